@@ -0,0 +1,277 @@
+package anvil
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem documents.
+const ProblemContentType = "application/problem+json"
+
+// problemDetails reports whether error responses are written as RFC 7807 problem documents.
+var problemDetails atomic.Bool
+
+// Common machine-readable error codes included in the "code" field of error responses.
+// Clients can rely on these values for programmatic handling and i18n, since unlike
+// the human-readable "error" message they never change.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeRequestTimeout       = "request_timeout"
+	CodeConflict             = "conflict"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeValidationFailed     = "validation_failed"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal_error"
+	CodeServiceUnavailable   = "service_unavailable"
+	CodeGatewayTimeout       = "gateway_timeout"
+)
+
+// statusCodes maps HTTP statuses to the error codes used when an error carries no explicit code.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusRequestTimeout:        CodeRequestTimeout,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   CodeValidationFailed,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusServiceUnavailable:    CodeServiceUnavailable,
+	http.StatusGatewayTimeout:        CodeGatewayTimeout,
+}
+
+// APIError is an error that carries the HTTP status and machine-readable code to respond with.
+// Handlers wrapped with HandlerFunc can return an *APIError (directly or wrapped with %w)
+// to control the status and "code" field of the JSON error response; any other error
+// is answered with 400 (Bad Request) and the code derived from that status.
+//
+// Example usage:
+//
+//	func getUser(w http.ResponseWriter, r *http.Request) error {
+//	    user, err := store.Find(r.PathValue("id"))
+//	    if errors.Is(err, sql.ErrNoRows) {
+//	        return NewAPIError(http.StatusNotFound, "user_not_found", "user does not exist")
+//	    }
+//	    ...
+//	}
+type APIError struct {
+	Status  int    // The HTTP status code of the response
+	Code    string // The machine-readable error code (derived from Status when empty)
+	Message string // The human-readable error message
+	Err     error  // The underlying cause, if any; never sent to the client
+}
+
+// NewAPIError creates an APIError with the given status, code and message.
+//
+// Parameters:
+//   - status: The HTTP status code of the response
+//   - code: The machine-readable error code (empty to derive it from the status)
+//   - message: The human-readable error message
+//
+// Returns:
+//   - *APIError: The new error
+func NewAPIError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// Error returns the human-readable message, falling back to the underlying cause or status text.
+func (e *APIError) Error() string {
+	switch {
+	case e.Message != "":
+		return e.Message
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return http.StatusText(e.Status)
+	}
+}
+
+// Unwrap returns the underlying cause so errors.Is and errors.As can inspect it.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// InternalErrorMessage is the message sent to clients for an InternalError.
+const InternalErrorMessage = "internal server error"
+
+// InternalError wraps a server-side failure whose details must not reach the client.
+// Unlike APIError, whose message is client-facing, an InternalError is always answered
+// with 500 (Internal Server Error), the code CodeInternal and the generic
+// InternalErrorMessage, even when it is wrapped with additional context. The cause is
+// logged instead: HandlerFunc logs it with the request ID, method and path, and
+// RespondWithError logs it without request context.
+//
+// Example usage:
+//
+//	func createOrder(w http.ResponseWriter, r *http.Request) error {
+//	    if err := store.Insert(r.Context(), order); err != nil {
+//	        return NewInternalError(fmt.Errorf("inserting order: %w", err))
+//	    }
+//	    ...
+//	}
+type InternalError struct {
+	Err error // The underlying cause; logged, never sent to the client
+}
+
+// NewInternalError wraps a cause in an InternalError.
+//
+// Parameters:
+//   - err: The underlying cause
+//
+// Returns:
+//   - *InternalError: The new error
+func NewInternalError(err error) *InternalError {
+	return &InternalError{Err: err}
+}
+
+// Error returns the generic message followed by the cause, for logs.
+func (e *InternalError) Error() string {
+	if e.Err == nil {
+		return InternalErrorMessage
+	}
+	return InternalErrorMessage + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying cause so errors.Is and errors.As can inspect it.
+func (e *InternalError) Unwrap() error {
+	return e.Err
+}
+
+// Problem is an RFC 7807 problem details document.
+// The machine-readable error code is used as the problem type, a URI reference
+// relative to the API (e.g., "not_found"), so clients can dispatch on it as they
+// would on the "code" field of the default error format.
+type Problem struct {
+	Type     string `json:"type"`               // The error code identifying the problem type
+	Title    string `json:"title"`              // The status text of the problem type
+	Status   int    `json:"status"`             // The HTTP status code
+	Detail   string `json:"detail,omitempty"`   // The human-readable explanation of this occurrence
+	Instance string `json:"instance,omitempty"` // A URI reference identifying this occurrence, if any
+}
+
+// Problem converts the error to an RFC 7807 problem document.
+// Status maps to "status", Code (or the code derived from Status) to "type", and
+// the message to "detail".
+//
+// Example usage:
+//
+//	p := NewAPIError(http.StatusNotFound, "user_not_found", "user does not exist").Problem()
+//	// Result: {"type": "user_not_found", "title": "Not Found", "status": 404, "detail": "user does not exist"}
+//
+// Returns:
+//   - Problem: The problem document
+func (e *APIError) Problem() Problem {
+	status := e.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+
+	code := e.Code
+	if code == "" {
+		code = ErrorCodeForStatus(status)
+	}
+
+	return Problem{
+		Type:   code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: e.Error(),
+	}
+}
+
+// NewProblem builds the RFC 7807 problem document for an error responded with a status.
+// An *APIError in the error chain is converted with its Problem method, so its code
+// and message are kept, and an *InternalError is described only by the generic
+// InternalErrorMessage; any other error is described by the status and its message.
+//
+// Parameters:
+//   - status: The HTTP status code of the response
+//   - err: The error to describe
+//
+// Returns:
+//   - Problem: The problem document
+func NewProblem(status int, err error) Problem {
+	var internal *InternalError
+	if errors.As(err, &internal) {
+		return Problem{
+			Type:   CodeInternal,
+			Title:  http.StatusText(status),
+			Status: status,
+			Detail: InternalErrorMessage,
+		}
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		p := apiErr.Problem()
+		p.Status, p.Title = status, http.StatusText(status)
+		p.Detail = err.Error()
+		return p
+	}
+
+	return Problem{
+		Type:   ErrorCodeForStatus(status),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: err.Error(),
+	}
+}
+
+// SetProblemDetails switches every error response of this package to RFC 7807 problem documents.
+// When enabled, RespondWithError, HandlerFunc and the middleware in this package answer
+// errors with an application/problem+json Problem instead of the default
+// {"error", "code", "timestamp"} object. It is safe to call concurrently with requests
+// being served, but is meant to be set once at startup.
+//
+// Example usage:
+//
+//	SetProblemDetails(true)
+//
+// Parameters:
+//   - enabled: true for problem documents, false for the default error format
+func SetProblemDetails(enabled bool) {
+	problemDetails.Store(enabled)
+}
+
+// ErrorCodeForStatus returns the default machine-readable error code for an HTTP status.
+// Statuses in the common code registry map to their constant (e.g., 404 to CodeNotFound);
+// other statuses are derived from their status text in snake case (e.g., 418 to
+// "im_a_teapot"), and unknown statuses return "error".
+//
+// Parameters:
+//   - status: The HTTP status code
+//
+// Returns:
+//   - string: The error code for the status
+func ErrorCodeForStatus(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-':
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
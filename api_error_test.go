@@ -0,0 +1,212 @@
+package anvil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorResponseIncludesCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "plain error", err: errors.New("name is required"), status: http.StatusBadRequest, code: CodeBadRequest},
+		{name: "api error with code", err: NewAPIError(http.StatusNotFound, "user_not_found", "user does not exist"), status: http.StatusNotFound, code: "user_not_found"},
+		{name: "api error without code", err: NewAPIError(http.StatusConflict, "", "email already registered"), status: http.StatusConflict, code: CodeConflict},
+		{name: "wrapped api error", err: fmt.Errorf("creating user: %w", NewAPIError(http.StatusForbidden, "", "not allowed")), status: http.StatusForbidden, code: CodeForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return tt.err })
+			rec := record(handler, httptest.NewRequest(http.MethodPost, "/users", nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			body := decodeErrorBody(t, rec)
+			if body["code"] != tt.code {
+				t.Errorf("code = %q, want %q", body["code"], tt.code)
+			}
+			if body["error"] != tt.err.Error() || body["timestamp"] == "" {
+				t.Errorf("body = %v, want the error message and a timestamp", body)
+			}
+		})
+	}
+}
+
+func TestErrorCodeForStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{status: http.StatusNotFound, want: CodeNotFound},
+		{status: http.StatusUnprocessableEntity, want: CodeValidationFailed},
+		{status: http.StatusTooManyRequests, want: CodeRateLimited},
+		{status: http.StatusTeapot, want: "im_a_teapot"},
+		{status: http.StatusRequestURITooLong, want: "request_uri_too_long"},
+		{status: 599, want: "error"},
+	}
+
+	for _, tt := range tests {
+		if got := ErrorCodeForStatus(tt.status); got != tt.want {
+			t.Errorf("ErrorCodeForStatus(%d) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestAPIErrorMessageFallbacks(t *testing.T) {
+	cause := errors.New("duplicate key")
+	tests := []struct {
+		err  *APIError
+		want string
+	}{
+		{err: &APIError{Status: http.StatusConflict, Message: "email already registered", Err: cause}, want: "email already registered"},
+		{err: &APIError{Status: http.StatusConflict, Err: cause}, want: "duplicate key"},
+		{err: &APIError{Status: http.StatusConflict}, want: "Conflict"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+	if !errors.Is(&APIError{Err: cause}, cause) {
+		t.Error("errors.Is(APIError, cause) = false, want the cause to be unwrapped")
+	}
+}
+
+func TestRespondWithProblem(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Problem
+	}{
+		{
+			name: "api error",
+			err:  NewAPIError(http.StatusNotFound, "user_not_found", "user does not exist"),
+			want: Problem{Type: "user_not_found", Title: "Not Found", Status: http.StatusNotFound, Detail: "user does not exist"},
+		},
+		{
+			name: "api error without code",
+			err:  NewAPIError(http.StatusConflict, "", "email already registered"),
+			want: Problem{Type: CodeConflict, Title: "Conflict", Status: http.StatusConflict, Detail: "email already registered"},
+		},
+		{
+			name: "plain error",
+			err:  errors.New("name is required"),
+			want: Problem{Type: CodeBadRequest, Title: "Bad Request", Status: http.StatusBadRequest, Detail: "name is required"},
+		},
+		{
+			name: "internal error",
+			err:  NewInternalError(errors.New("connection refused")),
+			want: Problem{Type: CodeInternal, Title: "Internal Server Error", Status: http.StatusInternalServerError, Detail: InternalErrorMessage},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if err := RespondWithProblem(rec, tt.err); err != nil {
+				t.Fatalf("RespondWithProblem() error = %v", err)
+			}
+
+			if rec.Code != tt.want.Status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want.Status)
+			}
+			if got := rec.Header().Get("Content-Type"); got != ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", got, ProblemContentType)
+			}
+			var got Problem
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("problem = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetProblemDetails(t *testing.T) {
+	SetProblemDetails(true)
+	t.Cleanup(func() { SetProblemDetails(false) })
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return NewAPIError(http.StatusNotFound, "user_not_found", "user does not exist")
+	})
+	rec := record(handler, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if got := rec.Header().Get("Content-Type"); got != ProblemContentType {
+		t.Errorf("Content-Type = %q, want %q", got, ProblemContentType)
+	}
+	var problem Problem
+	json.NewDecoder(rec.Body).Decode(&problem)
+	if problem.Type != "user_not_found" || problem.Status != http.StatusNotFound || problem.Detail != "user does not exist" {
+		t.Errorf("problem = %+v, want the API error fields", problem)
+	}
+
+	SetProblemDetails(false)
+	rec = record(handler, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if body := decodeErrorBody(t, rec); body["code"] != "user_not_found" {
+		t.Errorf("default error body = %v, want the code field", body)
+	}
+}
+
+func TestInternalErrorHidesCause(t *testing.T) {
+	cause := errors.New("pq: connection refused to 10.0.0.5")
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "internal error", err: NewInternalError(cause)},
+		{name: "wrapped internal error", err: fmt.Errorf("creating order: %w", NewInternalError(cause))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, cause) {
+				t.Errorf("errors.Is(err, cause) = false, want the cause to be unwrapped")
+			}
+
+			logs := captureLogs(t)
+			handler := RequestIDMiddleware(RequestIDOptions{})(HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				return tt.err
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+			r.Header.Set(DefaultRequestIDHeader, "req-123")
+			rec := record(handler, r)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+			}
+			body := decodeErrorBody(t, rec)
+			if body["error"] != InternalErrorMessage || body["code"] != CodeInternal {
+				t.Errorf("body = %v, want the generic message and %s code", body, CodeInternal)
+			}
+
+			out := logs.String()
+			for _, want := range []string{cause.Error(), "request_id=req-123", "path=/orders"} {
+				if !strings.Contains(out, want) {
+					t.Errorf("logs = %q, want %q", out, want)
+				}
+			}
+		})
+	}
+
+	logs := captureLogs(t)
+	rec := httptest.NewRecorder()
+	RespondWithError(rec, NewInternalError(cause))
+	if body := decodeErrorBody(t, rec); body["error"] != InternalErrorMessage {
+		t.Errorf("RespondWithError() body = %v, want the generic message", body)
+	}
+	if !strings.Contains(logs.String(), cause.Error()) {
+		t.Errorf("RespondWithError() logs = %q, want the cause", logs.String())
+	}
+}
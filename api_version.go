@@ -0,0 +1,109 @@
+package anvil
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultAPIVersionHeader is the header read by APIVersionMiddleware when no header is configured.
+const DefaultAPIVersionHeader = "X-API-Version"
+
+// APIVersionOptions configures APIVersionMiddleware.
+type APIVersionOptions struct {
+	Header        string               // The request header carrying the version (defaults to DefaultAPIVersionHeader)
+	Supported     []string             // Versions served without deprecation warnings
+	Deprecated    map[string]time.Time // Versions still served but deprecated, mapped to their sunset date (zero if not yet scheduled)
+	Required      bool                 // Whether to reject requests without a version header (400)
+	LogDeprecated bool                 // Whether to log requests that use a deprecated version
+}
+
+// APIVersionMiddleware creates middleware that enforces the requested API version.
+// The version is read from the configured header and must be either supported or
+// deprecated; any other version is rejected with a 400 (Bad Request) JSON error.
+// Requests without the header pass through unless opts.Required is set.
+//
+// For deprecated versions the response carries a "Deprecation: true" header and,
+// when a sunset date is configured, a "Sunset" header with that date (RFC 8594), so
+// clients learn about the upcoming removal before it happens. The requested version
+// is stored in the request context, retrievable with APIVersionFromContext.
+//
+// Example usage:
+//
+//	versions := APIVersionMiddleware(APIVersionOptions{
+//	    Supported:  []string{"2024-06-01"},
+//	    Deprecated: map[string]time.Time{"2023-01-01": time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+//	    Required:   true,
+//	})
+//	http.Handle("/api/", versions(apiHandler))
+//
+// Parameters:
+//   - opts: The version configuration
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces the API version
+func APIVersionMiddleware(opts APIVersionOptions) func(http.Handler) http.Handler {
+	header := opts.Header
+	if header == "" {
+		header = DefaultAPIVersionHeader
+	}
+
+	supported := make(map[string]bool, len(opts.Supported))
+	for _, version := range opts.Supported {
+		supported[version] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version := strings.TrimSpace(r.Header.Get(header))
+			if version == "" {
+				if opts.Required {
+					writeError(w, http.StatusBadRequest, fmt.Errorf("missing %s header", header))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !supported[version] {
+				sunset, deprecated := opts.Deprecated[version]
+				if !deprecated {
+					writeError(w, http.StatusBadRequest, fmt.Errorf("unsupported api version %q", version))
+					return
+				}
+
+				w.Header().Set("Deprecation", "true")
+				if !sunset.IsZero() {
+					w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+				}
+				if opts.LogDeprecated {
+					slog.Warn(
+						"deprecated api version used",
+						"version", version,
+						"method", r.Method,
+						"path", r.URL.Path,
+						"ip_address", ClientIP(r),
+					)
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), apiVersionContextKey, version)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// APIVersionFromContext returns the API version stored by APIVersionMiddleware.
+//
+// Parameters:
+//   - ctx: The request context
+//
+// Returns:
+//   - string: The requested API version, or an empty string if none was sent
+func APIVersionFromContext(ctx context.Context) string {
+	version, _ := ctx.Value(apiVersionContextKey).(string)
+	return version
+}
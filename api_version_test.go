@@ -0,0 +1,78 @@
+package anvil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIVersionMiddleware(t *testing.T) {
+	sunset := time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+	opts := APIVersionOptions{
+		Supported:     []string{"2024-06-01"},
+		Deprecated:    map[string]time.Time{"2023-01-01": sunset, "2023-06-01": {}},
+		LogDeprecated: true,
+	}
+
+	tests := []struct {
+		name        string
+		opts        APIVersionOptions
+		version     string
+		status      int
+		deprecation string
+		sunset      string
+	}{
+		{name: "current", opts: opts, version: "2024-06-01", status: http.StatusOK},
+		{name: "deprecated with sunset", opts: opts, version: "2023-01-01", status: http.StatusOK, deprecation: "true", sunset: "Mon, 30 Jun 2025 00:00:00 GMT"},
+		{name: "deprecated without sunset", opts: opts, version: "2023-06-01", status: http.StatusOK, deprecation: "true"},
+		{name: "unsupported", opts: opts, version: "2022-01-01", status: http.StatusBadRequest},
+		{name: "absent", opts: opts, status: http.StatusOK},
+		{name: "absent but required", opts: APIVersionOptions{Supported: opts.Supported, Required: true}, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLogs(t)
+			var seen string
+			handler := APIVersionMiddleware(tt.opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = APIVersionFromContext(r.Context())
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+			if tt.version != "" {
+				r.Header.Set(DefaultAPIVersionHeader, tt.version)
+			}
+			rec := record(handler, r)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Deprecation"); got != tt.deprecation {
+				t.Errorf("Deprecation = %q, want %q", got, tt.deprecation)
+			}
+			if got := rec.Header().Get("Sunset"); got != tt.sunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.sunset)
+			}
+			if tt.status == http.StatusOK && seen != tt.version {
+				t.Errorf("APIVersionFromContext() = %q, want %q", seen, tt.version)
+			}
+		})
+	}
+}
+
+func TestAPIVersionMiddlewareLogsDeprecatedUse(t *testing.T) {
+	logs := captureLogs(t)
+	handler := APIVersionMiddleware(APIVersionOptions{
+		Deprecated:    map[string]time.Time{"v1": {}},
+		LogDeprecated: true,
+	})(statusHandler(http.StatusOK))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	r.Header.Set(DefaultAPIVersionHeader, "v1")
+	record(handler, r)
+
+	if out := logs.String(); !strings.Contains(out, "deprecated api version used") || !strings.Contains(out, "version=v1") {
+		t.Errorf("logs = %q, want the deprecated version to be logged", out)
+	}
+}
@@ -0,0 +1,520 @@
+package anvil
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arbenlabs/anvil/tools"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// RefreshedTokenHeader is the response header carrying a refreshed token (see AuthOptions.RefreshThreshold).
+	RefreshedTokenHeader = "X-New-Token"
+
+	// WebSocketBearerProtocol is the Sec-WebSocket-Protocol entry announcing that the next entry is a bearer token.
+	WebSocketBearerProtocol = "bearer"
+)
+
+var (
+	// errMissingToken is returned when a request carries no token in any of the configured locations.
+	errMissingToken = errors.New("missing authentication token")
+
+	// errMalformedAuthHeader is returned when the Authorization header is not in the expected
+	// "Bearer <token>" (or, for ParseAuthorization, "<scheme> <credentials>") format.
+	errMalformedAuthHeader = errors.New("invalid authorization header")
+
+	// errMalformedWebSocketProtocol is returned when the Sec-WebSocket-Protocol header
+	// announces a bearer token but does not carry one.
+	errMalformedWebSocketProtocol = errors.New("invalid websocket subprotocol token")
+)
+
+// AuthOptions configures where authentication middleware looks for a token.
+// The Authorization header is always checked first and takes precedence over any
+// fallback location.
+//
+// Reading tokens from cookies makes the browser attach them automatically to every
+// request for the site, including requests triggered by other origins. When CookieName
+// is set, protect state-changing endpoints against cross-site request forgery, for
+// example by setting the cookie with SameSite=Strict or Lax and by requiring a CSRF
+// token on unsafe methods.
+//
+// QueryParam exists for links the browser navigates to directly, such as pre-signed
+// download links, where no header can be set. Tokens in URLs leak easily: they end up
+// in access logs, proxy logs, browser history and Referer headers sent to other
+// sites. Only use it with short-lived, narrowly scoped tokens, serve such responses
+// with "Referrer-Policy: no-referrer", and never use it for session tokens.
+//
+// WebSocketProtocol is for browser WebSocket clients, which cannot set the
+// Authorization header. They pass the token as a subprotocol instead, announced by
+// WebSocketBearerProtocol (new WebSocket(url, ["bearer", token])). On upgrade requests
+// the token is then read from Sec-WebSocket-Protocol, and once it is verified "bearer"
+// is echoed back, since browsers fail the handshake unless the server selects one of
+// the offered subprotocols. WebSocket libraries that select an application
+// subprotocol replace the echoed value. Like QueryParam, this avoids headers the
+// browser cannot set, but the token is not written to URLs and logs.
+//
+// RefreshThreshold enables sliding sessions: a valid token expiring within the
+// threshold is replaced by a fresh one from tools.JWT.Refresh, sent in the
+// RefreshedTokenHeader response header. Clients should swap in the new token when
+// the header is present. Browsers only expose the header to cross-origin scripts if
+// it is listed in the CORS exposed headers.
+//
+// VerifyCacheTTL reuses successful verifications of the same token for a short window,
+// which spares repeated signature checks under bursts from one client and, for
+// ClerkAuthMiddlewareWithOptions, repeated networked verification. Concurrent
+// verifications of the same token are collapsed into one. Results are keyed by a hash
+// of the token and never outlive the token's expiry, but a token revoked within the
+// window (see tools.JWT.WithSessionStore) is accepted until its cached result expires,
+// so keep the TTL to a few seconds.
+type AuthOptions struct {
+	CookieName        string        // Optional cookie to read the token from when the Authorization header is absent
+	QueryParam        string        // Optional query parameter to read the token from when neither the header nor the cookie carries one
+	WebSocketProtocol bool          // Optional: read the token from Sec-WebSocket-Protocol on WebSocket upgrade requests
+	RefreshThreshold  time.Duration // Optional remaining lifetime below which valid tokens are refreshed (0 disables refreshing)
+	VerifyCacheTTL    time.Duration // Optional window during which a successful verification of the same token is reused (0 disables caching)
+}
+
+// JWTAuthMiddleware creates middleware that requires a valid JSON Web Token.
+// The token is read from the "Authorization: Bearer <token>" header or, when the header
+// is absent, from the fallback locations configured in opts. The token is verified with
+// the provided JWT service and the resulting claims are stored in the request context,
+// where they can be retrieved with ClaimsFromContext.
+//
+// Requests without a token, with a malformed Authorization header, or with an invalid
+// token receive a 401 (Unauthorized) JSON error response.
+//
+// Example usage:
+//
+//	jwtService := tools.NewJsonWebToken("myapp.com", key)
+//	auth := JWTAuthMiddleware(jwtService, AuthOptions{CookieName: "session"})
+//	http.Handle("/api/me", auth(meHandler))
+//
+//	// Sliding sessions: tokens expiring within 5 minutes are refreshed via X-New-Token
+//	sliding := JWTAuthMiddleware(jwtService, AuthOptions{RefreshThreshold: 5 * time.Minute})
+//	http.Handle("/api/", sliding(apiHandler))
+//
+//	// Browser WebSockets: new WebSocket(url, ["bearer", token])
+//	ws := JWTAuthMiddleware(jwtService, AuthOptions{WebSocketProtocol: true})
+//	http.Handle("/ws", ws(socketHandler))
+//
+//	// Download links: /files/report.pdf?token=<short-lived token>
+//	download := JWTAuthMiddleware(downloadTokens, AuthOptions{QueryParam: "token"})
+//	http.Handle("/files/", download(fileHandler))
+//
+// Parameters:
+//   - j: The JWT service used to verify tokens
+//   - opts: Options controlling where the token is read from
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that requires a valid token
+func JWTAuthMiddleware(j *tools.JWT, opts AuthOptions) func(http.Handler) http.Handler {
+	verify := j.Verify
+	if opts.VerifyCacheTTL > 0 {
+		cache := newVerifyCache[tools.JWTClaims](opts.VerifyCacheTTL)
+		verify = func(token string) (tools.JWTClaims, error) {
+			return cache.verify(token, func(token string) (tools.JWTClaims, time.Time, error) {
+				claims, err := j.Verify(token)
+				return claims, jwtExpiry(token), err
+			})
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := tokenFromRequest(r, opts)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+
+			claims, err := verify(token)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+				return
+			}
+
+			if opts.RefreshThreshold > 0 {
+				refreshToken(w, j, token, opts.RefreshThreshold)
+			}
+			if opts.WebSocketProtocol {
+				acceptWebSocketProtocol(w, r)
+			}
+
+			ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// jwtExpiry returns the expiry of a token that has already been verified.
+//
+// Parameters:
+//   - token: The verified token
+//
+// Returns:
+//   - time.Time: The "exp" claim, or the zero time if the token has none
+func jwtExpiry(token string) time.Time {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return time.Time{}
+	}
+	exp, err := parsed.Claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}
+	}
+	return exp.Time
+}
+
+// refreshToken sets the RefreshedTokenHeader when a verified token is close to its expiry.
+// Failures are logged and leave the response without a new token, since the current
+// token is still valid.
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - j: The JWT service used to refresh the token
+//   - token: The verified token
+//   - threshold: The remaining lifetime below which the token is refreshed
+func refreshToken(w http.ResponseWriter, j *tools.JWT, token string, threshold time.Duration) {
+	fresh, refreshed, err := j.Refresh(token, threshold)
+	if err != nil {
+		slog.Warn("unable to refresh token", "error", err.Error())
+		return
+	}
+	if refreshed {
+		w.Header().Set(RefreshedTokenHeader, fresh)
+	}
+}
+
+// RequireScope creates middleware that requires a valid token granting all of the given scopes.
+// The token is read from the Authorization header and verified with the provided JWT
+// service, then its space-delimited "scope" claim is checked with tools.HasScope.
+//
+// The middleware responds with:
+//   - 401 (Unauthorized) when the token is missing or invalid
+//   - 403 (Forbidden) when the token is valid but lacks any of the required scopes
+//
+// On success the claims are stored in the request context, where they can be
+// retrieved with ClaimsFromContext.
+//
+// Example usage:
+//
+//	http.Handle("/api/users", RequireScope(jwtService, "read:users")(listUsersHandler))
+//
+// Parameters:
+//   - j: The JWT service used to verify tokens
+//   - scopes: The scopes that must all be granted by the token
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces the required scopes
+func RequireScope(j *tools.JWT, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := tokenFromRequest(r, AuthOptions{})
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+
+			claims, err := j.Verify(token)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+				return
+			}
+
+			if !tools.HasScope(claims, scopes...) {
+				writeError(w, http.StatusForbidden, errors.New("insufficient scope"))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// errMissingClientCert is returned when a request is not made over TLS or presents no client certificate.
+var errMissingClientCert = errors.New("client certificate required")
+
+// RequireClientCertMiddleware creates middleware that requires a TLS client certificate.
+// The leaf certificate in r.TLS.PeerCertificates is passed to verify, which can check
+// it against the identities allowed to call the API (e.g., subject common names or
+// DNS SANs).
+//
+// The server must request client certificates for PeerCertificates to be populated,
+// by setting tls.Config.ClientAuth. Certificates are only checked against ClientCAs
+// with tls.VerifyClientCertIfGiven or tls.RequireAndVerifyClientCert; with
+// tls.RequestClientCert or tls.RequireAnyClientCert, verify must validate the chain
+// itself.
+//
+// The middleware responds with:
+//   - 401 (Unauthorized) when the request is not made over TLS or presents no certificate
+//   - 403 (Forbidden) when verify rejects the certificate
+//
+// Example usage:
+//
+//	allowed := map[string]bool{"billing-service": true, "reports-service": true}
+//	mtls := RequireClientCertMiddleware(func(cert *x509.Certificate) error {
+//	    if !allowed[cert.Subject.CommonName] {
+//	        return fmt.Errorf("client %q is not allowed", cert.Subject.CommonName)
+//	    }
+//	    return nil
+//	})
+//	http.Handle("/internal/", mtls(internalHandler))
+//
+// Parameters:
+//   - verify: The function checking the client certificate (nil accepts any certificate)
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that requires an accepted client certificate
+func RequireClientCertMiddleware(verify func(*x509.Certificate) error) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				writeError(w, http.StatusUnauthorized, errMissingClientCert)
+				return
+			}
+
+			if verify != nil {
+				if err := verify(r.TLS.PeerCertificates[0]); err != nil {
+					writeError(w, http.StatusForbidden, errors.New("client certificate not allowed"))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClaimsFromContext returns the token claims stored by JWTAuthMiddleware.
+//
+// Example usage:
+//
+//	claims, ok := ClaimsFromContext(r.Context())
+//	if !ok {
+//	    // the request was not authenticated
+//	}
+//
+// Parameters:
+//   - ctx: The request context
+//
+// Returns:
+//   - tools.JWTClaims: The verified token claims
+//   - bool: true if claims were found in the context, false otherwise
+func ClaimsFromContext(ctx context.Context) (tools.JWTClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(tools.JWTClaims)
+	return claims, ok
+}
+
+// tokenFromRequest extracts an authentication token from the request.
+// The Authorization header takes precedence: if it is present, it must be a
+// well-formed bearer token and no fallback is consulted. Otherwise, the WebSocket
+// subprotocol (for upgrade requests), the cookie and then the query parameter named
+// in opts are used when configured.
+//
+// Parameters:
+//   - r: The HTTP request to read the token from
+//   - opts: Options controlling the fallback locations
+//
+// Returns:
+//   - string: The extracted token
+//   - error: errMissingToken if no token was found, or an error if the header or subprotocol is malformed
+func tokenFromRequest(r *http.Request, opts AuthOptions) (string, error) {
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		token, ok := bearerToken(authHeader)
+		if !ok {
+			return "", errMalformedAuthHeader
+		}
+		return token, nil
+	}
+
+	if opts.WebSocketProtocol && isWebSocketUpgrade(r) {
+		token, _, err := WebSocketBearerToken(r)
+		if !errors.Is(err, errMissingToken) {
+			return token, err
+		}
+	}
+
+	if opts.CookieName != "" {
+		if cookie, err := r.Cookie(opts.CookieName); err == nil && cookie.Value != "" {
+			return cookie.Value, nil
+		}
+	}
+
+	if opts.QueryParam != "" {
+		if token := r.URL.Query().Get(opts.QueryParam); token != "" {
+			return token, nil
+		}
+	}
+
+	return "", errMissingToken
+}
+
+// ParseAuthorization splits the Authorization header into its scheme and credentials.
+// This function is intended for endpoints that accept several authentication schemes
+// (for example both Bearer and Basic) and need to dispatch on the scheme. The scheme
+// is returned in lowercase, since schemes are case-insensitive (RFC 9110), and the
+// credentials are returned verbatim without decoding.
+//
+// Example usage:
+//
+//	scheme, credentials, err := ParseAuthorization(r)
+//	if err != nil {
+//	    return err
+//	}
+//	switch scheme {
+//	case "bearer":
+//	    claims, err = jwtService.Verify(credentials)
+//	case "basic":
+//	    user, pass, ok = r.BasicAuth()
+//	}
+//
+// Parameters:
+//   - r: The HTTP request to read the Authorization header from
+//
+// Returns:
+//   - string: The lowercase authentication scheme (e.g., "bearer", "basic")
+//   - string: The raw credentials following the scheme
+//   - error: errMissingToken if the header is absent, or errMalformedAuthHeader if it is not "<scheme> <credentials>"
+func ParseAuthorization(r *http.Request) (scheme, credentials string, err error) {
+	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
+	if authHeader == "" {
+		return "", "", errMissingToken
+	}
+
+	scheme, credentials, ok := strings.Cut(authHeader, " ")
+	credentials = strings.TrimSpace(credentials)
+	if !ok || credentials == "" || !isAuthScheme(scheme) {
+		return "", "", errMalformedAuthHeader
+	}
+
+	return strings.ToLower(scheme), credentials, nil
+}
+
+// isAuthScheme reports whether s is a valid authentication scheme token (RFC 9110 token characters).
+//
+// Parameters:
+//   - s: The candidate scheme
+//
+// Returns:
+//   - bool: true if s is a non-empty token, false otherwise
+func isAuthScheme(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WebSocketBearerToken extracts a bearer token from the Sec-WebSocket-Protocol header.
+// Browser WebSocket clients cannot set the Authorization header, so the token is
+// passed as a subprotocol following WebSocketBearerProtocol:
+//
+//	new WebSocket("wss://api.example.com/ws", ["bearer", token, "chat.v1"])
+//
+// sends "Sec-WebSocket-Protocol: bearer, <token>, chat.v1". The returned protocol is
+// the one to echo back in the handshake response: the first offered protocol that
+// is in supported, or WebSocketBearerProtocol if none is. Browsers fail the
+// handshake unless one of the offered protocols is echoed, and the token itself must
+// never be. JWTAuthMiddleware and ClerkAuthMiddlewareWithOptions use this function
+// when AuthOptions.WebSocketProtocol is set.
+//
+// Example usage:
+//
+//	token, protocol, err := WebSocketBearerToken(r, "chat.v1")
+//	if err != nil {
+//	    RespondWithError(w, NewAPIError(http.StatusUnauthorized, CodeUnauthorized, err.Error()))
+//	    return
+//	}
+//	claims, err := jwtService.Verify(token)
+//	// ...
+//	conn, err := upgrader.Upgrade(w, r, http.Header{"Sec-WebSocket-Protocol": {protocol}})
+//
+// Parameters:
+//   - r: The WebSocket upgrade request
+//   - supported: The application subprotocols the server speaks, in order of preference
+//
+// Returns:
+//   - string: The bearer token
+//   - string: The subprotocol to echo back in the Sec-WebSocket-Protocol response header
+//   - error: errMissingToken if no token is announced, or an error if the announced token is missing
+func WebSocketBearerToken(r *http.Request, supported ...string) (token, protocol string, err error) {
+	var offered []string
+	tokenIndex := -1
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			if entry == WebSocketBearerProtocol && tokenIndex < 0 {
+				tokenIndex = len(offered) + 1
+			}
+			offered = append(offered, entry)
+		}
+	}
+
+	if tokenIndex < 0 {
+		return "", "", errMissingToken
+	}
+	if tokenIndex >= len(offered) {
+		return "", "", errMalformedWebSocketProtocol
+	}
+	token = offered[tokenIndex]
+
+	for _, want := range supported {
+		for i, entry := range offered {
+			if i != tokenIndex && entry == want {
+				return token, want, nil
+			}
+		}
+	}
+	return token, WebSocketBearerProtocol, nil
+}
+
+// acceptWebSocketProtocol echoes the subprotocol selected by WebSocketBearerToken
+// on upgrade requests that carry their token in Sec-WebSocket-Protocol, unless a
+// subprotocol was already selected.
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - r: The HTTP request
+func acceptWebSocketProtocol(w http.ResponseWriter, r *http.Request) {
+	if !isWebSocketUpgrade(r) || w.Header().Get("Sec-WebSocket-Protocol") != "" {
+		return
+	}
+	if _, protocol, err := WebSocketBearerToken(r); err == nil {
+		w.Header().Set("Sec-WebSocket-Protocol", protocol)
+	}
+}
+
+// isWebSocketUpgrade reports whether the request asks to upgrade to the WebSocket protocol.
+//
+// Parameters:
+//   - r: The HTTP request
+//
+// Returns:
+//   - bool: true if the request carries "Upgrade: websocket"
+func isWebSocketUpgrade(r *http.Request) bool {
+	for _, value := range r.Header.Values("Upgrade") {
+		for _, protocol := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(protocol), "websocket") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,349 @@
+package anvil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arbenlabs/anvil/tools"
+)
+
+// authKey signs the tokens in the authentication tests.
+var authKey = []byte("auth-test-signing-key-0123456789")
+
+// newAuthToken returns a JWT service and a valid token for user123 with the given scope.
+func newAuthToken(t *testing.T, scope string) (*tools.JWT, string) {
+	t.Helper()
+	j := tools.NewJsonWebToken("myapp.com", authKey)
+	token, err := j.Generate(tools.JWTClaims{ID: "user123", Email: "user@example.com", Scope: scope}, nil)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	return j, token
+}
+
+// serveAuth sends r through mw and returns the response and the claims the next handler saw.
+func serveAuth(mw func(http.Handler) http.Handler, r *http.Request) (*httptest.ResponseRecorder, tools.JWTClaims) {
+	var claims tools.JWTClaims
+	rec := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ = ClaimsFromContext(r.Context())
+	})).ServeHTTP(rec, r)
+	return rec, claims
+}
+
+func TestJWTAuthMiddlewareTokenLocations(t *testing.T) {
+	j, token := newAuthToken(t, "")
+	mw := JWTAuthMiddleware(j, AuthOptions{CookieName: "session"})
+
+	tests := []struct {
+		name   string
+		setup  func(r *http.Request)
+		status int
+	}{
+		{name: "header", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }, status: http.StatusOK},
+		{name: "cookie", setup: func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session", Value: token}) }, status: http.StatusOK},
+		{name: "header takes precedence", setup: func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer invalid")
+			r.AddCookie(&http.Cookie{Name: "session", Value: token})
+		}, status: http.StatusUnauthorized},
+		{name: "malformed header", setup: func(r *http.Request) { r.Header.Set("Authorization", "Token "+token) }, status: http.StatusUnauthorized},
+		{name: "other cookie", setup: func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "theme", Value: token}) }, status: http.StatusUnauthorized},
+		{name: "missing", setup: func(r *http.Request) {}, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+			tt.setup(r)
+			rec, claims := serveAuth(mw, r)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && claims.ID != "user123" {
+				t.Errorf("ClaimsFromContext().ID = %q, want user123", claims.ID)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	j, token := newAuthToken(t, "read:users write:users")
+
+	tests := []struct {
+		name   string
+		token  string
+		scopes []string
+		status int
+	}{
+		{name: "granted", token: token, scopes: []string{"read:users"}, status: http.StatusOK},
+		{name: "all granted", token: token, scopes: []string{"read:users", "write:users"}, status: http.StatusOK},
+		{name: "missing scope", token: token, scopes: []string{"read:users", "admin"}, status: http.StatusForbidden},
+		{name: "invalid token", token: "invalid", scopes: []string{"read:users"}, status: http.StatusUnauthorized},
+		{name: "no token", scopes: []string{"read:users"}, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec, claims := serveAuth(RequireScope(j, tt.scopes...), r)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && claims.ID != "user123" {
+				t.Errorf("claims.ID = %q, want user123", claims.ID)
+			}
+		})
+	}
+}
+
+func TestParseAuthorization(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		scheme      string
+		credentials string
+		err         error
+	}{
+		{name: "bearer", header: "Bearer abc.def.ghi", scheme: "bearer", credentials: "abc.def.ghi"},
+		{name: "basic", header: "Basic dXNlcjpwYXNz", scheme: "basic", credentials: "dXNlcjpwYXNz"},
+		{name: "mixed case scheme", header: "bEaReR token", scheme: "bearer", credentials: "token"},
+		{name: "extra spaces", header: "  Bearer   token  ", scheme: "bearer", credentials: "token"},
+		{name: "missing", err: errMissingToken},
+		{name: "scheme only", header: "Bearer", err: errMalformedAuthHeader},
+		{name: "no credentials", header: "Bearer   ", err: errMalformedAuthHeader},
+		{name: "invalid scheme", header: "Bear(er) token", err: errMalformedAuthHeader},
+		{name: "garbage", header: "@@@ ###", err: errMalformedAuthHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			scheme, credentials, err := ParseAuthorization(r)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseAuthorization() error = %v, want %v", err, tt.err)
+			}
+			if scheme != tt.scheme || credentials != tt.credentials {
+				t.Errorf("ParseAuthorization() = %q, %q; want %q, %q", scheme, credentials, tt.scheme, tt.credentials)
+			}
+		})
+	}
+}
+
+func TestJWTAuthMiddlewareQueryParam(t *testing.T) {
+	j, token := newAuthToken(t, "")
+	mw := JWTAuthMiddleware(j, AuthOptions{CookieName: "session", QueryParam: "token"})
+
+	tests := []struct {
+		name   string
+		target string
+		setup  func(r *http.Request)
+		status int
+	}{
+		{name: "query", target: "/download/report.pdf?token=" + token, setup: func(r *http.Request) {}, status: http.StatusOK},
+		{name: "header takes precedence", target: "/download/report.pdf?token=" + token, setup: func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer invalid")
+		}, status: http.StatusUnauthorized},
+		{name: "cookie takes precedence", target: "/download/report.pdf?token=invalid", setup: func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "session", Value: token})
+		}, status: http.StatusOK},
+		{name: "invalid query token", target: "/download/report.pdf?token=invalid", setup: func(r *http.Request) {}, status: http.StatusUnauthorized},
+		{name: "other parameter", target: "/download/report.pdf?access_token=" + token, setup: func(r *http.Request) {}, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			tt.setup(r)
+			if rec, _ := serveAuth(mw, r); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/download/report.pdf?token="+token, nil)
+	if rec, _ := serveAuth(JWTAuthMiddleware(j, AuthOptions{}), r); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status without QueryParam = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireClientCertMiddleware(t *testing.T) {
+	mtls := RequireClientCertMiddleware(func(cert *x509.Certificate) error {
+		if cert.Subject.CommonName != "billing-service" {
+			return fmt.Errorf("client %q is not allowed", cert.Subject.CommonName)
+		}
+		return nil
+	})
+	handler := mtls(statusHandler(http.StatusOK))
+
+	withCert := func(commonName string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "https://internal.example.com/ledger", nil)
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: commonName}}}}
+		return r
+	}
+	noCert := httptest.NewRequest(http.MethodGet, "https://internal.example.com/ledger", nil)
+	noCert.TLS = &tls.ConnectionState{}
+
+	tests := []struct {
+		name   string
+		r      *http.Request
+		status int
+	}{
+		{name: "valid certificate", r: withCert("billing-service"), status: http.StatusOK},
+		{name: "untrusted certificate", r: withCert("reports-service"), status: http.StatusForbidden},
+		{name: "tls without certificate", r: noCert, status: http.StatusUnauthorized},
+		{name: "plain request", r: httptest.NewRequest(http.MethodGet, "/ledger", nil), status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tt.r)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	RequireClientCertMiddleware(nil)(statusHandler(http.StatusOK)).ServeHTTP(rec, withCert("anyone"))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status without a verifier = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestJWTAuthMiddlewareRefreshThreshold(t *testing.T) {
+	j, fresh := newAuthToken(t, "read:users")
+	lifetime := 2
+	expiring, err := j.Generate(tools.JWTClaims{ID: "user123", Email: "user@example.com", Scope: "read:users"}, &lifetime)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	mw := JWTAuthMiddleware(j, AuthOptions{RefreshThreshold: 5 * time.Minute})
+
+	tests := []struct {
+		name      string
+		token     string
+		status    int
+		refreshed bool
+	}{
+		{name: "near expiry", token: expiring, status: http.StatusOK, refreshed: true},
+		{name: "fresh", token: fresh, status: http.StatusOK},
+		{name: "invalid", token: "invalid", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			rec, _ := serveAuth(mw, r)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			newToken := rec.Header().Get(RefreshedTokenHeader)
+			if (newToken != "") != tt.refreshed {
+				t.Fatalf("%s = %q, want refreshed %v", RefreshedTokenHeader, newToken, tt.refreshed)
+			}
+			if !tt.refreshed {
+				return
+			}
+			oldID, _ := j.Claim(tt.token, "jti")
+			if newID, _ := j.Claim(newToken, "jti"); newID == oldID {
+				t.Error("refreshed token kept the jti of the original token")
+			}
+			if claims, err := j.Verify(newToken); err != nil || claims.ID != "user123" || claims.Scope != "read:users" {
+				t.Errorf("Verify(refreshed) = %+v, %v; want the original claims", claims, err)
+			}
+		})
+	}
+}
+
+func TestWebSocketBearerToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    []string
+		supported []string
+		token     string
+		protocol  string
+		err       error
+	}{
+		{name: "token only", header: []string{"bearer, tkn123"}, token: "tkn123", protocol: WebSocketBearerProtocol},
+		{name: "application protocol", header: []string{"bearer, tkn123, chat.v1"}, supported: []string{"chat.v2", "chat.v1"}, token: "tkn123", protocol: "chat.v1"},
+		{name: "unsupported protocol", header: []string{"chat.v1, bearer, tkn123"}, supported: []string{"chat.v2"}, token: "tkn123", protocol: WebSocketBearerProtocol},
+		{name: "repeated headers", header: []string{"bearer", "tkn123"}, token: "tkn123", protocol: WebSocketBearerProtocol},
+		{name: "token never echoed", header: []string{"bearer, chat.v1"}, supported: []string{"chat.v1"}, token: "chat.v1", protocol: WebSocketBearerProtocol},
+		{name: "absent", header: []string{"chat.v1"}, err: errMissingToken},
+		{name: "no header", err: errMissingToken},
+		{name: "announced without token", header: []string{"chat.v1, bearer"}, err: errMalformedWebSocketProtocol},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			for _, value := range tt.header {
+				r.Header.Add("Sec-WebSocket-Protocol", value)
+			}
+
+			token, protocol, err := WebSocketBearerToken(r, tt.supported...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("WebSocketBearerToken() error = %v, want %v", err, tt.err)
+			}
+			if token != tt.token || protocol != tt.protocol {
+				t.Errorf("WebSocketBearerToken() = %q, %q; want %q, %q", token, protocol, tt.token, tt.protocol)
+			}
+		})
+	}
+}
+
+func TestJWTAuthMiddlewareWebSocketProtocol(t *testing.T) {
+	j, token := newAuthToken(t, "")
+	mw := JWTAuthMiddleware(j, AuthOptions{WebSocketProtocol: true})
+
+	tests := []struct {
+		name     string
+		upgrade  bool
+		protocol string
+		status   int
+		echoed   string
+	}{
+		{name: "upgrade with token", upgrade: true, protocol: "bearer, " + token, status: http.StatusOK, echoed: WebSocketBearerProtocol},
+		{name: "upgrade without token", upgrade: true, protocol: "chat.v1", status: http.StatusUnauthorized},
+		{name: "plain request", protocol: "bearer, " + token, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			if tt.upgrade {
+				r.Header.Set("Connection", "Upgrade")
+				r.Header.Set("Upgrade", "websocket")
+			}
+			r.Header.Set("Sec-WebSocket-Protocol", tt.protocol)
+
+			rec, claims := serveAuth(mw, r)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Sec-WebSocket-Protocol"); got != tt.echoed {
+				t.Errorf("Sec-WebSocket-Protocol = %q, want %q", got, tt.echoed)
+			}
+			if tt.status == http.StatusOK && claims.ID != "user123" {
+				t.Errorf("claims = %+v, want user123", claims)
+			}
+		})
+	}
+}
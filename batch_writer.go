@@ -0,0 +1,213 @@
+package anvil
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBatchSize is the default number of buffered bytes at which BatchWritesMiddleware flushes.
+	DefaultBatchSize = 16 << 10
+
+	// DefaultBatchInterval is the default longest time BatchWritesMiddleware holds buffered bytes.
+	DefaultBatchInterval = 100 * time.Millisecond
+)
+
+// BatchWritesMiddleware creates middleware that coalesces many small response writes.
+// Handlers that stream fragments, such as progress updates, often flush after every
+// fragment so clients see them promptly, which costs a write to the connection each
+// time. Behind this middleware such handlers only write: their writes are buffered
+// and sent to the client, flushed, once size bytes have accumulated or interval has
+// passed since the oldest buffered byte, whichever comes first, so clients still see
+// progress promptly. Writes larger than size are sent right away, and the
+// http.Flusher interface remains available for output that must go out immediately.
+//
+// Whatever is still buffered is sent when the handler returns, so no output is lost
+// when the connection closes. If the handler panics, the buffered output is
+// discarded rather than sent as if the response were complete.
+//
+// Example usage:
+//
+//	batched := BatchWritesMiddleware(4<<10, 250*time.Millisecond)
+//	http.Handle("/api/imports/{id}/progress", batched(progressHandler))
+//
+// Parameters:
+//   - size: The number of buffered bytes that triggers a flush (DefaultBatchSize if <= 0)
+//   - interval: The longest time bytes stay buffered (DefaultBatchInterval if <= 0)
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that batches response writes
+func BatchWritesMiddleware(size int, interval time.Duration) func(http.Handler) http.Handler {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	if interval <= 0 {
+		interval = DefaultBatchInterval
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bw := &batchWriter{ResponseWriter: w, size: size, interval: interval}
+
+			completed := false
+			defer func() {
+				bw.close(completed)
+			}()
+
+			next.ServeHTTP(bw, r)
+			completed = true
+		})
+	}
+}
+
+// batchWriter buffers response writes and sends them in batches.
+// A timer sends the batch once the interval has passed, so every access to the
+// buffer and the underlying writer is guarded by the mutex.
+type batchWriter struct {
+	http.ResponseWriter
+	size     int
+	interval time.Duration
+
+	mu          sync.Mutex
+	buf         bytes.Buffer
+	timer       *time.Timer
+	err         error // The error of a batch sent outside Write, returned by the next Write
+	wroteHeader bool
+	closed      bool
+}
+
+// WriteHeader forwards the status to the underlying writer.
+func (bw *batchWriter) WriteHeader(status int) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	bw.writeHeader(status)
+}
+
+// Write buffers b, sending the batch once it reaches the size threshold.
+// Writes larger than the threshold are sent right away, after the current batch.
+// The status is written first, so the headers are fixed before the timer may send
+// the batch from another goroutine.
+func (bw *batchWriter) Write(b []byte) (int, error) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if bw.err != nil {
+		return 0, bw.err
+	}
+	bw.writeHeader(http.StatusOK)
+
+	if len(b) >= bw.size {
+		if err := bw.flushLocked(); err != nil {
+			return 0, err
+		}
+		n, err := bw.ResponseWriter.Write(b)
+		if err == nil {
+			bw.flushUnderlying()
+		}
+		return n, err
+	}
+
+	bw.buf.Write(b)
+	if bw.buf.Len() >= bw.size {
+		return len(b), bw.flushLocked()
+	}
+	if bw.timer == nil {
+		bw.timer = time.AfterFunc(bw.interval, bw.flushTimer)
+	}
+	return len(b), nil
+}
+
+// Flush implements http.Flusher by sending the buffered batch right away.
+func (bw *batchWriter) Flush() {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if bw.buf.Len() == 0 {
+		bw.flushUnderlying()
+		return
+	}
+	if err := bw.flushLocked(); err != nil {
+		bw.err = err
+	}
+}
+
+// Unwrap returns the underlying response writer so http.ResponseController can
+// reach optional interfaces such as deadlines and hijacking.
+func (bw *batchWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+// flushTimer sends the buffered batch once the interval has passed.
+func (bw *batchWriter) flushTimer() {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if bw.closed {
+		return
+	}
+	bw.timer = nil
+	if err := bw.flushLocked(); err != nil {
+		bw.err = err
+	}
+}
+
+// close stops the timer once the handler has returned and sends the remaining batch.
+//
+// Parameters:
+//   - send: Whether to send the remaining batch (false if the handler panicked)
+func (bw *batchWriter) close(send bool) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	bw.closed = true
+	if bw.timer != nil {
+		bw.timer.Stop()
+		bw.timer = nil
+	}
+	if send {
+		bw.flushLocked()
+	}
+}
+
+// flushLocked writes the buffered batch to the underlying writer and flushes it.
+// The caller must hold the lock.
+//
+// Returns:
+//   - error: The error of the underlying write
+func (bw *batchWriter) flushLocked() error {
+	if bw.timer != nil {
+		bw.timer.Stop()
+		bw.timer = nil
+	}
+	if bw.buf.Len() == 0 {
+		return nil
+	}
+
+	_, err := bw.ResponseWriter.Write(bw.buf.Bytes())
+	bw.buf.Reset()
+	if err != nil {
+		return err
+	}
+	bw.flushUnderlying()
+	return nil
+}
+
+// writeHeader writes the status to the underlying writer once.
+// The caller must hold the lock.
+func (bw *batchWriter) writeHeader(status int) {
+	if bw.wroteHeader {
+		return
+	}
+	bw.wroteHeader = true
+	bw.ResponseWriter.WriteHeader(status)
+}
+
+// flushUnderlying flushes the underlying writer when it supports flushing.
+// The caller must hold the lock.
+func (bw *batchWriter) flushUnderlying() {
+	if f, ok := bw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
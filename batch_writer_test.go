@@ -0,0 +1,111 @@
+package anvil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingWriter records a response and counts the writes and flushes reaching it.
+type countingWriter struct {
+	*httptest.ResponseRecorder
+	mu      sync.Mutex
+	writes  int
+	flushes int
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.writes++
+	return cw.ResponseRecorder.Write(b)
+}
+
+func (cw *countingWriter) Flush() {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.flushes++
+}
+
+// counts returns the number of writes and flushes so far.
+func (cw *countingWriter) counts() (writes, flushes int) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.writes, cw.flushes
+}
+
+func TestBatchWritesMiddlewareCoalesces(t *testing.T) {
+	const chunks = 1000
+	chunk := `{"done":1}` + "\n"
+	handler := BatchWritesMiddleware(1024, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		for range chunks {
+			w.Write([]byte(chunk))
+		}
+	}))
+
+	cw := &countingWriter{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(cw, httptest.NewRequest(http.MethodGet, "/progress", nil))
+
+	if cw.Code != http.StatusAccepted {
+		t.Errorf("status = %d, want %d", cw.Code, http.StatusAccepted)
+	}
+	if got, want := cw.Body.String(), strings.Repeat(chunk, chunks); got != want {
+		t.Errorf("body has %d bytes, want all %d", len(got), len(want))
+	}
+	writes, flushes := cw.counts()
+	if want := len(chunk) * chunks / 1024; writes < want || writes > want+1 {
+		t.Errorf("underlying writes = %d for %d chunks, want about %d", writes, chunks, want)
+	}
+	if flushes != writes {
+		t.Errorf("flushes = %d, want one per batch (%d)", flushes, writes)
+	}
+}
+
+func TestBatchWritesMiddlewareInterval(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	cw := &countingWriter{ResponseRecorder: httptest.NewRecorder()}
+	handler := BatchWritesMiddleware(1024, interval)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first"))
+		w.Write([]byte("second"))
+		time.Sleep(5 * interval)
+		if writes, _ := cw.counts(); writes != 1 {
+			t.Errorf("writes after the interval = %d, want 1 batch", writes)
+		}
+
+		w.Write([]byte("third"))
+		http.NewResponseController(w).Flush()
+		if writes, flushes := cw.counts(); writes != 2 || flushes != 2 {
+			t.Errorf("after an explicit flush writes = %d, flushes = %d; want 2, 2", writes, flushes)
+		}
+		w.Write([]byte("last"))
+	}))
+
+	handler.ServeHTTP(cw, httptest.NewRequest(http.MethodGet, "/progress", nil))
+	if got := cw.Body.String(); got != "firstsecondthirdlast" {
+		t.Errorf("body = %q, want every chunk", got)
+	}
+	if writes, _ := cw.counts(); writes != 3 {
+		t.Errorf("writes = %d, want the remainder sent on completion", writes)
+	}
+}
+
+func TestBatchWritesMiddlewareLargeWrite(t *testing.T) {
+	cw := &countingWriter{ResponseRecorder: httptest.NewRecorder()}
+	large := strings.Repeat("x", 2048)
+	handler := BatchWritesMiddleware(1024, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("small"))
+		w.Write([]byte(large))
+	}))
+
+	handler.ServeHTTP(cw, httptest.NewRequest(http.MethodGet, "/export", nil))
+	if got := cw.Body.String(); got != "small"+large {
+		t.Errorf("body has %d bytes, want the buffered chunk before the large write", len(got))
+	}
+	if writes, _ := cw.counts(); writes != 2 {
+		t.Errorf("writes = %d, want 2", writes)
+	}
+}
@@ -0,0 +1,211 @@
+package anvil
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arbenlabs/anvil/internal/ttlmap"
+)
+
+// CachedResponse is a response stored by CacheMiddleware.
+type CachedResponse struct {
+	Status int         // The response status code
+	Header http.Header // The response headers
+	Body   []byte      // The response body
+}
+
+// CacheStore stores responses cached by CacheMiddleware.
+// Implementations must be safe for concurrent use. Services running several
+// instances can share a cache by implementing this interface on top of an external
+// store such as Redis.
+type CacheStore interface {
+	// Get returns the cached response for the key, if present and not expired.
+	Get(key string) (*CachedResponse, bool)
+
+	// Set stores the response under the key for the given TTL.
+	Set(key string, response *CachedResponse, ttl time.Duration)
+}
+
+// MemoryCacheStore is an in-memory CacheStore with TTL eviction.
+// Each process keeps its own copy of the cached responses, so a service running
+// several instances renders each response once per instance.
+type MemoryCacheStore struct {
+	entries *ttlmap.Map[*CachedResponse]
+}
+
+// NewMemoryCacheStore creates a new, empty in-memory cache store.
+//
+// Example usage:
+//
+//	cache := CacheMiddleware(NewMemoryCacheStore(), time.Minute, "Accept-Language")
+//
+// Returns:
+//   - *MemoryCacheStore: A new in-memory cache store
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{entries: ttlmap.New[*CachedResponse]()}
+}
+
+// Get returns the cached response for the key, if present and not expired.
+// An expired entry is evicted when it is looked up.
+//
+// Parameters:
+//   - key: The cache key
+//
+// Returns:
+//   - *CachedResponse: The cached response
+//   - bool: true if a fresh response was found, false otherwise
+func (s *MemoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	return s.entries.Get(key)
+}
+
+// Set stores the response under the key for the given TTL.
+// Expired entries are swept at most once per minute during calls to Set.
+//
+// Parameters:
+//   - key: The cache key
+//   - response: The response to store
+//   - ttl: How long the response stays fresh
+func (s *MemoryCacheStore) Set(key string, response *CachedResponse, ttl time.Duration) {
+	s.entries.Set(key, response, time.Now().Add(ttl))
+}
+
+// CacheMiddleware creates middleware that caches GET responses on the server.
+// Read-heavy endpoints can skip the handler entirely while a cached response is fresh.
+// Responses are keyed by the request path and query plus the values of the varyOn
+// request headers, so for example caching with varyOn "Accept-Language" stores one
+// response per language. The varied headers are advertised in the Vary response header.
+//
+// Only GET requests answered with 200 (OK) are cached, and responses that set cookies
+// or carry "Cache-Control: private" or "no-store" are never stored. A cached response
+// is replayed with its original status, headers and body for ttl, and an X-Cache
+// header of HIT or MISS tells whether the cache was used.
+//
+// Clients sending "Cache-Control: no-cache" bypass the cached response and refresh it;
+// clients sending "Cache-Control: no-store" bypass the cache entirely.
+//
+// Requests carrying an Authorization or Cookie header also bypass the cache entirely,
+// since their responses are usually specific to the user (RFC 9111, section 3.5):
+// otherwise one user's GET /me would be served to the next. To cache such responses
+// per credential, list the header in varyOn, which makes it part of the cache key.
+//
+// Example usage:
+//
+//	cache := CacheMiddleware(NewMemoryCacheStore(), 30*time.Second, "Accept-Language")
+//	http.Handle("GET /api/catalog", cache(catalogHandler))
+//
+// Parameters:
+//   - store: The store holding cached responses
+//   - ttl: How long a cached response stays fresh
+//   - varyOn: Request headers whose values are part of the cache key
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that caches GET responses
+func CacheMiddleware(store CacheStore, ttl time.Duration, varyOn ...string) func(http.Handler) http.Handler {
+	vary := make([]string, len(varyOn))
+	for i, name := range varyOn {
+		vary[i] = http.CanonicalHeaderKey(name)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			requestDirectives := strings.ToLower(r.Header.Get("Cache-Control"))
+			if strings.Contains(requestDirectives, "no-store") || hasUnvariedCredentials(r, vary) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := cacheKey(r, vary)
+			if !strings.Contains(requestDirectives, "no-cache") {
+				if cached, ok := store.Get(key); ok {
+					recorder := &bufferedResponse{header: cached.Header.Clone(), status: cached.Status, wroteHeader: true}
+					recorder.body.Write(cached.Body)
+					recorder.header.Set("X-Cache", "HIT")
+					recorder.replay(w)
+					return
+				}
+			}
+
+			recorder := &bufferedResponse{header: make(http.Header)}
+			next.ServeHTTP(recorder, r)
+			if len(vary) > 0 {
+				recorder.header.Set("Vary", strings.Join(vary, ", "))
+			}
+
+			if isCacheable(recorder) {
+				store.Set(key, &CachedResponse{
+					Status: recorder.status,
+					Header: recorder.header.Clone(),
+					Body:   append([]byte(nil), recorder.body.Bytes()...),
+				}, ttl)
+			}
+
+			recorder.header.Set("X-Cache", "MISS")
+			recorder.replay(w)
+		})
+	}
+}
+
+// cacheKey builds the cache key for a request from its path, query and varied headers.
+//
+// Parameters:
+//   - r: The HTTP request
+//   - vary: The canonical names of the varied request headers
+//
+// Returns:
+//   - string: The cache key
+func cacheKey(r *http.Request, vary []string) string {
+	var b strings.Builder
+	b.WriteString(r.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(r.URL.RawQuery)
+	for _, name := range vary {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// hasUnvariedCredentials reports whether the request carries credentials that are not part of the cache key.
+//
+// Parameters:
+//   - r: The HTTP request
+//   - vary: The canonical names of the varied request headers
+//
+// Returns:
+//   - bool: true if Authorization or Cookie is present and not listed in vary
+func hasUnvariedCredentials(r *http.Request, vary []string) bool {
+	for _, name := range []string{"Authorization", "Cookie"} {
+		if r.Header.Get(name) != "" && !slices.Contains(vary, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCacheable reports whether a recorded response may be stored by CacheMiddleware.
+//
+// Parameters:
+//   - recorder: The recorded response
+//
+// Returns:
+//   - bool: true for 200 responses without cookies or private/no-store directives
+func isCacheable(recorder *bufferedResponse) bool {
+	if recorder.status != http.StatusOK {
+		return false
+	}
+	if recorder.header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	directives := strings.ToLower(recorder.header.Get("Cache-Control"))
+	return !strings.Contains(directives, "private") && !strings.Contains(directives, "no-store")
+}
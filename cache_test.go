@@ -0,0 +1,177 @@
+package anvil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// countingHandler answers with the request's Accept-Language and Authorization values and counts its calls.
+type countingHandler struct {
+	calls int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	fmt.Fprintf(w, "lang=%s user=%s call=%d", r.Header.Get("Accept-Language"), r.Header.Get("Authorization"), h.calls)
+}
+
+// cacheGet sends a GET request with the given headers through handler.
+func cacheGet(handler http.Handler, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/catalog?page=1", nil)
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestCacheMiddlewareHit(t *testing.T) {
+	next := &countingHandler{}
+	handler := CacheMiddleware(NewMemoryCacheStore(), time.Minute)(next)
+
+	first := cacheGet(handler, nil)
+	second := cacheGet(handler, nil)
+
+	if next.calls != 1 {
+		t.Fatalf("handler calls = %d, want 1", next.calls)
+	}
+	if got := first.Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("first X-Cache = %q, want MISS", got)
+	}
+	if got := second.Header().Get("X-Cache"); got != "HIT" {
+		t.Errorf("second X-Cache = %q, want HIT", got)
+	}
+	if second.Code != http.StatusOK || second.Body.String() != first.Body.String() {
+		t.Errorf("cached response = %d %q, want %d %q", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+}
+
+func TestCacheMiddlewareVaryMiss(t *testing.T) {
+	next := &countingHandler{}
+	handler := CacheMiddleware(NewMemoryCacheStore(), time.Minute, "accept-language")(next)
+
+	english := cacheGet(handler, map[string]string{"Accept-Language": "en"})
+	german := cacheGet(handler, map[string]string{"Accept-Language": "de"})
+	englishAgain := cacheGet(handler, map[string]string{"Accept-Language": "en"})
+
+	if next.calls != 2 {
+		t.Fatalf("handler calls = %d, want 2", next.calls)
+	}
+	if got := german.Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("X-Cache for another language = %q, want MISS", got)
+	}
+	if englishAgain.Body.String() != english.Body.String() {
+		t.Errorf("cached body = %q, want %q", englishAgain.Body.String(), english.Body.String())
+	}
+	if got := english.Header().Get("Vary"); got != "Accept-Language" {
+		t.Errorf("Vary = %q, want Accept-Language", got)
+	}
+}
+
+func TestCacheMiddlewareDoesNotShareCredentialedResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		userA  string
+		userB  string
+		varyOn []string
+		calls  int
+	}{
+		{name: "authorization", header: "Authorization", userA: "Bearer a", userB: "Bearer b", calls: 2},
+		{name: "cookie", header: "Cookie", userA: "session=a", userB: "session=b", calls: 2},
+		{name: "varied authorization", header: "Authorization", userA: "Bearer a", userB: "Bearer b", varyOn: []string{"Authorization"}, calls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &countingHandler{}
+			handler := CacheMiddleware(NewMemoryCacheStore(), time.Minute, tt.varyOn...)(next)
+
+			a := cacheGet(handler, map[string]string{tt.header: tt.userA})
+			b := cacheGet(handler, map[string]string{tt.header: tt.userB})
+
+			if next.calls != tt.calls {
+				t.Errorf("handler calls = %d, want %d", next.calls, tt.calls)
+			}
+			if b.Body.String() == a.Body.String() {
+				t.Errorf("user B received user A's response %q", a.Body.String())
+			}
+			if got := b.Header().Get("X-Cache"); got == "HIT" {
+				t.Error("user B's response was served from the cache")
+			}
+		})
+	}
+}
+
+func TestCacheMiddlewareCachesPerVariedCredential(t *testing.T) {
+	next := &countingHandler{}
+	handler := CacheMiddleware(NewMemoryCacheStore(), time.Minute, "Authorization")(next)
+
+	cacheGet(handler, map[string]string{"Authorization": "Bearer a"})
+	again := cacheGet(handler, map[string]string{"Authorization": "Bearer a"})
+
+	if next.calls != 1 {
+		t.Errorf("handler calls = %d, want 1", next.calls)
+	}
+	if got := again.Header().Get("X-Cache"); got != "HIT" {
+		t.Errorf("X-Cache = %q, want HIT", got)
+	}
+}
+
+func TestCacheMiddlewareRequestDirectives(t *testing.T) {
+	next := &countingHandler{}
+	handler := CacheMiddleware(NewMemoryCacheStore(), time.Minute)(next)
+
+	cacheGet(handler, nil)
+	refreshed := cacheGet(handler, map[string]string{"Cache-Control": "no-cache"})
+	if next.calls != 2 || refreshed.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("no-cache: handler calls = %d, X-Cache = %q; want 2, MISS", next.calls, refreshed.Header().Get("X-Cache"))
+	}
+
+	cached := cacheGet(handler, nil)
+	if cached.Body.String() != refreshed.Body.String() {
+		t.Errorf("no-cache did not refresh the cached response: got %q, want %q", cached.Body.String(), refreshed.Body.String())
+	}
+
+	bypassed := cacheGet(handler, map[string]string{"Cache-Control": "no-store"})
+	if next.calls != 3 || bypassed.Header().Get("X-Cache") != "" {
+		t.Errorf("no-store: handler calls = %d, X-Cache = %q; want 3, none", next.calls, bypassed.Header().Get("X-Cache"))
+	}
+}
+
+func TestCacheMiddlewareSkipsUncacheableResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "error status", handler: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}},
+		{name: "set-cookie", handler: func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+		}},
+		{name: "private", handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			handler := CacheMiddleware(NewMemoryCacheStore(), time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				tt.handler(w, r)
+			}))
+
+			cacheGet(handler, nil)
+			cacheGet(handler, nil)
+			if calls != 2 {
+				t.Errorf("handler calls = %d, want 2", calls)
+			}
+		})
+	}
+}
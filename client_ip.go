@@ -0,0 +1,149 @@
+package anvil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// trustedProxies holds the proxy networks shared by every middleware that resolves client IPs.
+var trustedProxies atomic.Pointer[[]*net.IPNet]
+
+// SetTrustedProxies sets the proxy networks whose forwarding headers are trusted.
+// The setting is shared by every middleware in this package that needs the client
+// IP (logging, rate limiting, and so on) so they all resolve it consistently. It is
+// safe to call concurrently with requests being served.
+//
+// Requests arriving directly from an address outside these networks are attributed
+// to that address, and their X-Forwarded-For and X-Real-IP headers are ignored, since
+// any client can forge them.
+//
+// Example usage:
+//
+//	proxies, err := ParseTrustedProxies("10.0.0.0/8", "192.168.1.10")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	SetTrustedProxies(proxies)
+//
+// Parameters:
+//   - proxies: The trusted proxy networks (nil or empty trusts no proxies)
+func SetTrustedProxies(proxies []*net.IPNet) {
+	cp := append([]*net.IPNet(nil), proxies...)
+	trustedProxies.Store(&cp)
+}
+
+// TrustedProxies returns the proxy networks currently trusted by ClientIP.
+//
+// Returns:
+//   - []*net.IPNet: A copy of the trusted proxy networks
+func TrustedProxies() []*net.IPNet {
+	if p := trustedProxies.Load(); p != nil {
+		return append([]*net.IPNet(nil), (*p)...)
+	}
+	return nil
+}
+
+// ParseTrustedProxies parses CIDR ranges and bare IP addresses into networks.
+// Bare addresses are treated as single-host networks (/32 for IPv4, /128 for IPv6).
+//
+// Parameters:
+//   - cidrs: CIDR ranges (e.g., "10.0.0.0/8") or IP addresses (e.g., "192.168.1.10")
+//
+// Returns:
+//   - []*net.IPNet: The parsed networks
+//   - error: An error naming the first entry that could not be parsed
+func ParseTrustedProxies(cidrs ...string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", cidr)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ClientIP returns the IP address of the client that originated the request.
+// When the request arrives from a trusted proxy (see SetTrustedProxies), the
+// X-Forwarded-For chain is walked from right to left, skipping trusted proxies, and
+// the first untrusted address is returned. X-Real-IP is used when X-Forwarded-For is
+// absent. Otherwise, the address of the direct peer (r.RemoteAddr) is returned.
+//
+// Example usage:
+//
+//	ip := ClientIP(r)
+//
+// Parameters:
+//   - r: The HTTP request
+//
+// Returns:
+//   - string: The client IP address
+func ClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	proxies := TrustedProxies()
+	if !isTrustedProxy(remote, proxies) {
+		return remote
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			if !isTrustedProxy(hop, proxies) || i == 0 {
+				return hop
+			}
+		}
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+
+	return remote
+}
+
+// isTrustedProxy reports whether the address belongs to one of the trusted networks.
+//
+// Parameters:
+//   - addr: The IP address to check
+//   - proxies: The trusted proxy networks
+//
+// Returns:
+//   - bool: true if the address is a trusted proxy, false otherwise
+func isTrustedProxy(addr string, proxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package anvil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// trustProxies sets the trusted proxies for the duration of the test.
+func trustProxies(t *testing.T, cidrs ...string) {
+	t.Helper()
+	proxies, err := ParseTrustedProxies(cidrs...)
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+	previous := TrustedProxies()
+	SetTrustedProxies(proxies)
+	t.Cleanup(func() { SetTrustedProxies(previous) })
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trusted   []string
+		remote    string
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{name: "direct peer", remote: "203.0.113.7:4321", want: "203.0.113.7"},
+		{name: "untrusted peer ignores headers", remote: "203.0.113.7:4321", forwarded: []string{"198.51.100.1"}, realIP: "198.51.100.2", want: "203.0.113.7"},
+		{name: "trusted proxy", trusted: []string{"10.0.0.0/8"}, remote: "10.0.0.1:4321", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "proxy chain", trusted: []string{"10.0.0.0/8", "192.168.1.10"}, remote: "10.0.0.1:4321", forwarded: []string{"203.0.113.9, 198.51.100.1, 192.168.1.10"}, want: "198.51.100.1"},
+		{name: "repeated headers", trusted: []string{"10.0.0.0/8"}, remote: "10.0.0.1:4321", forwarded: []string{"198.51.100.1", "10.0.0.2"}, want: "198.51.100.1"},
+		{name: "all hops trusted", trusted: []string{"10.0.0.0/8"}, remote: "10.0.0.1:4321", forwarded: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "real ip fallback", trusted: []string{"10.0.0.0/8"}, remote: "10.0.0.1:4321", realIP: "198.51.100.2", want: "198.51.100.2"},
+		{name: "trusted proxy without headers", trusted: []string{"10.0.0.0/8"}, remote: "10.0.0.1:4321", want: "10.0.0.1"},
+		{name: "ipv6 peer", trusted: []string{"::1"}, remote: "[::1]:4321", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trustProxies(t, tt.trusted...)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8", " 192.168.1.10 ", "::1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+	if len(proxies) != 3 || proxies[1].String() != "192.168.1.10/32" || proxies[2].String() != "::1/128" {
+		t.Errorf("ParseTrustedProxies() = %v, want single-host networks for bare addresses", proxies)
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "proxy.internal"} {
+		if _, err := ParseTrustedProxies(invalid); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) error = nil, want an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package anvil
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// errNotShareable marks a coalesced response that waiters must not receive.
+var errNotShareable = errors.New("response cannot be shared")
+
+// SingleflightMiddleware creates middleware that coalesces concurrent identical requests.
+// When many identical requests arrive at once (for example after a cache entry expires),
+// only the first one runs the handler; the others wait for it to finish and receive a
+// copy of the same response. This prevents a stampede of redundant work on expensive
+// endpoints. Executions are coalesced with golang.org/x/sync/singleflight.
+//
+// Requests are considered identical when keyFn returns the same non-empty key for them.
+// Returning an empty key opts a request out of coalescing. The key must capture
+// everything the response depends on (method, path, query and, for per-user responses,
+// the caller's identity), otherwise one client may receive another client's response.
+//
+// The response of the executing request is buffered in memory and replayed to every
+// waiter, so this middleware is unsuitable for streaming or very large responses.
+// Only complete responses are shared: if the executing request is cancelled, writes
+// no status, answers with a 5xx status or panics, waiting requests run the handler
+// themselves instead of receiving its response.
+//
+// Example usage:
+//
+//	byURL := func(r *http.Request) string {
+//	    if r.Method != http.MethodGet {
+//	        return ""
+//	    }
+//	    return r.URL.String()
+//	}
+//	http.Handle("/api/reports", SingleflightMiddleware(byURL)(reportsHandler))
+//
+// Parameters:
+//   - keyFn: Derives the coalescing key for a request (empty to skip coalescing)
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that coalesces identical requests
+func SingleflightMiddleware(keyFn func(*http.Request) string) func(http.Handler) http.Handler {
+	var group singleflight.Group
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFn(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var (
+				leader   bool
+				panicked any
+			)
+			v, err, _ := group.Do(key, func() (_ any, err error) {
+				leader = true
+				recorder := &bufferedResponse{header: make(http.Header)}
+				defer func() {
+					// Keep the panic out of singleflight, which would re-raise it in every waiter.
+					if panicked = recover(); panicked != nil {
+						err = errNotShareable
+					}
+				}()
+
+				next.ServeHTTP(recorder, r)
+				if r.Context().Err() != nil || recorder.status == 0 || recorder.status >= 500 {
+					return recorder, errNotShareable
+				}
+				return recorder, nil
+			})
+
+			if leader {
+				if panicked != nil {
+					panic(panicked)
+				}
+				v.(*bufferedResponse).replay(w)
+				return
+			}
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			v.(*bufferedResponse).replay(w)
+		})
+	}
+}
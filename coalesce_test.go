@@ -0,0 +1,168 @@
+package anvil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// coalesceRun fires n identical requests through SingleflightMiddleware.
+// Request 0 starts first and runs lead; once it is inside the handler the other
+// requests are sent, and lead only runs after all of them have reached the middleware,
+// so they join its flight. follow serves any further handler execution.
+func coalesceRun(t *testing.T, n int, ctxFor func(i int) context.Context, lead, follow http.HandlerFunc) ([]*httptest.ResponseRecorder, int32) {
+	t.Helper()
+
+	var (
+		arrived sync.WaitGroup
+		calls   atomic.Int32
+	)
+	entered := make(chan struct{})
+	arrived.Add(n)
+	keyFn := func(r *http.Request) string {
+		arrived.Done()
+		return r.URL.Path
+	}
+	handler := SingleflightMiddleware(keyFn)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(entered)
+			arrived.Wait()
+			// Give the other requests time to join the flight after computing their key.
+			time.Sleep(50 * time.Millisecond)
+			lead(w, r)
+			return
+		}
+		follow(w, r)
+	}))
+
+	recorders := make([]*httptest.ResponseRecorder, n)
+	var done sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/reports", nil).WithContext(ctxFor(i))
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			defer func() { recover() }()
+			handler.ServeHTTP(recorders[i], r)
+		}()
+		if i == 0 {
+			<-entered
+		}
+	}
+	done.Wait()
+	return recorders, calls.Load()
+}
+
+// background gives every request a live context.
+func background(int) context.Context {
+	return context.Background()
+}
+
+func TestSingleflightMiddlewareRunsHandlerOnce(t *testing.T) {
+	const n = 10
+	recorders, calls := coalesceRun(t, n, background,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Report", "1")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"total":42}`))
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			t.Error("handler ran more than once")
+		},
+	)
+
+	if calls != 1 {
+		t.Fatalf("handler calls = %d, want 1", calls)
+	}
+	for i, rec := range recorders {
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"total":42}` || rec.Header().Get("X-Report") != "1" {
+			t.Errorf("response %d = %d %q %v, want the shared response", i, rec.Code, rec.Body.String(), rec.Header())
+		}
+	}
+}
+
+func TestSingleflightMiddlewareDoesNotShareIncompleteResponses(t *testing.T) {
+	const n = 5
+	follow := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("own"))
+	}
+
+	tests := []struct {
+		name   string
+		ctxFor func(i int) context.Context
+		lead   http.HandlerFunc
+	}{
+		{
+			name:   "5xx",
+			ctxFor: background,
+			lead: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+		},
+		{
+			name:   "no status",
+			ctxFor: background,
+			lead:   func(w http.ResponseWriter, r *http.Request) {},
+		},
+		{
+			name:   "panic",
+			ctxFor: background,
+			lead: func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			},
+		},
+		{
+			name: "cancelled leader",
+			ctxFor: func(i int) context.Context {
+				if i > 0 {
+					return context.Background()
+				}
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			lead: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("partial"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorders, calls := coalesceRun(t, n, tt.ctxFor, tt.lead, follow)
+
+			if calls != n {
+				t.Errorf("handler calls = %d, want %d", calls, n)
+			}
+			own := 0
+			for _, rec := range recorders {
+				if rec.Body.String() == "own" {
+					own++
+				}
+			}
+			if own != n-1 {
+				t.Errorf("%d waiters ran the handler themselves, want %d", own, n-1)
+			}
+		})
+	}
+}
+
+func TestSingleflightMiddlewareEmptyKey(t *testing.T) {
+	var calls atomic.Int32
+	handler := SingleflightMiddleware(func(r *http.Request) string { return "" })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.Itoa(int(calls.Add(1)))))
+	}))
+
+	for range 3 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/reports", nil))
+	}
+	if calls.Load() != 3 {
+		t.Errorf("handler calls = %d, want 3", calls.Load())
+	}
+}
@@ -0,0 +1,64 @@
+package anvil
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// ContextLoggerMiddleware creates middleware that stores a request-scoped logger in the context.
+// The logger is derived from base and pre-populated with the request's method and path,
+// plus its request ID when RequestIDMiddleware runs earlier in the chain, so every log
+// line a handler emits through LoggerFromContext can be correlated with the request.
+//
+// Example usage:
+//
+//	handler := RequestIDMiddleware(RequestIDOptions{})(
+//	    ContextLoggerMiddleware(slog.Default())(router),
+//	)
+//
+//	func getUser(w http.ResponseWriter, r *http.Request) error {
+//	    LoggerFromContext(r.Context()).Info("loading user", "user_id", id)
+//	    // logs: ... request_id=... method=GET path=/api/users/42 user_id=42
+//	}
+//
+// Parameters:
+//   - base: The logger to derive request loggers from (slog.Default() if nil)
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that stores a request-scoped logger
+func ContextLoggerMiddleware(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := base
+			if logger == nil {
+				logger = slog.Default()
+			}
+
+			attrs := make([]any, 0, 6)
+			if id := RequestIDFromContext(r.Context()); id != "" {
+				attrs = append(attrs, "request_id", id)
+			}
+			attrs = append(attrs, "method", r.Method, "path", r.URL.Path)
+
+			ctx := context.WithValue(r.Context(), loggerContextKey, logger.With(attrs...))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// LoggerFromContext returns the request-scoped logger stored by ContextLoggerMiddleware.
+// When the middleware did not run, slog.Default() is returned, so callers can always
+// log through the result.
+//
+// Parameters:
+//   - ctx: The request context
+//
+// Returns:
+//   - *slog.Logger: The request-scoped logger, or slog.Default()
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,44 @@
+package anvil
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContextLoggerMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(slog.NewTextHandler(&buf, nil))
+	handler := RequestIDMiddleware(RequestIDOptions{})(ContextLoggerMiddleware(base)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context()).Info("loading user")
+	})))
+
+	r := httptest.NewRequest(http.MethodGet, "/users/123", nil)
+	r.Header.Set(DefaultRequestIDHeader, "req-123")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	out := buf.String()
+	for _, attr := range []string{"msg=\"loading user\"", "request_id=req-123", "method=GET", "path=/users/123"} {
+		if !strings.Contains(out, attr) {
+			t.Errorf("log = %q, want %s", out, attr)
+		}
+	}
+}
+
+func TestContextLoggerMiddlewareDefaults(t *testing.T) {
+	logs := captureLogs(t)
+	ContextLoggerMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context()).Info("loading user")
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+
+	if out := logs.String(); !strings.Contains(out, "path=/users/123") || strings.Contains(out, "request_id") {
+		t.Errorf("log = %q, want the default logger with the path and no request ID", out)
+	}
+	if LoggerFromContext(context.Background()) != slog.Default() {
+		t.Error("LoggerFromContext(without logger) != slog.Default()")
+	}
+}
@@ -0,0 +1,150 @@
+package anvil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/arbenlabs/anvil/tools"
+)
+
+const (
+	// DefaultCSRFCookieName is the default name of the cookie holding the CSRF token.
+	DefaultCSRFCookieName = "csrf_token"
+
+	// DefaultCSRFHeaderName is the default request header that must echo the CSRF token.
+	DefaultCSRFHeaderName = "X-CSRF-Token"
+
+	// csrfTokenBytes is the number of random bytes in a generated CSRF token.
+	csrfTokenBytes = 32
+)
+
+// CSRFOptions configures the CSRF protection middleware.
+// Zero values are replaced with sensible defaults.
+type CSRFOptions struct {
+	CookieName string        // Name of the token cookie (defaults to DefaultCSRFCookieName)
+	HeaderName string        // Header that must echo the token (defaults to DefaultCSRFHeaderName)
+	Path       string        // Cookie path (defaults to "/")
+	Domain     string        // Optional cookie domain
+	MaxAge     int           // Cookie max age in seconds (0 for a session cookie)
+	Secure     bool          // Whether the cookie is only sent over HTTPS
+	SameSite   http.SameSite // Cookie SameSite mode (defaults to http.SameSiteLaxMode)
+}
+
+// CSRFMiddleware creates middleware that protects cookie-authenticated requests against CSRF.
+// This middleware implements the double-submit-cookie pattern: it issues a random token
+// in a cookie, and requires unsafe requests (any method other than GET, HEAD, OPTIONS and
+// TRACE) to echo the same token in a header. Because other origins can neither read the
+// cookie nor set custom headers on cross-site requests, a forged request cannot supply
+// the matching header.
+//
+// The middleware:
+//   - Issues a token cookie when the request does not carry one
+//   - Exposes the current token to handlers via CSRFToken (e.g., for rendering forms)
+//   - Rejects unsafe requests whose header token is missing or does not match the cookie
+//     with a 403 (Forbidden) JSON error, comparing in constant time
+//   - Exempts requests authenticated with an "Authorization: Bearer" header, since browsers
+//     never attach that header automatically
+//
+// The token cookie is deliberately not HttpOnly so that frontend code can read it and
+// copy it into the header.
+//
+// Example usage:
+//
+//	csrf := CSRFMiddleware(CSRFOptions{Secure: true})
+//	http.Handle("/api/", csrf(apiHandler))
+//
+//	// Frontend: fetch("/api/items", {method: "POST", headers: {"X-CSRF-Token": getCookie("csrf_token")}})
+//
+// Parameters:
+//   - opts: The CSRF configuration
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces CSRF protection
+func CSRFMiddleware(opts CSRFOptions) func(http.Handler) http.Handler {
+	if opts.CookieName == "" {
+		opts.CookieName = DefaultCSRFCookieName
+	}
+	if opts.HeaderName == "" {
+		opts.HeaderName = DefaultCSRFHeaderName
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := bearerToken(r.Header.Get("Authorization")); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := ""
+			if cookie, err := r.Cookie(opts.CookieName); err == nil {
+				token = cookie.Value
+			}
+
+			if !isSafeMethod(r.Method) {
+				header := r.Header.Get(opts.HeaderName)
+				if token == "" || header == "" || !tools.SecureCompare(header, token) {
+					writeError(w, http.StatusForbidden, errors.New("invalid or missing csrf token"))
+					return
+				}
+			}
+
+			if token == "" {
+				generated, err := tools.GenerateSecureToken(csrfTokenBytes)
+				if err != nil {
+					writeError(w, http.StatusInternalServerError, errors.New("unable to generate csrf token"))
+					return
+				}
+				token = generated
+				http.SetCookie(w, &http.Cookie{
+					Name:     opts.CookieName,
+					Value:    token,
+					Path:     opts.Path,
+					Domain:   opts.Domain,
+					MaxAge:   opts.MaxAge,
+					Secure:   opts.Secure,
+					SameSite: opts.SameSite,
+				})
+			}
+
+			ctx := context.WithValue(r.Context(), csrfContextKey, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// CSRFToken returns the CSRF token for the current request, as stored by CSRFMiddleware.
+// Handlers can embed this token in rendered forms or return it to clients that cannot
+// read cookies.
+//
+// Parameters:
+//   - ctx: The request context
+//
+// Returns:
+//   - string: The CSRF token, or an empty string if CSRFMiddleware did not run
+func CSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfContextKey).(string)
+	return token
+}
+
+// isSafeMethod reports whether the method is considered safe (read-only) by RFC 9110.
+//
+// Parameters:
+//   - method: The HTTP method
+//
+// Returns:
+//   - bool: true for GET, HEAD, OPTIONS and TRACE, false otherwise
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package anvil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	const token = "csrf-token-value"
+
+	tests := []struct {
+		name   string
+		method string
+		setup  func(r *http.Request)
+		status int
+	}{
+		{name: "matching token", method: http.MethodPost, setup: func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: DefaultCSRFCookieName, Value: token})
+			r.Header.Set(DefaultCSRFHeaderName, token)
+		}, status: http.StatusOK},
+		{name: "missing header", method: http.MethodPost, setup: func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: DefaultCSRFCookieName, Value: token})
+		}, status: http.StatusForbidden},
+		{name: "mismatched header", method: http.MethodDelete, setup: func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: DefaultCSRFCookieName, Value: token})
+			r.Header.Set(DefaultCSRFHeaderName, "forged")
+		}, status: http.StatusForbidden},
+		{name: "missing cookie", method: http.MethodPost, setup: func(r *http.Request) {
+			r.Header.Set(DefaultCSRFHeaderName, token)
+		}, status: http.StatusForbidden},
+		{name: "bearer token exempt", method: http.MethodPost, setup: func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer api-token")
+		}, status: http.StatusOK},
+		{name: "safe method", method: http.MethodGet, setup: func(r *http.Request) {}, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/account", nil)
+			tt.setup(r)
+			if rec := record(CSRFMiddleware(CSRFOptions{})(statusHandler(http.StatusOK)), r); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestCSRFMiddlewareIssuesToken(t *testing.T) {
+	var seen string
+	handler := CSRFMiddleware(CSRFOptions{Secure: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = CSRFToken(r.Context())
+	}))
+
+	rec := record(handler, httptest.NewRequest(http.MethodGet, "/account", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DefaultCSRFCookieName {
+		t.Fatalf("cookies = %v, want a %s cookie", cookies, DefaultCSRFCookieName)
+	}
+	if cookies[0].Value == "" || cookies[0].Value != seen {
+		t.Errorf("CSRFToken() = %q, want the issued cookie value %q", seen, cookies[0].Value)
+	}
+	if !cookies[0].Secure || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie = %+v, want Secure and SameSite=Lax", cookies[0])
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/account", nil)
+	r.AddCookie(cookies[0])
+	r.Header.Set(DefaultCSRFHeaderName, seen)
+	if rec := record(handler, r); rec.Code != http.StatusOK || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("status = %d with cookies %v, want %d reusing the cookie", rec.Code, rec.Result().Cookies(), http.StatusOK)
+	}
+}
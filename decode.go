@@ -0,0 +1,190 @@
+package anvil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is the default request body size limit used by the decode helpers
+// when a non-positive limit is given.
+const DefaultMaxBodyBytes int64 = 1 << 20 // 1 MiB
+
+// DefaultMaxStreamElements is the element limit used by StreamDecodeArray.
+const DefaultMaxStreamElements = 100_000
+
+// Validator is implemented by request types that can validate themselves.
+// The decode helpers call Validate on each decoded value (through a pointer if
+// the method has a pointer receiver) and report the returned error.
+type Validator interface {
+	Validate() error
+}
+
+// SliceValidationError reports which elements of a decoded JSON array failed validation.
+// The Errors map is keyed by the zero-based index of each failing element, so bulk
+// endpoints can tell clients exactly which items must be corrected.
+type SliceValidationError struct {
+	Errors map[int]error // Validation error for each failing element, keyed by index
+}
+
+// Error returns a message listing every failing index and its error, ordered by index.
+func (e *SliceValidationError) Error() string {
+	indexes := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	parts := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		parts = append(parts, fmt.Sprintf("[%d]: %s", i, e.Errors[i].Error()))
+	}
+	return fmt.Sprintf("%d element(s) failed validation: %s", len(indexes), strings.Join(parts, "; "))
+}
+
+// DecodeAndValidateSlice decodes a JSON array request body and validates every element.
+// This function is intended for bulk endpoints that accept arrays of objects. The body
+// is limited to maxBytes (DefaultMaxBodyBytes if <= 0), unknown fields are rejected,
+// and every element implementing Validator is validated.
+//
+// When one or more elements fail validation, the function returns a
+// *SliceValidationError listing the index and error of each failing element, so the
+// client can correct all of them at once rather than one per round trip.
+//
+// Example usage:
+//
+//	func createUsers(w http.ResponseWriter, r *http.Request) error {
+//	    users, err := DecodeAndValidateSlice[CreateUserRequest](r, 0)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    // all elements are valid
+//	}
+//
+// Parameters:
+//   - r: The HTTP request whose body contains a JSON array
+//   - maxBytes: The maximum body size in bytes (DefaultMaxBodyBytes if <= 0)
+//
+// Returns:
+//   - []T: The decoded elements
+//   - error: Any decoding error, or a *SliceValidationError if elements failed validation
+func DecodeAndValidateSlice[T any](r *http.Request, maxBytes int64) ([]T, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBytes))
+	decoder.DisallowUnknownFields()
+
+	var items []T
+	if err := decoder.Decode(&items); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("request body must not exceed %d bytes", maxBytes)
+		}
+		return nil, fmt.Errorf("invalid JSON array: %w", err)
+	}
+
+	failures := make(map[int]error)
+	for i := range items {
+		if err := validateValue(&items[i]); err != nil {
+			failures[i] = err
+		}
+	}
+	if len(failures) > 0 {
+		return nil, &SliceValidationError{Errors: failures}
+	}
+
+	return items, nil
+}
+
+// StreamDecodeArray decodes a JSON array element by element, calling fn for each one.
+// Unlike DecodeAndValidateSlice, the array is never held in memory as a whole: the
+// decoder reads one element at a time with json.Decoder.Token and More, which makes
+// this suitable for ingesting very large payloads. Processing stops at the first error
+// returned by fn, which is returned unchanged. Arrays with more than
+// DefaultMaxStreamElements elements are rejected; use StreamDecodeArrayLimit to choose
+// a different limit.
+//
+// Example usage:
+//
+//	func importEvents(w http.ResponseWriter, r *http.Request) error {
+//	    return StreamDecodeArray(r.Body, func(e Event) error {
+//	        return store.Insert(r.Context(), e)
+//	    })
+//	}
+//
+// Parameters:
+//   - r: The reader containing a JSON array
+//   - fn: The function called with each decoded element
+//
+// Returns:
+//   - error: Any decoding error, the first error returned by fn, or an error if the element limit is exceeded
+func StreamDecodeArray[T any](r io.Reader, fn func(T) error) error {
+	return StreamDecodeArrayLimit(r, DefaultMaxStreamElements, fn)
+}
+
+// StreamDecodeArrayLimit decodes a JSON array element by element like StreamDecodeArray,
+// rejecting arrays with more than maxElements elements.
+//
+// Parameters:
+//   - r: The reader containing a JSON array
+//   - maxElements: The maximum number of elements (DefaultMaxStreamElements if <= 0)
+//   - fn: The function called with each decoded element
+//
+// Returns:
+//   - error: Any decoding error, the first error returned by fn, or an error if the element limit is exceeded
+func StreamDecodeArrayLimit[T any](r io.Reader, maxElements int, fn func(T) error) error {
+	if maxElements <= 0 {
+		maxElements = DefaultMaxStreamElements
+	}
+
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("invalid JSON array: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return errors.New("invalid JSON array: expected '['")
+	}
+
+	for count := 0; decoder.More(); count++ {
+		if count >= maxElements {
+			return fmt.Errorf("JSON array must not exceed %d elements", maxElements)
+		}
+
+		var item T
+		if err := decoder.Decode(&item); err != nil {
+			return fmt.Errorf("invalid JSON array element %d: %w", count, err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("invalid JSON array: %w", err)
+	}
+	return nil
+}
+
+// validateValue calls Validate on the value if it, or its pointer, implements Validator.
+//
+// Parameters:
+//   - v: A pointer to the value to validate
+//
+// Returns:
+//   - error: The validation error, or nil if the value is valid or not a Validator
+func validateValue[T any](v *T) error {
+	if validator, ok := any(v).(Validator); ok {
+		return validator.Validate()
+	}
+	if validator, ok := any(*v).(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package anvil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// createUserRequest is a bulk-endpoint element used by the decode tests.
+type createUserRequest struct {
+	Email string `json:"email"`
+}
+
+// Validate requires an email address.
+func (u createUserRequest) Validate() error {
+	if !strings.Contains(u.Email, "@") {
+		return errors.New("email is invalid")
+	}
+	return nil
+}
+
+// jsonRequest builds a POST request with the given JSON body.
+func jsonRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestDecodeAndValidateSlice(t *testing.T) {
+	users, err := DecodeAndValidateSlice[createUserRequest](jsonRequest(`[{"email":"a@example.com"},{"email":"b@example.com"}]`), 0)
+	if err != nil {
+		t.Fatalf("DecodeAndValidateSlice() error = %v", err)
+	}
+	if len(users) != 2 || users[1].Email != "b@example.com" {
+		t.Errorf("DecodeAndValidateSlice() = %+v, want both users", users)
+	}
+}
+
+func TestDecodeAndValidateSliceReportsFailingIndexes(t *testing.T) {
+	body := `[{"email":"a@example.com"},{"email":"invalid"},{"email":"c@example.com"},{"email":""}]`
+	_, err := DecodeAndValidateSlice[createUserRequest](jsonRequest(body), 0)
+
+	var sliceErr *SliceValidationError
+	if !errors.As(err, &sliceErr) {
+		t.Fatalf("DecodeAndValidateSlice() error = %v, want a *SliceValidationError", err)
+	}
+	if len(sliceErr.Errors) != 2 || sliceErr.Errors[1] == nil || sliceErr.Errors[3] == nil {
+		t.Errorf("failing indexes = %v, want 1 and 3", sliceErr.Errors)
+	}
+	if want := "2 element(s) failed validation: [1]: email is invalid; [3]: email is invalid"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestDecodeAndValidateSliceRejectsInvalidBodies(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		maxBytes int64
+	}{
+		{name: "object", body: `{"email":"a@example.com"}`},
+		{name: "unknown field", body: `[{"email":"a@example.com","admin":true}]`},
+		{name: "too large", body: `[{"email":"a@example.com"}]`, maxBytes: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeAndValidateSlice[createUserRequest](jsonRequest(tt.body), tt.maxBytes)
+			var sliceErr *SliceValidationError
+			if err == nil || errors.As(err, &sliceErr) {
+				t.Errorf("DecodeAndValidateSlice() error = %v, want a decoding error", err)
+			}
+		})
+	}
+}
+
+func TestStreamDecodeArray(t *testing.T) {
+	var body strings.Builder
+	body.WriteString("[")
+	for i := range 10_000 {
+		if i > 0 {
+			body.WriteString(",")
+		}
+		fmt.Fprintf(&body, `{"email":"user%d@example.com"}`, i)
+	}
+	body.WriteString("]")
+
+	count := 0
+	err := StreamDecodeArray(strings.NewReader(body.String()), func(u createUserRequest) error {
+		if want := fmt.Sprintf("user%d@example.com", count); u.Email != want {
+			t.Fatalf("element %d = %q, want %q", count, u.Email, want)
+		}
+		count++
+		return nil
+	})
+	if err != nil || count != 10_000 {
+		t.Errorf("StreamDecodeArray() = %v after %d elements, want nil after 10000", err, count)
+	}
+}
+
+func TestStreamDecodeArrayStopsOnCallbackError(t *testing.T) {
+	errStop := errors.New("duplicate email")
+	count := 0
+	err := StreamDecodeArray(strings.NewReader(`[{"email":"a"},{"email":"b"},{"email":"c"}]`), func(u createUserRequest) error {
+		count++
+		if u.Email == "b" {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) || count != 2 {
+		t.Errorf("StreamDecodeArray() = %v after %d elements, want %v after 2", err, count, errStop)
+	}
+}
+
+func TestStreamDecodeArrayLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		limit int
+		ok    bool
+	}{
+		{name: "at limit", body: `[1,2,3]`, limit: 3, ok: true},
+		{name: "over limit", body: `[1,2,3,4]`, limit: 3},
+		{name: "empty", body: `[]`, limit: 3, ok: true},
+		{name: "not an array", body: `{"a":1}`, limit: 3},
+		{name: "invalid element", body: `[1,"two"]`, limit: 3},
+		{name: "truncated", body: `[1,2`, limit: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := StreamDecodeArrayLimit(strings.NewReader(tt.body), tt.limit, func(int) error { return nil })
+			if (err == nil) != tt.ok {
+				t.Errorf("StreamDecodeArrayLimit() error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
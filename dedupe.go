@@ -0,0 +1,151 @@
+package anvil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/arbenlabs/anvil/internal/ttlmap"
+)
+
+// DedupeStore remembers request fingerprints seen by DedupeMiddleware.
+// Implementations must be safe for concurrent use, and Add must check and record a
+// key atomically so that two concurrent duplicates cannot both pass. Services
+// running several instances need a shared implementation (e.g., Redis SET NX).
+type DedupeStore interface {
+	// Add records the key for the given TTL. It returns false if the key is already recorded and not expired.
+	Add(key string, ttl time.Duration) bool
+
+	// Remove forgets the key, so the same request may be submitted again.
+	Remove(key string)
+}
+
+// MemoryDedupeStore is an in-memory DedupeStore with TTL eviction.
+// Fingerprints are only remembered by the process that saw them, so duplicates sent
+// to different instances behind a load balancer are not detected.
+type MemoryDedupeStore struct {
+	keys *ttlmap.Map[struct{}]
+}
+
+// NewMemoryDedupeStore creates a new, empty in-memory dedupe store.
+//
+// Example usage:
+//
+//	dedupe := DedupeMiddleware(NewMemoryDedupeStore(), 10*time.Second)
+//
+// Returns:
+//   - *MemoryDedupeStore: A new in-memory dedupe store
+func NewMemoryDedupeStore() *MemoryDedupeStore {
+	return &MemoryDedupeStore{keys: ttlmap.New[struct{}]()}
+}
+
+// Add records the key for the given TTL unless it is already recorded and not expired.
+// Expired keys are swept at most once per minute during calls to Add.
+//
+// Parameters:
+//   - key: The request fingerprint
+//   - ttl: How long the key is remembered
+//
+// Returns:
+//   - bool: true if the key was recorded, false if it was already present
+func (s *MemoryDedupeStore) Add(key string, ttl time.Duration) bool {
+	return s.keys.Add(key, struct{}{}, time.Now().Add(ttl))
+}
+
+// Remove forgets the key.
+//
+// Parameters:
+//   - key: The request fingerprint
+func (s *MemoryDedupeStore) Remove(key string) {
+	s.keys.Delete(key)
+}
+
+// DedupeMiddleware creates middleware that rejects duplicate submissions of the same request body.
+// Double-clicked submit buttons and client retries can create the same resource twice
+// even when clients send no Idempotency-Key header. This middleware fingerprints
+// unsafe requests (POST, PUT, PATCH, DELETE, ...) by a SHA-256 hash of their method,
+// path, query, client and body, and rejects a repeated fingerprint within ttl with a 409
+// (Conflict) JSON error.
+//
+// The client is the authenticated user when JWTAuthMiddleware has run, and the client
+// IP (see ClientIP) otherwise, so two users sending the same body do not collide.
+// The body is read up to DefaultMaxBodyBytes (larger bodies receive a 413) and
+// restored, so the next handler reads it as usual. Unless the handler explicitly
+// writes a 2xx status, the fingerprint is removed again so the client can retry a
+// failed submission; this includes handlers that panic, write no status or stop
+// early because the request was cancelled.
+//
+// Example usage:
+//
+//	dedupe := DedupeMiddleware(NewMemoryDedupeStore(), 10*time.Second)
+//	http.Handle("POST /api/orders", dedupe(createOrderHandler))
+//
+// Parameters:
+//   - store: The store remembering request fingerprints
+//   - ttl: How long a submission blocks identical submissions
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that rejects duplicate submissions
+func DedupeMiddleware(store DedupeStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, DefaultMaxBodyBytes))
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					writeError(w, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+					return
+				}
+				writeError(w, http.StatusBadRequest, errors.New("unable to read request body"))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key := dedupeKey(r, body)
+			if !store.Add(key, ttl) {
+				writeError(w, http.StatusConflict, errors.New("duplicate request"))
+				return
+			}
+
+			sw := newStatusWriter(w)
+			defer func() {
+				if !sw.wroteHeader || sw.status < 200 || sw.status >= 300 {
+					store.Remove(key)
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// dedupeKey fingerprints a request by its method, path, query, client and body.
+//
+// Parameters:
+//   - r: The HTTP request
+//   - body: The request body
+//
+// Returns:
+//   - string: The hex-encoded SHA-256 fingerprint
+func dedupeKey(r *http.Request, body []byte) string {
+	client := ClientIP(r)
+	if claims, ok := ClaimsFromContext(r.Context()); ok && claims.ID != "" {
+		client = "user:" + claims.ID
+	}
+
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery, client} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,114 @@
+package anvil
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dedupePost sends a POST request with the given target and body through handler.
+func dedupePost(handler http.Handler, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestDedupeMiddlewareRejectsDuplicate(t *testing.T) {
+	calls := 0
+	handler := DedupeMiddleware(NewMemoryDedupeStore(), time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	first := dedupePost(handler, "/orders", `{"sku":"a"}`)
+	second := dedupePost(handler, "/orders", `{"sku":"a"}`)
+
+	if first.Code != http.StatusCreated {
+		t.Errorf("first status = %d, want %d", first.Code, http.StatusCreated)
+	}
+	if second.Code != http.StatusConflict {
+		t.Errorf("duplicate status = %d, want %d", second.Code, http.StatusConflict)
+	}
+	if calls != 1 {
+		t.Errorf("handler calls = %d, want 1", calls)
+	}
+}
+
+func TestDedupeMiddlewareAllowsDistinctRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{name: "body", target: "/orders", body: `{"sku":"b"}`},
+		{name: "query", target: "/orders?dry_run=true", body: `{"sku":"a"}`},
+		{name: "path", target: "/carts", body: `{"sku":"a"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := DedupeMiddleware(NewMemoryDedupeStore(), time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			}))
+
+			dedupePost(handler, "/orders", `{"sku":"a"}`)
+			if rec := dedupePost(handler, tt.target, tt.body); rec.Code != http.StatusCreated {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+			}
+		})
+	}
+}
+
+func TestDedupeMiddlewareForgetsFailedRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "error status", handler: func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, http.StatusBadRequest, errors.New("bad input"))
+		}},
+		{name: "no status", handler: func(w http.ResponseWriter, r *http.Request) {}},
+		{name: "panic", handler: func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryDedupeStore()
+			handler := DedupeMiddleware(store, time.Minute)(tt.handler)
+
+			func() {
+				defer func() { recover() }()
+				dedupePost(handler, "/orders", `{"sku":"a"}`)
+			}()
+
+			retry := DedupeMiddleware(store, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			}))
+			if rec := dedupePost(retry, "/orders", `{"sku":"a"}`); rec.Code != http.StatusCreated {
+				t.Errorf("retry status = %d, want %d", rec.Code, http.StatusCreated)
+			}
+		})
+	}
+}
+
+func TestDedupeMiddlewareRestoresBody(t *testing.T) {
+	var got string
+	handler := DedupeMiddleware(NewMemoryDedupeStore(), time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading body: %v", err)
+		}
+		got = string(body)
+	}))
+
+	dedupePost(handler, "/orders", `{"sku":"a"}`)
+	if got != `{"sku":"a"}` {
+		t.Errorf("body = %q, want the original body", got)
+	}
+}
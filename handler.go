@@ -1,11 +1,47 @@
 package anvil
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
+// bufferedJSON reports whether JSON responses are encoded in full before anything is written.
+var bufferedJSON atomic.Bool
+
+// SetBufferedJSON switches JSON responses of this package between streaming and buffered encoding.
+// By default JSON is encoded straight into the response, so Content-Length is never
+// set and responses are sent with chunked transfer encoding, which some strict
+// clients and proxies reject. When enabled, RespondWithSuccess, RespondWithError and
+// the other JSON responders encode the value into a buffer first, set Content-Length
+// and only then write the status and body.
+//
+// Buffering also makes encoding failures recoverable: a value that cannot be
+// marshalled (e.g., a channel or NaN) is answered with a 500 (Internal Server Error)
+// JSON error instead of a truncated body behind an already sent success status.
+// It is safe to call concurrently with requests being served, but is meant to be set
+// once at startup.
+//
+// Example usage:
+//
+//	SetBufferedJSON(true)
+//
+// Parameters:
+//   - enabled: true for buffered responses with Content-Length, false for streaming
+func SetBufferedJSON(enabled bool) {
+	bufferedJSON.Store(enabled)
+}
+
 // APIFunc represents a function signature for HTTP handlers that return errors.
 // This type is used to standardize error handling across all API endpoints.
 // Functions implementing this signature should handle the HTTP request and return
@@ -18,6 +54,15 @@ type APIFunc func(http.ResponseWriter, *http.Request) error
 // When the wrapped function returns an error, it automatically calls RespondWithError
 // to send a properly formatted JSON error response to the client.
 //
+// If the request context has been cancelled by the time the wrapped function
+// returns (for example, because the client disconnected), the error response is
+// not written, since there is nobody left to receive it and writing to the dead
+// connection only produces noise. The error is logged instead.
+//
+// Every returned error is passed to the reporter set with SetErrorReporter, together
+// with the request metadata. The cause of an *InternalError is logged with the
+// request ID, method and path, while the client only receives a generic message.
+//
 // Example usage:
 //
 //	http.HandleFunc("/api/users", HandlerFunc(createUserHandler))
@@ -30,15 +75,60 @@ type APIFunc func(http.ResponseWriter, *http.Request) error
 func HandlerFunc(f APIFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
-			RespondWithError(w, err)
+			ReportError(r, err)
+			if ctxErr := r.Context().Err(); ctxErr != nil {
+				slog.Info(
+					"client went away before error response was written",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"error", err.Error(),
+					"context_error", ctxErr.Error(),
+				)
+				return
+			}
+			respondWithError(w, err, LoggerFromContext(r.Context()).With(
+				"request_id", RequestIDFromContext(r.Context()),
+				"method", r.Method,
+				"path", r.URL.Path,
+			))
 		}
 	}
 }
 
+// HandlerFuncWith converts an APIFunc to an http.Handler wrapped in route-specific middleware.
+// The APIFunc is first wrapped with HandlerFunc, so returned errors are formatted as
+// usual, and the middleware are then applied around it. The first middleware is the
+// outermost one and sees the request first, matching the order in which they are listed.
+//
+// Example usage:
+//
+//	login := NewRateLimiter(rate.Limit(1), 5)
+//	router.Route(http.MethodPost, "/api/login",
+//	    HandlerFuncWith(loginHandler, RequireContentType("application/json"), login.Handler))
+//
+// Parameters:
+//   - f: The APIFunc to wrap with error handling
+//   - mw: The middleware to apply around the handler, outermost first
+//
+// Returns:
+//   - http.Handler: The handler wrapped in error handling and the middleware
+func HandlerFuncWith(f APIFunc, mw ...func(http.Handler) http.Handler) http.Handler {
+	var h http.Handler = HandlerFunc(f)
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
 // writeJSON is a helper function that writes JSON data to an HTTP response.
 // It sets the appropriate Content-Type header and writes the response with the given status code.
 // This function is used internally by RespondWithError and RespondWithSuccess.
 //
+// Write failures are logged: failures caused by the client going away (broken pipe,
+// connection reset, closed connection or cancelled request) are logged at debug level,
+// since they are not actionable, and all other failures at error level. With
+// SetBufferedJSON enabled the response is buffered and sent with Content-Length.
+//
 // Parameters:
 //   - w: The HTTP response writer
 //   - status: The HTTP status code to return
@@ -47,20 +137,100 @@ func HandlerFunc(f APIFunc) http.HandlerFunc {
 // Returns:
 //   - error: Any error that occurred during JSON encoding or writing
 func writeJSON(w http.ResponseWriter, status int, v any) error {
-	w.Header().Add("Content-Type", "application/json")
+	return writeJSONAs(w, status, "application/json", v)
+}
+
+// writeJSONAs writes JSON data to an HTTP response with the given Content-Type.
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - status: The HTTP status code to return
+//   - contentType: The media type of the response (e.g., "application/problem+json")
+//   - v: The data to encode as JSON
+//
+// Returns:
+//   - error: Any error that occurred during JSON encoding or writing
+func writeJSONAs(w http.ResponseWriter, status int, contentType string, v any) error {
+	if bufferedJSON.Load() {
+		return writeBufferedJSON(w, status, contentType, v)
+	}
+
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
-	return json.NewEncoder(w).Encode(v)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		if isClientDisconnect(err) {
+			slog.Debug("client disconnected while writing response", "status", status, "error", err.Error())
+		} else {
+			slog.Error("unable to write response", "status", status, "error", err.Error())
+		}
+	}
+	return err
+}
+
+// writeBufferedJSON encodes JSON data into a buffer and writes it with Content-Length.
+// Nothing is written before encoding succeeds; if it fails, a 500 (Internal Server
+// Error) JSON error is written instead and the encoding error is returned.
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - status: The HTTP status code to return
+//   - contentType: The media type of the response
+//   - v: The data to encode as JSON
+//
+// Returns:
+//   - error: Any error that occurred during JSON encoding or writing
+func writeBufferedJSON(w http.ResponseWriter, status int, contentType string, v any) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		slog.Error("unable to encode response", "status", status, "error", err.Error())
+		writeError(w, http.StatusInternalServerError, errors.New(InternalErrorMessage))
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+
+	_, err := w.Write(buf.Bytes())
+	if err != nil {
+		if isClientDisconnect(err) {
+			slog.Debug("client disconnected while writing response", "status", status, "error", err.Error())
+		} else {
+			slog.Error("unable to write response", "status", status, "error", err.Error())
+		}
+	}
+	return err
+}
+
+// isClientDisconnect reports whether an error was caused by the client going away.
+//
+// Parameters:
+//   - err: The error returned while writing a response
+//
+// Returns:
+//   - bool: true for broken pipes, connection resets, closed connections and cancelled contexts
+func isClientDisconnect(err error) bool {
+	return errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, context.Canceled)
 }
 
 // RespondWithError sends a JSON error response to the client.
-// This function formats the error message and includes a timestamp in the response.
-// It automatically sets the HTTP status code to 400 (Bad Request) and the
-// Content-Type header to application/json.
+// This function formats the error message and includes a machine-readable code and
+// a timestamp in the response. If the error is (or wraps) an *APIError, its status
+// and code are used; an *InternalError is answered with 500 (Internal Server Error)
+// and a generic message while its cause is logged; otherwise the HTTP status code is
+// set to 400 (Bad Request).
+// The Content-Type header is set to application/json.
 //
 // The error response follows this structure:
 //
 //	{
 //	  "error": "error message here",
+//	  "code": "bad_request",
 //	  "timestamp": "2024-01-01 12:00:00 +0000 UTC"
 //	}
 //
@@ -71,7 +241,49 @@ func writeJSON(w http.ResponseWriter, status int, v any) error {
 // Returns:
 //   - error: Any error that occurred during response writing
 func RespondWithError(w http.ResponseWriter, e error) error {
-	return writeJSON(w, http.StatusBadRequest, formatError(e))
+	return respondWithError(w, e, slog.Default())
+}
+
+// respondWithError sends an error response, logging the cause of an *InternalError.
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - e: The error to format and send
+//   - logger: The logger receiving the cause of internal errors
+//
+// Returns:
+//   - error: Any error that occurred during response writing
+func respondWithError(w http.ResponseWriter, e error, logger *slog.Logger) error {
+	var internal *InternalError
+	if errors.As(e, &internal) {
+		logger.Error("internal error", "error", e.Error())
+	}
+	return writeError(w, errorStatus(e), e)
+}
+
+// RespondWithProblem sends an RFC 7807 problem document for the error.
+// It responds like RespondWithError, with the status taken from an *APIError (400
+// otherwise), but always writes an application/problem+json Problem regardless of
+// SetProblemDetails.
+//
+// The problem response follows this structure:
+//
+//	{
+//	  "type": "user_not_found",
+//	  "title": "Not Found",
+//	  "status": 404,
+//	  "detail": "user does not exist"
+//	}
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - e: The error to describe
+//
+// Returns:
+//   - error: Any error that occurred during response writing
+func RespondWithProblem(w http.ResponseWriter, e error) error {
+	status := errorStatus(e)
+	return writeJSONAs(w, status, ProblemContentType, NewProblem(status, e))
 }
 
 // RespondWithSuccess sends a JSON success response to the client.
@@ -89,20 +301,240 @@ func RespondWithSuccess(w http.ResponseWriter, status int, v any) error {
 	return writeJSON(w, status, v)
 }
 
+// RespondWithCreated sends a 201 (Created) JSON response with a Location header for the new resource.
+// REST clients expect the Location header to point at a resource created by a POST.
+// Relative locations are resolved against the request URL following RFC 3986, so for
+// a request to "/api/users", "/api/users/42" stays as is, "users/42" becomes
+// "/api/users/42", and absolute URLs are used unchanged.
+//
+// Example usage:
+//
+//	func createUser(w http.ResponseWriter, r *http.Request) error {
+//	    user, err := store.Create(r.Context(), input)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    return RespondWithCreated(w, r, "/api/users/"+user.ID, user)
+//	}
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - r: The HTTP request that created the resource
+//   - location: The URL of the created resource (absolute, or relative to the request URL)
+//   - v: The data to encode as JSON in the response body
+//
+// Returns:
+//   - error: An error if the location is not a valid URL, or any error that occurred during JSON encoding or writing
+func RespondWithCreated(w http.ResponseWriter, r *http.Request, location string, v any) error {
+	ref, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("invalid location %q: %w", location, err)
+	}
+
+	w.Header().Set("Location", r.URL.ResolveReference(ref).String())
+	return writeJSON(w, http.StatusCreated, v)
+}
+
+// PageInfo holds the cursor pagination metadata of a list response.
+type PageInfo struct {
+	NextCursor string `json:"next_cursor"` // Opaque cursor for the next page (empty when there are no more results)
+	HasMore    bool   `json:"has_more"`    // Whether more results exist after this page
+}
+
+// PageResponse is the envelope written by RespondWithPage.
+type PageResponse struct {
+	Data       any      `json:"data"`       // The items of the current page
+	Pagination PageInfo `json:"pagination"` // The pagination metadata
+}
+
+// RespondWithPage sends a page of a cursor-paginated list as a JSON success response.
+// This function wraps the items in a standard envelope together with the pagination
+// metadata, so every list endpoint in a codebase responds with the same shape.
+// A nil items value is sent as an empty array.
+//
+// The response follows this structure:
+//
+//	{
+//	  "data": [{"id": "..."}, ...],
+//	  "pagination": {
+//	    "next_cursor": "eyJpZCI6IjEyMyJ9",
+//	    "has_more": true
+//	  }
+//	}
+//
+// Example usage:
+//
+//	users, next, more, err := store.ListUsers(r.URL.Query().Get("cursor"), 50)
+//	if err != nil {
+//	    return err
+//	}
+//	return RespondWithPage(w, http.StatusOK, users, next, more)
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - status: The HTTP status code to return (typically 200)
+//   - items: The items of the current page (typically a slice)
+//   - nextCursor: The cursor for fetching the next page (empty if there is none)
+//   - hasMore: Whether more results exist after this page
+//
+// Returns:
+//   - error: Any error that occurred during JSON encoding or writing
+func RespondWithPage(w http.ResponseWriter, status int, items any, nextCursor string, hasMore bool) error {
+	if items == nil {
+		items = []any{}
+	}
+
+	return writeJSON(w, status, PageResponse{
+		Data: items,
+		Pagination: PageInfo{
+			NextCursor: nextCursor,
+			HasMore:    hasMore,
+		},
+	})
+}
+
+const (
+	// DefaultPageSize is the page size used by ParsePage when PageLimits.Default is not set.
+	DefaultPageSize = 20
+
+	// DefaultMaxPageSize is the largest page size allowed by ParsePage when PageLimits.Max is not set.
+	DefaultMaxPageSize = 100
+)
+
+// PageLimits configures the page sizes accepted by ParsePage.
+type PageLimits struct {
+	Default int    // The page size used when the client sends none or 0 (DefaultPageSize if 0)
+	Max     int    // The largest page size; larger requests are clamped to it (DefaultMaxPageSize if 0)
+	Param   string // The query parameter holding the page size (default "page_size")
+}
+
+// PageParams holds the effective cursor pagination parameters of a list request.
+type PageParams struct {
+	Cursor   string // The opaque cursor from the "cursor" query parameter (empty for the first page)
+	PageSize int    // The effective page size, between 1 and the configured maximum
+}
+
+// ParsePage reads the cursor and page size of a list request, the counterpart of RespondWithPage.
+// Clients can otherwise request pages large enough to cause heavy queries, so the page
+// size is bounded: an absent or zero size falls back to the default, and a size above
+// the maximum is clamped to it rather than rejected. Negative and non-integer sizes
+// are client errors, answered with 400 (Bad Request) when returned from an APIFunc.
+//
+// Example usage:
+//
+//	// GET /api/users?cursor=eyJpZCI6IjEyMyJ9&page_size=100000
+//	page, err := ParsePage(r, PageLimits{Default: 25, Max: 200})
+//	if err != nil {
+//	    return err
+//	}
+//	// page.PageSize == 200
+//	users, next, more, err := store.ListUsers(page.Cursor, page.PageSize)
+//
+// Parameters:
+//   - r: The HTTP request
+//   - limits: The default and maximum page sizes
+//
+// Returns:
+//   - PageParams: The cursor and effective page size
+//   - error: An error if the page size is negative or not an integer
+func ParsePage(r *http.Request, limits PageLimits) (PageParams, error) {
+	param := limits.Param
+	if param == "" {
+		param = "page_size"
+	}
+
+	query := r.URL.Query()
+	size := 0
+	if raw := query.Get(param); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return PageParams{}, fmt.Errorf("%s must be an integer", param)
+		}
+		size = n
+	}
+
+	size, err := limits.Clamp(size)
+	if err != nil {
+		return PageParams{}, fmt.Errorf("%s %w", param, err)
+	}
+
+	return PageParams{Cursor: query.Get("cursor"), PageSize: size}, nil
+}
+
+// Clamp returns the effective page size for a requested size.
+// Zero selects the default and sizes above the maximum are clamped to it.
+//
+// Parameters:
+//   - size: The requested page size
+//
+// Returns:
+//   - int: The effective page size
+//   - error: An error if size is negative
+func (l PageLimits) Clamp(size int) (int, error) {
+	if size < 0 {
+		return 0, errors.New("must not be negative")
+	}
+
+	maxSize := l.Max
+	if maxSize <= 0 {
+		maxSize = DefaultMaxPageSize
+	}
+	if size == 0 {
+		size = l.Default
+		if size <= 0 {
+			size = DefaultPageSize
+		}
+	}
+	return min(size, maxSize), nil
+}
+
+// writeError writes an error response in the configured format.
+// This is the single path through which the package writes error responses: by
+// default the body is the formatError object, and with SetProblemDetails enabled it
+// is an RFC 7807 problem document.
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - status: The HTTP status code to return
+//   - err: The error to report
+//
+// Returns:
+//   - error: Any error that occurred during JSON encoding or writing
+func writeError(w http.ResponseWriter, status int, err error) error {
+	if problemDetails.Load() {
+		return writeJSONAs(w, status, ProblemContentType, NewProblem(status, err))
+	}
+	return writeJSON(w, status, formatError(status, err))
+}
+
 // formatError creates a standardized error response structure.
-// This function takes an error and formats it into a map with an error message
-// and a timestamp. The timestamp is useful for debugging and logging purposes.
+// This function takes an error and formats it into a map with an error message,
+// a machine-readable code and a timestamp. The code is taken from an *APIError in
+// the error chain when set, and otherwise derived from the response status. An
+// *InternalError in the chain is replaced by the generic InternalErrorMessage. The
+// timestamp is useful for debugging and logging purposes.
 //
 // Parameters:
+//   - status: The HTTP status code of the response
 //   - err: The error to format
 //
 // Returns:
-//   - map[string]string: A map containing the error message and timestamp
-func formatError(err error) map[string]string {
+//   - map[string]string: A map containing the error message, code and timestamp
+func formatError(status int, err error) map[string]string {
 	var handlerError = err.Error()
 
+	code := ErrorCodeForStatus(status)
+	var apiErr *APIError
+	var internal *InternalError
+	if errors.As(err, &internal) {
+		handlerError, code = InternalErrorMessage, CodeInternal
+	} else if errors.As(err, &apiErr) && apiErr.Code != "" {
+		code = apiErr.Code
+	}
+
 	return map[string]string{
 		"error":     handlerError,
+		"code":      code,
 		"timestamp": time.Now().String(),
 	}
 }
@@ -0,0 +1,308 @@
+package anvil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+// decodeErrorBody decodes a formatError response body.
+func decodeErrorBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]string {
+	t.Helper()
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding error body: %v", err)
+	}
+	return body
+}
+
+func TestHandlerFuncWritesErrors(t *testing.T) {
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("name is required")
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if body := decodeErrorBody(t, rec); body["error"] != "name is required" || body["code"] != "bad_request" {
+		t.Errorf("body = %v, want the error message and bad_request code", body)
+	}
+}
+
+func TestHandlerFuncSkipsResponseForCancelledRequests(t *testing.T) {
+	logs := captureLogs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		cancel()
+		return r.Context().Err()
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil).WithContext(ctx))
+
+	if rec.Body.Len() != 0 {
+		t.Errorf("body = %q, want no response for a cancelled request", rec.Body.String())
+	}
+	if !strings.Contains(logs.String(), "client went away") {
+		t.Errorf("logs = %q, want the cancelled request to be logged", logs)
+	}
+}
+
+func TestRespondWithPage(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      any
+		nextCursor string
+		hasMore    bool
+		data       string
+	}{
+		{name: "more results", items: []string{"a", "b"}, nextCursor: "eyJpZCI6ImIifQ", hasMore: true, data: `["a","b"]`},
+		{name: "last page", items: []string{"c"}, data: `["c"]`},
+		{name: "nil items", data: `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if err := RespondWithPage(rec, http.StatusOK, tt.items, tt.nextCursor, tt.hasMore); err != nil {
+				t.Fatalf("RespondWithPage() error = %v", err)
+			}
+
+			var body struct {
+				Data       json.RawMessage `json:"data"`
+				Pagination map[string]any  `json:"pagination"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if string(body.Data) != tt.data {
+				t.Errorf("data = %s, want %s", body.Data, tt.data)
+			}
+			want := map[string]any{"next_cursor": tt.nextCursor, "has_more": tt.hasMore}
+			for field, value := range want {
+				if got, ok := body.Pagination[field]; !ok || got != value {
+					t.Errorf("pagination.%s = %v, want %v", field, got, value)
+				}
+			}
+		})
+	}
+}
+
+// failingWriter is a ResponseWriter whose writes fail with err.
+type failingWriter struct {
+	header http.Header
+	err    error
+}
+
+func (fw *failingWriter) Header() http.Header       { return fw.header }
+func (fw *failingWriter) WriteHeader(int)           {}
+func (fw *failingWriter) Write([]byte) (int, error) { return 0, fw.err }
+
+func TestIsClientDisconnect(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}, want: true},
+		{err: &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.ECONNRESET)}, want: true},
+		{err: fmt.Errorf("writing body: %w", net.ErrClosed), want: true},
+		{err: context.Canceled, want: true},
+		{err: errors.New("json: unsupported type"), want: false},
+		{err: context.DeadlineExceeded, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isClientDisconnect(tt.err); got != tt.want {
+			t.Errorf("isClientDisconnect(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWriteJSONClientDisconnect(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		disconnect bool
+	}{
+		{name: "broken pipe", err: &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}, disconnect: true},
+		{name: "other failure", err: errors.New("disk quota exceeded")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			err := writeJSON(&failingWriter{header: make(http.Header), err: tt.err}, http.StatusOK, map[string]string{"status": "ok"})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("writeJSON() error = %v, want %v", err, tt.err)
+			}
+			if logged := strings.Contains(logs.String(), "level=ERROR"); logged == tt.disconnect {
+				t.Errorf("error logged = %v, want %v; logs = %q", logged, !tt.disconnect, logs)
+			}
+		})
+	}
+}
+
+func TestRespondWithCreated(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		want     string
+	}{
+		{name: "absolute path", location: "/api/users/42", want: "/api/users/42"},
+		{name: "relative path", location: "users/42", want: "/api/users/42"},
+		{name: "relative to collection", location: "./42", want: "/api/42"},
+		{name: "absolute url", location: "https://cdn.example.com/users/42", want: "https://cdn.example.com/users/42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			err := RespondWithCreated(rec, httptest.NewRequest(http.MethodPost, "/api/users", nil), tt.location, map[string]string{"id": "42"})
+			if err != nil {
+				t.Fatalf("RespondWithCreated() error = %v", err)
+			}
+			if rec.Code != http.StatusCreated {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	if err := RespondWithCreated(rec, httptest.NewRequest(http.MethodPost, "/api/users", nil), "http://[::1", nil); err == nil {
+		t.Error("RespondWithCreated(invalid location) error = nil, want an error")
+	}
+}
+
+func TestHandlerFuncWith(t *testing.T) {
+	var order []string
+	trace := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	login := RateLimitConfig{Rate: rate.Limit(0.001), Burst: 1}.NewLimiter()
+
+	handler := HandlerFuncWith(func(w http.ResponseWriter, r *http.Request) error {
+		order = append(order, "handler")
+		return NewAPIError(http.StatusUnauthorized, "invalid_credentials", "invalid email or password")
+	}, trace("outer"), trace("inner"), login.Handler)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if body := decodeErrorBody(t, rec); body["code"] != "invalid_credentials" {
+		t.Errorf("body = %v, want the formatted API error", body)
+	}
+	if got := strings.Join(order, ","); got != "outer,inner,handler" {
+		t.Errorf("order = %s, want outer,inner,handler", got)
+	}
+
+	if got := limitedGet(handler); got != http.StatusTooManyRequests {
+		t.Errorf("second request status = %d, want %d from the route rate limit", got, http.StatusTooManyRequests)
+	}
+}
+
+func TestSetBufferedJSON(t *testing.T) {
+	payload := map[string]string{"id": "42", "name": "Ada"}
+
+	rec := httptest.NewRecorder()
+	RespondWithSuccess(rec, http.StatusOK, payload)
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Errorf("Content-Length = %q when streaming, want none", got)
+	}
+
+	SetBufferedJSON(true)
+	t.Cleanup(func() { SetBufferedJSON(false) })
+
+	rec = httptest.NewRecorder()
+	if err := RespondWithSuccess(rec, http.StatusCreated, payload); err != nil {
+		t.Fatalf("RespondWithSuccess() error = %v", err)
+	}
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if got, want := rec.Header().Get("Content-Length"), strconv.Itoa(rec.Body.Len()); got != want {
+		t.Errorf("Content-Length = %q, want %q", got, want)
+	}
+
+	logs := captureLogs(t)
+	rec = httptest.NewRecorder()
+	if err := RespondWithSuccess(rec, http.StatusOK, map[string]any{"ratio": math.NaN()}); err == nil {
+		t.Error("RespondWithSuccess(NaN) error = nil, want the encoding error")
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status after an encoding failure = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if body := decodeErrorBody(t, rec); body["error"] != InternalErrorMessage {
+		t.Errorf("body after an encoding failure = %v, want the generic error", body)
+	}
+	if !strings.Contains(logs.String(), "unable to encode response") {
+		t.Errorf("logs = %q, want the encoding failure", logs.String())
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	limits := PageLimits{Default: 25, Max: 200}
+	tests := []struct {
+		name    string
+		query   string
+		limits  PageLimits
+		want    PageParams
+		wantErr bool
+	}{
+		{name: "oversized", query: "page_size=100000", limits: limits, want: PageParams{PageSize: 200}},
+		{name: "zero", query: "page_size=0", limits: limits, want: PageParams{PageSize: 25}},
+		{name: "absent", query: "cursor=abc", limits: limits, want: PageParams{Cursor: "abc", PageSize: 25}},
+		{name: "within limits", query: "cursor=abc&page_size=50", limits: limits, want: PageParams{Cursor: "abc", PageSize: 50}},
+		{name: "package defaults", query: "page_size=500", want: PageParams{PageSize: DefaultMaxPageSize}},
+		{name: "package default size", query: "", want: PageParams{PageSize: DefaultPageSize}},
+		{name: "custom param", query: "limit=300", limits: PageLimits{Max: 200, Param: "limit"}, want: PageParams{PageSize: 200}},
+		{name: "negative", query: "page_size=-1", limits: limits, wantErr: true},
+		{name: "not an integer", query: "page_size=ten", limits: limits, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil)
+			got, err := ParsePage(r, tt.limits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePage() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, err := ParsePage(r, limits)
+		return err
+	})
+	rec := record(handler, httptest.NewRequest(http.MethodGet, "/users?page_size=-5", nil))
+	if body := decodeErrorBody(t, rec); rec.Code != http.StatusBadRequest || body["error"] != "page_size must not be negative" {
+		t.Errorf("response = %d %v, want 400 with the page size error", rec.Code, body)
+	}
+}
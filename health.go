@@ -0,0 +1,119 @@
+package anvil
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	// BuildCommit is the commit SHA the binary was built from.
+	// It is intended to be set at build time with:
+	//
+	//	go build -ldflags "-X github.com/arbenlabs/anvil.BuildCommit=$(git rev-parse HEAD)"
+	//
+	// When empty, BuildVersionInfo falls back to the VCS revision embedded by the Go toolchain.
+	BuildCommit string
+
+	// BuildTime is the time the binary was built, typically in RFC 3339 format.
+	// It is intended to be set at build time with:
+	//
+	//	go build -ldflags "-X github.com/arbenlabs/anvil.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+	//
+	// When empty, BuildVersionInfo falls back to the VCS commit time embedded by the Go toolchain.
+	BuildTime string
+)
+
+// VersionInfo describes the build of the running service.
+// It is returned by VersionHandler and embedded in the HealthHandler response so
+// operators can tell exactly which build is deployed.
+type VersionInfo struct {
+	Commit    string `json:"commit"`     // The commit SHA the binary was built from
+	BuildTime string `json:"build_time"` // The time the binary was built
+	GoVersion string `json:"go_version"` // The Go version used to build the binary
+}
+
+// BuildVersionInfo returns the VersionInfo of the running binary.
+// The commit and build time are taken from BuildCommit and BuildTime when they were
+// injected via ldflags, falling back to the VCS information embedded by the Go
+// toolchain (available when building from a git checkout).
+//
+// Example usage:
+//
+//	http.Handle("/version", VersionHandler(BuildVersionInfo()))
+//
+// Returns:
+//   - VersionInfo: The build information of the running binary
+func BuildVersionInfo() VersionInfo {
+	info := VersionInfo{
+		Commit:    BuildCommit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// VersionHandler returns a handler that responds with the given build information as JSON.
+// This is typically mounted at "/version" so operators can check which build is deployed.
+//
+// The response follows this structure:
+//
+//	{
+//	  "commit": "3b77451...",
+//	  "build_time": "2024-01-01T12:00:00Z",
+//	  "go_version": "go1.24.0"
+//	}
+//
+// Example usage:
+//
+//	http.Handle("GET /version", VersionHandler(BuildVersionInfo()))
+//
+// Parameters:
+//   - info: The build information to report
+//
+// Returns:
+//   - http.Handler: A handler that responds with the build information
+func VersionHandler(info VersionInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, info)
+	})
+}
+
+// HealthHandler returns a handler that reports the service as healthy along with its build information.
+//
+// The response follows this structure:
+//
+//	{
+//	  "status": "healthy",
+//	  "version": {"commit": "...", "build_time": "...", "go_version": "..."}
+//	}
+//
+// Example usage:
+//
+//	http.Handle("GET /health", HealthHandler(BuildVersionInfo()))
+//
+// Parameters:
+//   - info: The build information to include in the response
+//
+// Returns:
+//   - http.Handler: A handler that responds with the health status and build information
+func HealthHandler(info VersionInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"status":  "healthy",
+			"version": info,
+		})
+	})
+}
@@ -0,0 +1,61 @@
+package anvil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestVersionHandler(t *testing.T) {
+	info := VersionInfo{Commit: "4f2a9c1", BuildTime: "2024-05-01T12:00:00Z", GoVersion: "go1.24.0"}
+	rec := record(VersionHandler(info), httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	want := map[string]string{"commit": "4f2a9c1", "build_time": "2024-05-01T12:00:00Z", "go_version": "go1.24.0"}
+	for field, value := range want {
+		if body[field] != value {
+			t.Errorf("%s = %q, want %q", field, body[field], value)
+		}
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	info := VersionInfo{Commit: "4f2a9c1", GoVersion: "go1.24.0"}
+	rec := record(HealthHandler(info), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var body struct {
+		Status  string      `json:"status"`
+		Version VersionInfo `json:"version"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if body.Status != "healthy" || body.Version != info {
+		t.Errorf("body = %+v, want healthy with %+v", body, info)
+	}
+}
+
+func TestBuildVersionInfo(t *testing.T) {
+	previous := BuildCommit
+	BuildCommit = "4f2a9c1"
+	t.Cleanup(func() { BuildCommit = previous })
+
+	info := BuildVersionInfo()
+	if info.Commit != "4f2a9c1" {
+		t.Errorf("Commit = %q, want the BuildCommit value", info.Commit)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("GoVersion = %q, want %q", info.GoVersion, runtime.Version())
+	}
+}
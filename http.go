@@ -2,13 +2,21 @@ package anvil
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/arbenlabs/anvil/tools"
 	"github.com/rs/cors"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 const (
@@ -27,6 +35,10 @@ const (
 	// DefaultIdleTimeout is the default maximum amount of time to wait for the next request.
 	// This helps manage connection pooling and resource utilization.
 	DefaultIdleTimeout = time.Second * 120
+
+	// AutoTLSChallengeAddress is the address on which WithAutoTLS serves ACME HTTP-01
+	// challenges (and redirects all other plain HTTP requests to HTTPS).
+	AutoTLSChallengeAddress = ":80"
 )
 
 // AllowedMethods represents HTTP methods that are allowed in CORS configuration.
@@ -54,11 +66,23 @@ const (
 // This struct provides a builder pattern for creating HTTP servers with
 // customizable timeout configurations and graceful shutdown capabilities.
 type HTTPServer struct {
-	Address      string        // The server address (e.g., ":8080")
-	WriteTimeout time.Duration // Maximum duration for writing the entire request
-	ReadTimeout  time.Duration // Maximum duration for reading the entire request
-	IdleTimeout  time.Duration // Maximum amount of time to wait for the next request
-	Handler      http.Handler  // The HTTP handler to serve requests
+	Address         string        // The server address (e.g., ":8080")
+	WriteTimeout    time.Duration // Maximum duration for writing the entire request
+	ReadTimeout     time.Duration // Maximum duration for reading the entire request
+	IdleTimeout     time.Duration // Maximum amount of time to wait for the next request
+	ShutdownTimeout time.Duration // Maximum duration to wait for in-flight requests during shutdown (used by Run)
+	Handler         http.Handler  // The HTTP handler to serve requests
+
+	listener   net.Listener      // Optional pre-bound listener used instead of binding Address
+	bindRetry  time.Duration     // How long to keep retrying a bind while the address is in use
+	keepAlive  time.Duration     // TCP keep-alive period set on accepted connections (0 keeps the default, < 0 disables)
+	autoTLS    *autocert.Manager // Optional ACME certificate manager enabling HTTPS
+	onStart    []func()          // Hooks run right before the listener is bound
+	onReady    []func()          // Hooks run right after the listener is bound
+	onShutdown []func()          // Hooks run when graceful shutdown begins
+	stats      serverStats       // Request concurrency counters reported by Stats
+	drainDelay time.Duration     // How long new requests are answered with 503 before the listener closes
+	draining   atomic.Bool       // Set once shutdown begins (see Draining)
 }
 
 // NewServer creates a new HTTPServer instance with default timeout settings.
@@ -78,10 +102,11 @@ type HTTPServer struct {
 //   - *HTTPServer: A new HTTPServer instance with default settings
 func NewServer(address string) *HTTPServer {
 	return &HTTPServer{
-		Address:      fmt.Sprintf(":%s", address),
-		ReadTimeout:  DefaultReadTimeout,
-		WriteTimeout: DefaultWriteTimeout,
-		IdleTimeout:  DefaultIdleTimeout,
+		Address:         fmt.Sprintf(":%s", address),
+		ReadTimeout:     DefaultReadTimeout,
+		WriteTimeout:    DefaultWriteTimeout,
+		IdleTimeout:     DefaultIdleTimeout,
+		ShutdownTimeout: DefaultShutdownGracePeriod,
 	}
 }
 
@@ -136,6 +161,156 @@ func (h *HTTPServer) WithIdleTimeout(ito time.Duration) *HTTPServer {
 	return h
 }
 
+// WithShutdownTimeout sets the graceful shutdown timeout for the HTTP server.
+// This method returns the HTTPServer instance with the specified shutdown timeout,
+// following the builder pattern for configuration.
+//
+// The shutdown timeout is the maximum duration Run waits for in-flight requests
+// to finish after shutdown begins. Once it elapses, Run returns the shutdown error.
+//
+// Parameters:
+//   - sto: The shutdown timeout duration
+//
+// Returns:
+//   - *HTTPServer: The HTTPServer instance with the updated shutdown timeout
+func (h *HTTPServer) WithShutdownTimeout(sto time.Duration) *HTTPServer {
+	h.ShutdownTimeout = sto
+	return h
+}
+
+// WithTrustedProxies sets the proxy networks whose forwarding headers are trusted.
+// This method returns the HTTPServer instance, following the builder pattern for
+// configuration. It is a convenience for SetTrustedProxies: the setting is shared
+// by every middleware in this package that resolves client IPs via ClientIP, so
+// they all agree on who the client is.
+//
+// Example usage:
+//
+//	proxies, _ := ParseTrustedProxies("10.0.0.0/8")
+//	server := NewServer("8080").WithTrustedProxies(proxies)
+//
+// Parameters:
+//   - proxies: The trusted proxy networks
+//
+// Returns:
+//   - *HTTPServer: The HTTPServer instance
+func (h *HTTPServer) WithTrustedProxies(proxies []*net.IPNet) *HTTPServer {
+	SetTrustedProxies(proxies)
+	return h
+}
+
+// WithListener sets a pre-bound listener for the server to serve on.
+// This method returns the HTTPServer instance, following the builder pattern for
+// configuration.
+//
+// When a listener is set, Run and Start serve on it instead of binding Address.
+// This supports systemd socket activation, binding to an ephemeral port (":0")
+// and reading back the chosen address, and serving in tests. The listener is
+// closed when the server shuts down.
+//
+// Example usage:
+//
+//	listener, err := net.Listen("tcp", "127.0.0.1:0")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Println("listening on", listener.Addr())
+//	server := NewServer("").WithListener(listener).WithHandler(router)
+//
+// Parameters:
+//   - listener: The listener to serve on
+//
+// Returns:
+//   - *HTTPServer: The HTTPServer instance
+func (h *HTTPServer) WithListener(listener net.Listener) *HTTPServer {
+	h.listener = listener
+	return h
+}
+
+// WithBindRetry makes the server keep retrying to bind its address while it is in use.
+// This method returns the HTTPServer instance, following the builder pattern for
+// configuration.
+//
+// During rolling restarts, the previous instance may still hold the port for a short
+// while. With a retry duration set, a bind failing with EADDRINUSE is logged and
+// retried with exponential backoff until it succeeds or the duration elapses, after
+// which Run returns the bind error. Other bind errors, such as a malformed address or
+// a permission error, are not retried.
+//
+// Example usage:
+//
+//	server := NewServer("8080").WithBindRetry(30 * time.Second)
+//
+// Parameters:
+//   - timeout: How long to keep retrying (0 disables retries)
+//
+// Returns:
+//   - *HTTPServer: The HTTPServer instance
+func (h *HTTPServer) WithBindRetry(timeout time.Duration) *HTTPServer {
+	h.bindRetry = timeout
+	return h
+}
+
+// WithTCPKeepAlive sets the TCP keep-alive period of accepted connections.
+// This method returns the HTTPServer instance, following the builder pattern for
+// configuration.
+//
+// Go enables keep-alive probes every 15 seconds by default, which may not match the
+// idle behavior expected by some cloud load balancers. With a period set, the
+// listener is wrapped so that every accepted TCP connection has keep-alive enabled
+// with that period. A negative period disables keep-alive probes. The wrapper also
+// applies to a listener supplied with WithListener.
+//
+// Example usage:
+//
+//	server := NewServer("8080").WithTCPKeepAlive(5 * time.Minute)
+//
+// Parameters:
+//   - period: The keep-alive period (0 keeps the default, negative disables keep-alive)
+//
+// Returns:
+//   - *HTTPServer: The HTTPServer instance
+func (h *HTTPServer) WithTCPKeepAlive(period time.Duration) *HTTPServer {
+	h.keepAlive = period
+	return h
+}
+
+// WithAutoTLS serves HTTPS with certificates obtained and renewed automatically from Let's Encrypt.
+// This method returns the HTTPServer instance, following the builder pattern for
+// configuration. It is intended for public-facing, single-instance services.
+//
+// Certificates are requested through ACME with golang.org/x/crypto/acme/autocert,
+// only for the listed domains, and cached in cacheDir so restarts do not request
+// new ones (Let's Encrypt enforces strict rate limits). By using this option you
+// accept the Let's Encrypt terms of service.
+//
+// The server must be reachable from the internet on port 80, where Run and Start
+// additionally serve the HTTP-01 challenges at AutoTLSChallengeAddress and redirect
+// every other request to HTTPS, and on port 443, so the server should be created
+// with NewServer("443"). Both listeners shut down together. Several instances behind
+// a load balancer need a shared cache instead, which this option does not provide.
+//
+// Example usage:
+//
+//	server := NewServer("443").
+//	    WithAutoTLS("/var/lib/myapp/certs", "example.com", "www.example.com").
+//	    WithHandler(router)
+//
+// Parameters:
+//   - cacheDir: The directory caching certificates and the ACME account key (created if missing)
+//   - domains: The domains to request certificates for
+//
+// Returns:
+//   - *HTTPServer: The HTTPServer instance
+func (h *HTTPServer) WithAutoTLS(cacheDir string, domains ...string) *HTTPServer {
+	h.autoTLS = &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cacheDir),
+	}
+	return h
+}
+
 // WithHandler sets the HTTP handler for the server.
 // This method returns a new HTTPServer instance with the specified handler,
 // following the builder pattern for configuration.
@@ -153,6 +328,72 @@ func (h *HTTPServer) WithHandler(handler http.Handler) *HTTPServer {
 	return h
 }
 
+// OnStart registers a hook that runs right before the server binds its listener.
+// This method returns the HTTPServer instance, following the builder pattern for
+// configuration. Hooks run synchronously in registration order, each time Run or
+// Start is called.
+//
+// Example usage:
+//
+//	server := NewServer("8080").OnStart(func() {
+//	    slog.Info("warming caches")
+//	})
+//
+// Parameters:
+//   - fn: The hook to run
+//
+// Returns:
+//   - *HTTPServer: The HTTPServer instance
+func (h *HTTPServer) OnStart(fn func()) *HTTPServer {
+	h.onStart = append(h.onStart, fn)
+	return h
+}
+
+// OnReady registers a hook that runs right after the server has bound its listener.
+// This method returns the HTTPServer instance, following the builder pattern for
+// configuration. By the time the hooks run, the port is accepting connections, which
+// makes this the right place to register with service discovery or to signal
+// readiness to a supervisor. Hooks run synchronously in registration order, before
+// requests are served.
+//
+// Example usage:
+//
+//	server := NewServer("8080").OnReady(func() {
+//	    registry.Register("users-api", "10.0.0.12:8080")
+//	})
+//
+// Parameters:
+//   - fn: The hook to run
+//
+// Returns:
+//   - *HTTPServer: The HTTPServer instance
+func (h *HTTPServer) OnReady(fn func()) *HTTPServer {
+	h.onReady = append(h.onReady, fn)
+	return h
+}
+
+// OnShutdown registers a hook that runs when graceful shutdown begins.
+// This method returns the HTTPServer instance, following the builder pattern for
+// configuration. Hooks run synchronously in registration order, before the server
+// stops accepting connections, so they can deregister the instance from service
+// discovery while in-flight requests are still being served.
+//
+// Example usage:
+//
+//	server := NewServer("8080").OnShutdown(func() {
+//	    registry.Deregister("users-api")
+//	})
+//
+// Parameters:
+//   - fn: The hook to run
+//
+// Returns:
+//   - *HTTPServer: The HTTPServer instance
+func (h *HTTPServer) OnShutdown(fn func()) *HTTPServer {
+	h.onShutdown = append(h.onShutdown, fn)
+	return h
+}
+
 // Start begins listening for HTTP requests and handles graceful shutdown.
 // This method starts the HTTP server on the configured address and sets up
 // graceful shutdown handling. The server will listen for shutdown signals
@@ -168,24 +409,33 @@ func (h *HTTPServer) WithHandler(handler http.Handler) *HTTPServer {
 //	defer cancel()
 //	server.Start(ctx)
 //
+// Start parses the "graceful-timeout" command-line flag, panics on listen errors and
+// calls os.Exit(0) once shutdown completes, which makes it unsuitable for tests and
+// for programs that need to run cleanup after the server stops.
+//
+// Deprecated: Use Run, which returns errors instead of panicking or exiting and
+// does not touch the global flag set.
+//
 // Parameters:
 //   - ctx: Context for controlling server lifecycle and shutdown
 func (h *HTTPServer) Start(ctx context.Context) {
-	server := &http.Server{
-		Addr:         h.Address,
-		WriteTimeout: h.WriteTimeout,
-		ReadTimeout:  h.ReadTimeout,
-		IdleTimeout:  h.IdleTimeout,
-		Handler:      h.Handler,
-	}
+	server := h.newServer()
 
 	var wait time.Duration
 	flag.DurationVar(&wait, "graceful-timeout", DefaultShutdownGracePeriod, "duration for which the server gracefully waits for existing connections to finish")
 	flag.Parse()
 
+	listener, err := h.listen(ctx, server)
+	if err != nil {
+		fmt.Print(fmt.Errorf("unexpected server error: %v", err))
+		panic(err)
+	}
+
+	challenge := h.startChallengeServer()
+
 	go func() {
 		fmt.Printf("api running on port %s", server.Addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := h.serve(server, listener); err != nil && err != http.ErrServerClosed {
 			fmt.Print(fmt.Errorf("unexpected server error: %v", err))
 			panic(err)
 		}
@@ -193,10 +443,14 @@ func (h *HTTPServer) Start(ctx context.Context) {
 
 	<-ctx.Done()
 	fmt.Print("received shutdown signal, shutting down marketplace service gracefully")
+	h.beginDrain()
 
 	cx, cancel := context.WithTimeout(ctx, wait)
 	defer cancel()
 
+	if challenge != nil {
+		challenge.Shutdown(cx)
+	}
 	if err := server.Shutdown(cx); err != nil {
 		fmt.Print(fmt.Errorf("error during server shutdown"))
 	}
@@ -204,6 +458,243 @@ func (h *HTTPServer) Start(ctx context.Context) {
 	os.Exit(0)
 }
 
+// Run starts the HTTP server and blocks until it has shut down.
+// This method is the error-returning replacement for Start. It listens on the
+// configured address and begins a graceful shutdown when the context is cancelled
+// or when the process receives SIGINT or SIGTERM. It never parses flags, panics,
+// or exits the process.
+//
+// During shutdown, requests that have not started yet are answered with 503 (Service
+// Unavailable), the server stops accepting new connections (after the delay set with
+// WithDrainDelay) and waits up to ShutdownTimeout for in-flight requests to finish. Hooks registered with OnStart
+// and OnReady run around binding the listener, and OnShutdown hooks run when
+// shutdown begins. Shutdown also begins when serving fails, in which case Run returns
+// the serving error once it completes.
+//
+// Example usage:
+//
+//	server := NewServer("8080").WithHandler(router)
+//	if err := server.Run(context.Background()); err != nil {
+//	    log.Fatal(err)
+//	}
+//
+// Parameters:
+//   - ctx: Context for controlling server lifecycle and shutdown
+//
+// Returns:
+//   - error: Any error from listening (e.g., the port is in use), serving or shutdown; nil on a clean shutdown
+func (h *HTTPServer) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := h.newServer()
+
+	listener, err := h.listen(ctx, server)
+	if err != nil {
+		return fmt.Errorf("unexpected server error: %w", err)
+	}
+
+	challenge := h.startChallengeServer()
+
+	slog.Info("api running", "address", listener.Addr().String())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- h.serve(server, listener)
+	}()
+
+	var runErr error
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		// Shut down the challenge server and run the shutdown hooks as on a signal.
+		runErr = fmt.Errorf("unexpected server error: %w", err)
+		slog.Error("server failed, shutting down", "error", err.Error())
+	case <-ctx.Done():
+		slog.Info("received shutdown signal, shutting down gracefully")
+	}
+
+	h.beginDrain()
+
+	shutdownTimeout := h.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = DefaultShutdownGracePeriod
+	}
+	cx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+
+	if challenge != nil {
+		challenge.Shutdown(cx)
+	}
+	if err := server.Shutdown(cx); err != nil && runErr == nil {
+		return fmt.Errorf("error during server shutdown: %w", err)
+	}
+
+	return runErr
+}
+
+// newServer builds the underlying http.Server from the HTTPServer configuration.
+//
+// Returns:
+//   - *http.Server: A configured http.Server ready to listen
+func (h *HTTPServer) newServer() *http.Server {
+	server := &http.Server{
+		Addr:         h.Address,
+		WriteTimeout: h.WriteTimeout,
+		ReadTimeout:  h.ReadTimeout,
+		IdleTimeout:  h.IdleTimeout,
+		Handler:      h.stats.handler(h.drainHandler(h.Handler)),
+	}
+	if h.autoTLS != nil {
+		server.TLSConfig = h.autoTLS.TLSConfig()
+	}
+	return server
+}
+
+// serve serves requests on the listener, over TLS when WithAutoTLS is configured.
+//
+// Parameters:
+//   - server: The http.Server to run
+//   - listener: The bound listener
+//
+// Returns:
+//   - error: The error returned by Serve or ServeTLS
+func (h *HTTPServer) serve(server *http.Server, listener net.Listener) error {
+	if h.autoTLS != nil {
+		return server.ServeTLS(listener, "", "")
+	}
+	return server.Serve(listener)
+}
+
+// startChallengeServer starts the plain HTTP server answering ACME HTTP-01 challenges
+// when WithAutoTLS is configured. Errors are logged, since HTTPS keeps serving with
+// cached certificates even if port 80 is unavailable.
+//
+// Returns:
+//   - *http.Server: The running challenge server, or nil if WithAutoTLS is not configured
+func (h *HTTPServer) startChallengeServer() *http.Server {
+	if h.autoTLS == nil {
+		return nil
+	}
+
+	challenge := &http.Server{
+		Addr:              AutoTLSChallengeAddress,
+		Handler:           h.autoTLS.HTTPHandler(nil),
+		ReadHeaderTimeout: DefaultReadTimeout,
+	}
+	go func() {
+		if err := challenge.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("acme challenge server failed", "address", AutoTLSChallengeAddress, "error", err.Error())
+		}
+	}()
+	return challenge
+}
+
+// listen binds the listener for the server, running the OnStart hooks before and the
+// OnReady hooks after binding. A listener supplied with WithListener is used as is.
+// While the address is in use, binding is retried for the duration set with
+// WithBindRetry, or until the context is done. The listener is wrapped to apply the
+// keep-alive period set with WithTCPKeepAlive.
+//
+// Parameters:
+//   - ctx: Context for abandoning bind retries
+//   - server: The http.Server whose address to bind
+//
+// Returns:
+//   - net.Listener: The bound listener
+//   - error: Any error that occurred while binding the address
+func (h *HTTPServer) listen(ctx context.Context, server *http.Server) (net.Listener, error) {
+	runHooks(h.onStart)
+
+	if h.listener != nil {
+		runHooks(h.onReady)
+		return h.wrapListener(h.listener), nil
+	}
+
+	addr := server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+
+	deadline := time.Now().Add(h.bindRetry)
+	backoff := tools.BackoffConfig{Max: time.Second * 2}
+	for attempt := 0; ; attempt++ {
+		listener, err := net.Listen("tcp", addr)
+		if err == nil {
+			runHooks(h.onReady)
+			return h.wrapListener(listener), nil
+		}
+
+		delay := backoff.Delay(attempt)
+		if !errors.Is(err, syscall.EADDRINUSE) || time.Now().Add(delay).After(deadline) {
+			return nil, err
+		}
+		slog.Warn("address in use, retrying bind", "address", addr, "retry_in", delay.String(), "error", err.Error())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// wrapListener applies the configured TCP keep-alive period to a listener.
+//
+// Parameters:
+//   - listener: The bound listener
+//
+// Returns:
+//   - net.Listener: The listener, wrapped when a keep-alive period is configured
+func (h *HTTPServer) wrapListener(listener net.Listener) net.Listener {
+	if h.keepAlive == 0 {
+		return listener
+	}
+	return &keepAliveListener{Listener: listener, period: h.keepAlive}
+}
+
+// keepAliveListener sets the keep-alive behavior of accepted TCP connections.
+type keepAliveListener struct {
+	net.Listener
+	period time.Duration // The keep-alive period; negative disables keep-alive
+}
+
+// Accept waits for the next connection and configures its keep-alive behavior.
+// Connections that are not TCP connections are returned unchanged.
+//
+// Returns:
+//   - net.Conn: The accepted connection
+//   - error: Any error from the underlying listener
+func (l *keepAliveListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		if l.period < 0 {
+			tcp.SetKeepAlive(false)
+		} else {
+			tcp.SetKeepAlive(true)
+			tcp.SetKeepAlivePeriod(l.period)
+		}
+	}
+	return conn, nil
+}
+
+// runHooks runs lifecycle hooks in registration order.
+//
+// Parameters:
+//   - hooks: The hooks to run
+func runHooks(hooks []func()) {
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
 // CORS creates a new CORS middleware with the specified configuration.
 // This function creates a CORS handler that can be used to handle Cross-Origin
 // Resource Sharing requests. It configures which origins, methods, and credentials
@@ -251,3 +742,104 @@ func CORS(origins []string, methods []string, allowCredentials bool) *cors.Cors
 func PopulateHandlerWithCORS(crossOrigin *cors.Cors, handler http.Handler) http.Handler {
 	return crossOrigin.Handler(handler)
 }
+
+// CORSOptions configures the CORS middleware returned by CORSMiddleware.
+// It mirrors the arguments accepted by CORS and adds explicit control over
+// how preflight requests are answered and reported.
+type CORSOptions struct {
+	AllowedOrigins        []string // List of allowed origin URLs (e.g., ["https://example.com"])
+	AllowedMethods        []string // List of allowed HTTP methods (e.g., ["GET", "POST"])
+	AllowedHeaders        []string // List of non-simple headers clients may send
+	AllowCredentials      bool     // Whether to allow credentials in cross-origin requests
+	ShortCircuitPreflight bool     // Answer preflights with 204 (allowed) or 403 (rejected) and no body, without calling the next handler
+	LogRejectedOrigins    bool     // Log preflight requests whose origin, method or headers are not allowed
+}
+
+// CORSMiddleware creates a CORS middleware with explicit preflight handling.
+// Unlike CORS, which relies entirely on the rs/cors defaults, this middleware
+// can short-circuit preflight requests with a 204 (No Content) response and
+// log rejected preflights, which helps when debugging misconfigured frontends.
+//
+// A preflight request is an OPTIONS request carrying an Access-Control-Request-Method
+// header. When short-circuiting, every preflight is answered without calling the next
+// handler: with 204 when its origin, method and headers are all allowed, and with 403
+// (Forbidden) and no CORS headers otherwise, so the browser blocks the actual request.
+// Actual (non-preflight) requests always pass through to the next handler with the
+// appropriate CORS headers applied.
+//
+// Example usage:
+//
+//	corsMiddleware := CORSMiddleware(CORSOptions{
+//	    AllowedOrigins:        []string{"https://example.com"},
+//	    AllowedMethods:        []string{"GET", "POST"},
+//	    ShortCircuitPreflight: true,
+//	    LogRejectedOrigins:    true,
+//	})
+//	http.Handle("/api", corsMiddleware(myHandler))
+//
+// Parameters:
+//   - opts: The CORS configuration and preflight behavior to apply
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that applies the CORS configuration
+func CORSMiddleware(opts CORSOptions) func(http.Handler) http.Handler {
+	crossOrigin := cors.New(cors.Options{
+		AllowedOrigins:     opts.AllowedOrigins,
+		AllowedMethods:     opts.AllowedMethods,
+		AllowedHeaders:     opts.AllowedHeaders,
+		AllowCredentials:   opts.AllowCredentials,
+		OptionsPassthrough: opts.ShortCircuitPreflight,
+	})
+
+	return func(next http.Handler) http.Handler {
+		handler := crossOrigin.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if opts.ShortCircuitPreflight && isPreflightRequest(r) {
+				// rs/cors passes every preflight through, and only sets the allow
+				// headers once the origin, method and headers have all been accepted.
+				if preflightAllowed(w) {
+					w.WriteHeader(http.StatusNoContent)
+				} else {
+					w.WriteHeader(http.StatusForbidden)
+				}
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler.ServeHTTP(w, r)
+			if opts.LogRejectedOrigins && isPreflightRequest(r) && !preflightAllowed(w) {
+				slog.Warn(
+					"cors preflight rejected",
+					"origin", r.Header.Get("Origin"),
+					"method", r.Header.Get("Access-Control-Request-Method"),
+					"headers", r.Header.Get("Access-Control-Request-Headers"),
+					"path", r.URL.Path,
+				)
+			}
+		})
+	}
+}
+
+// preflightAllowed reports whether rs/cors accepted the preflight being answered on w.
+//
+// Parameters:
+//   - w: The response writer rs/cors has handled the preflight on
+//
+// Returns:
+//   - bool: true if the allow headers were set, false if the preflight was rejected
+func preflightAllowed(w http.ResponseWriter) bool {
+	return w.Header().Get("Access-Control-Allow-Origin") != ""
+}
+
+// isPreflightRequest reports whether the request is a CORS preflight request.
+// A preflight is an OPTIONS request that carries an Access-Control-Request-Method header.
+//
+// Parameters:
+//   - r: The HTTP request to inspect
+//
+// Returns:
+//   - bool: true if the request is a CORS preflight request, false otherwise
+func isPreflightRequest(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
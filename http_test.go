@@ -0,0 +1,416 @@
+package anvil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// captureLogs redirects the default slog logger to a buffer for the duration of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// preflight builds a CORS preflight request from the given origin.
+func preflight(origin string) *http.Request {
+	r := httptest.NewRequest(http.MethodOptions, "/api/orders", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	return r
+}
+
+func TestCORSMiddlewarePreflight(t *testing.T) {
+	logs := captureLogs(t)
+	nextCalls := 0
+	handler := CORSMiddleware(CORSOptions{
+		AllowedOrigins:        []string{"https://example.com"},
+		AllowedMethods:        []string{http.MethodGet, http.MethodPost},
+		ShortCircuitPreflight: true,
+		LogRejectedOrigins:    true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextCalls++
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	t.Run("allowed origin", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, preflight("https://example.com"))
+
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://example.com" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want https://example.com", got)
+		}
+		if nextCalls != 0 {
+			t.Errorf("next handler calls = %d, want 0", nextCalls)
+		}
+		if logs.Len() != 0 {
+			t.Errorf("allowed preflight was logged: %s", logs)
+		}
+	})
+
+	t.Run("rejected origin", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, preflight("https://evil.example"))
+
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+		}
+		if nextCalls != 0 {
+			t.Errorf("next handler calls = %d, want 0", nextCalls)
+		}
+		if out := logs.String(); !strings.Contains(out, "cors preflight rejected") || !strings.Contains(out, "https://evil.example") {
+			t.Errorf("logs = %q, want the rejected origin", out)
+		}
+	})
+
+	t.Run("rejected method and headers", func(t *testing.T) {
+		method := preflight("https://example.com")
+		method.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+		header := preflight("https://example.com")
+		header.Header.Set("Access-Control-Request-Headers", "X-Custom")
+
+		for _, r := range []*http.Request{method, header} {
+			logs.Reset()
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+			}
+			if nextCalls != 0 {
+				t.Errorf("next handler calls = %d, want 0", nextCalls)
+			}
+			if out := logs.String(); !strings.Contains(out, "cors preflight rejected") {
+				t.Errorf("logs = %q, want the rejected preflight", out)
+			}
+		}
+	})
+}
+
+func TestCORSMiddlewareActualRequest(t *testing.T) {
+	handler := CORSMiddleware(CORSOptions{
+		AllowedOrigins:        []string{"https://example.com"},
+		ShortCircuitPreflight: true,
+	})(statusHandler(http.StatusOK))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	r.Header.Set("Origin", "https://example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want https://example.com", got)
+	}
+}
+
+func TestHTTPServerRunStopsOnCancel(t *testing.T) {
+	captureLogs(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	ready := make(chan struct{})
+	server := NewServer("0").
+		WithListener(listener).
+		WithHandler(statusHandler(http.StatusNoContent)).
+		OnReady(func() { close(ready) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx) }()
+	<-ready
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() error = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after the context was cancelled")
+	}
+}
+
+func TestHTTPServerRunShutsDownOnServeError(t *testing.T) {
+	captureLogs(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	ready := make(chan struct{})
+	shutdown := make(chan struct{})
+	server := NewServer("0").
+		WithListener(listener).
+		WithHandler(statusHandler(http.StatusNoContent)).
+		OnReady(func() { close(ready) }).
+		OnShutdown(func() { close(shutdown) })
+
+	done := make(chan error, 1)
+	go func() { done <- server.Run(context.Background()) }()
+	<-ready
+	listener.Close() // makes Serve fail
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Run() error = %v, want the serving error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after serving failed")
+	}
+	select {
+	case <-shutdown:
+	default:
+		t.Error("OnShutdown hooks did not run")
+	}
+}
+
+func TestHTTPServerRunPortInUse(t *testing.T) {
+	captureLogs(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer listener.Close()
+
+	server := NewServer("0")
+	server.Address = listener.Addr().String()
+	err = server.WithHandler(statusHandler(http.StatusOK)).Run(context.Background())
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		t.Errorf("Run() error = %v, want %v", err, syscall.EADDRINUSE)
+	}
+}
+
+func TestHTTPServerLifecycleHooks(t *testing.T) {
+	captureLogs(t)
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	addr := probe.Addr().String()
+	probe.Close()
+
+	// listening reports whether the server accepts connections on addr.
+	listening := func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}
+
+	var events []string
+	ready := make(chan struct{})
+	server := NewServer("0").
+		WithHandler(statusHandler(http.StatusOK)).
+		OnStart(func() { events = append(events, fmt.Sprintf("start listening=%v", listening())) }).
+		OnStart(func() { events = append(events, "start 2") }).
+		OnReady(func() { events = append(events, fmt.Sprintf("ready listening=%v", listening())) }).
+		OnReady(func() { close(ready) }).
+		OnShutdown(func() { events = append(events, "shutdown") })
+	server.Address = addr
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx) }()
+	<-ready
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := []string{"start listening=false", "start 2", "ready listening=true", "shutdown"}
+	if strings.Join(events, ", ") != strings.Join(want, ", ") {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
+
+func TestHTTPServerRunRetriesBind(t *testing.T) {
+	logs := captureLogs(t)
+	held, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	addr := held.Addr().String()
+	time.AfterFunc(250*time.Millisecond, func() { held.Close() })
+
+	ready := make(chan struct{})
+	server := NewServer("0").
+		WithBindRetry(5 * time.Second).
+		WithHandler(statusHandler(http.StatusOK)).
+		OnReady(func() { close(ready) })
+	server.Address = addr
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx) }()
+
+	select {
+	case <-ready:
+	case err := <-done:
+		t.Fatalf("Run() error = %v, want the bind to be retried", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not bind after the port was released")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v, want nil", err)
+	}
+	if !strings.Contains(logs.String(), "address in use, retrying bind") {
+		t.Errorf("logs = %q, want the retried bind to be logged", logs)
+	}
+}
+
+func TestHTTPServerRunBindRetryGivesUp(t *testing.T) {
+	captureLogs(t)
+	held, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer held.Close()
+
+	server := NewServer("0").WithBindRetry(200 * time.Millisecond).WithHandler(statusHandler(http.StatusOK))
+	server.Address = held.Addr().String()
+	if err := server.Run(context.Background()); !errors.Is(err, syscall.EADDRINUSE) {
+		t.Errorf("Run() error = %v, want %v once the retry window passes", err, syscall.EADDRINUSE)
+	}
+
+	server.Address = "127.0.0.1:not-a-port"
+	start := time.Now()
+	if err := server.Run(context.Background()); err == nil || time.Since(start) > 100*time.Millisecond {
+		t.Errorf("Run(invalid address) error = %v after %v, want an immediate error", err, time.Since(start))
+	}
+}
+
+// acceptedKeepAlive accepts one connection through listener and reports whether SO_KEEPALIVE is set on it.
+func acceptedKeepAlive(t *testing.T, listener net.Listener) bool {
+	t.Helper()
+	go func() {
+		if conn, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	defer conn.Close()
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("SyscallConn() error = %v", err)
+	}
+	var enabled int
+	raw.Control(func(fd uintptr) {
+		enabled, err = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+	})
+	if err != nil {
+		t.Fatalf("GetsockoptInt(SO_KEEPALIVE) error = %v", err)
+	}
+	return enabled != 0
+}
+
+func TestHTTPServerWithTCPKeepAlive(t *testing.T) {
+	tests := []struct {
+		name    string
+		period  time.Duration
+		enabled bool
+	}{
+		{name: "period", period: 45 * time.Second, enabled: true},
+		{name: "disabled", period: -1, enabled: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+			listener := NewServer("0").WithTCPKeepAlive(tt.period).wrapListener(inner)
+			defer listener.Close()
+
+			wrapper, ok := listener.(*keepAliveListener)
+			if !ok || wrapper.period != tt.period {
+				t.Fatalf("wrapListener() = %#v, want a keepAliveListener with period %v", listener, tt.period)
+			}
+			if got := acceptedKeepAlive(t, listener); got != tt.enabled {
+				t.Errorf("SO_KEEPALIVE = %v, want %v", got, tt.enabled)
+			}
+		})
+	}
+
+	inner, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer inner.Close()
+	if listener := NewServer("0").wrapListener(inner); listener != inner {
+		t.Errorf("wrapListener() without a keep-alive period = %#v, want the listener unchanged", listener)
+	}
+}
+
+func TestHTTPServerWithAutoTLS(t *testing.T) {
+	server := NewServer("443").WithAutoTLS(t.TempDir(), "example.com", "www.example.com").WithHandler(statusHandler(http.StatusOK))
+	if server.autoTLS == nil {
+		t.Fatal("WithAutoTLS() did not configure a certificate manager")
+	}
+
+	for host, wantErr := range map[string]bool{"example.com": false, "www.example.com": false, "attacker.com": true} {
+		if err := server.autoTLS.HostPolicy(context.Background(), host); (err != nil) != wantErr {
+			t.Errorf("HostPolicy(%s) error = %v, want error %v", host, err, wantErr)
+		}
+	}
+
+	config := server.newServer().TLSConfig
+	if config == nil || config.GetCertificate == nil {
+		t.Fatal("TLSConfig does not get certificates from the manager")
+	}
+	if !slices.Contains(config.NextProtos, "acme-tls/1") {
+		t.Errorf("NextProtos = %q, want acme-tls/1 for TLS-ALPN challenges", config.NextProtos)
+	}
+
+	rec := httptest.NewRecorder()
+	server.autoTLS.HTTPHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com/users", nil))
+	if got := rec.Header().Get("Location"); got != "https://example.com/users" {
+		t.Errorf("challenge server Location = %q, want a redirect to HTTPS", got)
+	}
+
+	if plain := NewServer("8080").newServer(); plain.TLSConfig != nil {
+		t.Error("TLSConfig is set without WithAutoTLS")
+	}
+	if challenge := NewServer("8080").startChallengeServer(); challenge != nil {
+		t.Error("startChallengeServer() started a server without WithAutoTLS")
+	}
+}
@@ -0,0 +1,162 @@
+// Package ttlmap provides the expiring map behind the package's in-memory stores.
+package ttlmap
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is the minimum time between sweeps of expired entries.
+const sweepInterval = time.Minute
+
+// Map is a concurrency-safe map whose entries expire at a given time.
+// Expired entries are never returned: they are evicted when looked up, and swept at
+// most once per minute whenever an entry is written, so keys that are never looked
+// up again do not accumulate.
+type Map[V any] struct {
+	mu        sync.Mutex
+	entries   map[string]entry[V]
+	lastSweep time.Time
+}
+
+// entry is a value held by Map with its expiry.
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// New creates a new, empty map.
+//
+// Returns:
+//   - *Map[V]: A new map
+func New[V any]() *Map[V] {
+	return &Map[V]{
+		entries:   make(map[string]entry[V]),
+		lastSweep: time.Now(),
+	}
+}
+
+// Get returns the value stored under key, if present and not expired.
+//
+// Parameters:
+//   - key: The key to look up
+//
+// Returns:
+//   - V: The stored value
+//   - bool: true if a live entry was found, false otherwise
+func (m *Map[V]) Get(key string) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lookup(key, time.Now())
+}
+
+// Set stores value under key until expires, replacing any existing entry.
+//
+// Parameters:
+//   - key: The key to store
+//   - value: The value to store
+//   - expires: When the entry expires
+func (m *Map[V]) Set(key string, value V, expires time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store(key, value, expires, time.Now())
+}
+
+// Add stores value under key until expires unless a live entry already exists.
+// The check and the write happen atomically.
+//
+// Parameters:
+//   - key: The key to store
+//   - value: The value to store
+//   - expires: When the entry expires
+//
+// Returns:
+//   - bool: true if the value was stored, false if key already had a live entry
+func (m *Map[V]) Add(key string, value V, expires time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := m.lookup(key, now); ok {
+		return false
+	}
+	m.store(key, value, expires, now)
+	return true
+}
+
+// Update atomically replaces the entry under key with the result of fn.
+// fn receives the current value and expiry, and ok is false if there is no live entry.
+//
+// Parameters:
+//   - key: The key to update
+//   - fn: Computes the new value and expiry from the current entry
+func (m *Map[V]) Update(key string, fn func(value V, expires time.Time, ok bool) (V, time.Time)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	current, ok := m.lookup(key, now)
+	value, expires := fn(current, m.entries[key].expires, ok)
+	m.store(key, value, expires, now)
+}
+
+// Take removes the entry under key and returns its value, if it was live.
+//
+// Parameters:
+//   - key: The key to remove
+//
+// Returns:
+//   - V: The removed value
+//   - bool: true if a live entry was removed, false otherwise
+func (m *Map[V]) Take(key string) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	value, ok := m.lookup(key, time.Now())
+	delete(m.entries, key)
+	return value, ok
+}
+
+// Delete removes the entry under key.
+//
+// Parameters:
+//   - key: The key to remove
+func (m *Map[V]) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+}
+
+// lookup returns the live value under key, evicting an expired entry.
+// The caller must hold the lock.
+func (m *Map[V]) lookup(key string, now time.Time) (V, bool) {
+	e, ok := m.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if !now.Before(e.expires) {
+		delete(m.entries, key)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// store writes an entry and sweeps expired entries if a sweep is due.
+// The caller must hold the lock.
+func (m *Map[V]) store(key string, value V, expires, now time.Time) {
+	m.entries[key] = entry[V]{value: value, expires: expires}
+
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		for k, e := range m.entries {
+			if !now.Before(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+}
@@ -0,0 +1,94 @@
+package ttlmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMapExpiry(t *testing.T) {
+	m := New[string]()
+	now := time.Now()
+	m.Set("live", "a", now.Add(time.Minute))
+	m.Set("expired", "b", now.Add(-time.Second))
+
+	if v, ok := m.Get("live"); !ok || v != "a" {
+		t.Errorf("Get(live) = %q, %v; want a, true", v, ok)
+	}
+	if _, ok := m.Get("expired"); ok {
+		t.Error("Get(expired) found an expired entry")
+	}
+	if _, ok := m.entries["expired"]; ok {
+		t.Error("expired entry was not evicted on lookup")
+	}
+}
+
+func TestMapAdd(t *testing.T) {
+	m := New[int]()
+	expires := time.Now().Add(time.Minute)
+
+	if !m.Add("k", 1, expires) {
+		t.Fatal("first Add() = false, want true")
+	}
+	if m.Add("k", 2, expires) {
+		t.Error("second Add() = true, want false")
+	}
+	if v, _ := m.Get("k"); v != 1 {
+		t.Errorf("Get() = %d, want the first value", v)
+	}
+
+	m.Set("old", 1, time.Now().Add(-time.Second))
+	if !m.Add("old", 2, expires) {
+		t.Error("Add() over an expired entry = false, want true")
+	}
+}
+
+func TestMapUpdate(t *testing.T) {
+	m := New[int]()
+	expires := time.Now().Add(time.Minute)
+	increment := func(v int, e time.Time, ok bool) (int, time.Time) {
+		if !ok {
+			return 1, expires
+		}
+		return v + 1, e
+	}
+
+	m.Update("k", increment)
+	m.Update("k", increment)
+	if v, ok := m.Get("k"); !ok || v != 2 {
+		t.Errorf("Get() = %d, %v; want 2, true", v, ok)
+	}
+	if e := m.entries["k"].expires; !e.Equal(expires) {
+		t.Errorf("expires = %v, want %v", e, expires)
+	}
+}
+
+func TestMapTake(t *testing.T) {
+	m := New[string]()
+	m.Set("k", "v", time.Now().Add(time.Minute))
+
+	if v, ok := m.Take("k"); !ok || v != "v" {
+		t.Errorf("Take() = %q, %v; want v, true", v, ok)
+	}
+	if _, ok := m.Take("k"); ok {
+		t.Error("second Take() = true, want false")
+	}
+
+	m.Set("expired", "v", time.Now().Add(-time.Second))
+	if _, ok := m.Take("expired"); ok {
+		t.Error("Take() returned an expired entry")
+	}
+}
+
+func TestMapSweep(t *testing.T) {
+	m := New[string]()
+	m.Set("expired", "v", time.Now().Add(-time.Second))
+	m.lastSweep = time.Now().Add(-sweepInterval)
+
+	m.Set("other", "v", time.Now().Add(time.Minute))
+	if _, ok := m.entries["expired"]; ok {
+		t.Error("expired entry survived a due sweep")
+	}
+	if len(m.entries) != 1 {
+		t.Errorf("len(entries) = %d, want 1", len(m.entries))
+	}
+}
@@ -1,68 +1,245 @@
 package anvil
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/arbenlabs/anvil/tools"
 	"github.com/clerkinc/clerk-sdk-go/clerk"
 	"golang.org/x/time/rate"
 )
 
+// contextKey is the type used for values stored in a request context by this package.
+// Using an unexported type prevents collisions with keys defined in other packages.
+type contextKey string
+
+const (
+	// tenantContextKey is the context key under which TenantMiddleware stores the tenant ID.
+	tenantContextKey contextKey = "tenant"
+
+	// clerkSessionContextKey is the context key under which ClerkAuthMiddleware stores the session claims.
+	clerkSessionContextKey contextKey = "clerksession"
+
+	// claimsContextKey is the context key under which JWTAuthMiddleware stores the token claims.
+	claimsContextKey contextKey = "claims"
+
+	// csrfContextKey is the context key under which CSRFMiddleware stores the current token.
+	csrfContextKey contextKey = "csrf"
+
+	// requestIDContextKey is the context key under which RequestIDMiddleware stores the request ID.
+	requestIDContextKey contextKey = "request_id"
+
+	// loggerContextKey is the context key under which ContextLoggerMiddleware stores the request logger.
+	loggerContextKey contextKey = "logger"
+
+	// routePatternContextKey is the context key under which the matched route pattern is recorded.
+	routePatternContextKey contextKey = "route_pattern"
+
+	// apiVersionContextKey is the context key under which APIVersionMiddleware stores the requested version.
+	apiVersionContextKey contextKey = "api_version"
+
+	// txContextKey is the context key under which TxMiddleware stores the request transaction.
+	txContextKey contextKey = "tx"
+
+	// signatureKeyContextKey is the context key under which SignatureMiddleware stores the signing key ID.
+	signatureKeyContextKey contextKey = "signature_key"
+)
+
+// DefaultTenantHeader is the default header read by TenantMiddleware when no header is given.
+const DefaultTenantHeader = "X-Org-ID"
+
+// DefaultTenantClaim is the default JWT claim read by TenantMiddleware in derive-from-JWT mode.
+const DefaultTenantClaim = "org_id"
+
 // Message represents a standardized error response structure for rate limiting.
 // This struct is used to provide consistent error messages when rate limits are exceeded.
 // It includes status information, a descriptive message, a locked flag, and a timestamp
 // for debugging and monitoring purposes.
 type Message struct {
-	Status    string    `json:"status"`    // The status of the request (e.g., "Request Failed")
-	Body      string    `json:"body"`      // The error message body
-	Locked    bool      `json:"locked"`    // Whether the request is locked due to rate limiting
-	Timestamp time.Time `json:"timestamp"` // When the rate limit was triggered
+	Status    MessageStatus `json:"status"`    // The status of the request (e.g., StatusRateLimited)
+	Body      string        `json:"body"`      // The error message body
+	Locked    bool          `json:"locked"`    // Whether the request is locked due to rate limiting
+	Timestamp time.Time     `json:"timestamp"` // When the rate limit was triggered
+}
+
+// MessageStatus represents the status reported in a Message.
+// This type provides type safety for the Message.Status field, so clients and
+// tests can compare against exported constants rather than magic strings.
+type MessageStatus string
+
+const (
+	// StatusRequestFailed indicates that the request was rejected before reaching the handler.
+	StatusRequestFailed MessageStatus = "Request Failed"
+
+	// StatusRateLimited indicates that the request was rejected because the client
+	// exceeded its rate limit. It is sent with the 429 (Too Many Requests) response.
+	StatusRateLimited MessageStatus = "Rate Limited"
+)
+
+// Valid reports whether the status is one of the MessageStatus constants defined by this package.
+//
+// Example usage:
+//
+//	var msg Message
+//	json.NewDecoder(resp.Body).Decode(&msg)
+//	if !msg.Status.Valid() {
+//	    // unexpected response shape
+//	}
+//
+// Returns:
+//   - bool: true if the status is a known MessageStatus, false otherwise
+func (s MessageStatus) Valid() bool {
+	switch s {
+	case StatusRequestFailed, StatusRateLimited:
+		return true
+	default:
+		return false
+	}
 }
 
 // RateLimit is a type alias for rate.Limiter to provide semantic meaning.
 // This type represents a rate limiter that controls the frequency of requests
 // based on the configured rate and burst limits.
+//
+// Deprecated: Use RateLimitConfig, which is a plain value and cannot share
+// limiter state between middleware instances.
 type RateLimit *rate.Limiter
 
 var (
 	// RateLimitPublicAPI provides rate limiting for public API endpoints.
 	// This limiter allows 5000 requests per second with a burst capacity of 100 requests.
 	// Suitable for public-facing APIs that need to handle high traffic while preventing abuse.
+	//
+	// Deprecated: Use PublicAPIRateLimit. The preset middleware no longer reads this variable.
 	RateLimitPublicAPI RateLimit = rate.NewLimiter(5000, 100)
 
 	// RateLimitInternalAPI provides rate limiting for internal API endpoints.
 	// This limiter allows 10000 requests per second with a burst capacity of 200 requests.
 	// Suitable for internal services that need higher throughput than public APIs.
+	//
+	// Deprecated: Use InternalAPIRateLimit. The preset middleware no longer reads this variable.
 	RateLimitInternalAPI RateLimit = rate.NewLimiter(10000, 200)
 
 	// RateLimitUserWebAPI provides rate limiting for user-facing web APIs.
 	// This limiter allows 300 requests per second with a burst capacity of 30 requests.
 	// Suitable for web applications where users interact directly with the API.
+	//
+	// Deprecated: Use UserWebAPIRateLimit. The preset middleware no longer reads this variable.
 	RateLimitUserWebAPI RateLimit = rate.NewLimiter(300, 30)
 
 	// RateLimitStrictAPI provides strict rate limiting for sensitive endpoints.
 	// This limiter allows 100 requests per second with a burst capacity of 10 requests.
 	// Suitable for authentication endpoints, payment processing, or other sensitive operations.
+	//
+	// Deprecated: Use StrictAPIRateLimit. The preset middleware no longer reads this variable.
 	RateLimitStrictAPI RateLimit = rate.NewLimiter(100, 10)
 )
 
+// RateLimitConfig describes the per-client rate and burst of a rate limiter.
+// It is a plain value: every RateLimiter built from it with NewLimiter has its own
+// independent client state, so middleware instances (and tests) never share budgets.
+type RateLimitConfig struct {
+	Rate  rate.Limit // The number of requests per second allowed for each client
+	Burst int        // The burst capacity for each client
+}
+
+// NewLimiter creates a new RateLimiter with the configured rate and burst.
+// Each call returns a limiter with fresh client state. Its cleanup goroutine runs
+// until Close is called; use NewLimiterWithContext to tie it to a context instead.
+//
+// Example usage:
+//
+//	limiter := StrictAPIRateLimit().NewLimiter()
+//	http.Handle("/api/login", limiter.Handler(loginHandler))
+//
+// Returns:
+//   - *RateLimiter: A new RateLimiter instance
+func (c RateLimitConfig) NewLimiter() *RateLimiter {
+	return NewRateLimiter(c.Rate, c.Burst)
+}
+
+// NewLimiterWithContext creates a new RateLimiter with the configured rate and burst
+// whose background cleanup stops when ctx is cancelled.
+// Pass the context given to HTTPServer.Run so the limiter lives exactly as long as the server.
+//
+// Example usage:
+//
+//	limiter := StrictAPIRateLimit().NewLimiterWithContext(ctx)
+//	http.Handle("/api/login", limiter.Handler(loginHandler))
+//
+// Parameters:
+//   - ctx: The context bounding the limiter's background work
+//
+// Returns:
+//   - *RateLimiter: A new RateLimiter instance
+func (c RateLimitConfig) NewLimiterWithContext(ctx context.Context) *RateLimiter {
+	return NewRateLimiterWithContext(ctx, c.Rate, c.Burst)
+}
+
+// PublicAPIRateLimit returns the preset for public API endpoints.
+// It allows 5000 requests per second with a burst capacity of 100 requests per client.
+// Suitable for public-facing APIs that need to handle high traffic while preventing abuse.
+//
+// Returns:
+//   - RateLimitConfig: A fresh copy of the preset
+func PublicAPIRateLimit() RateLimitConfig {
+	return RateLimitConfig{Rate: 5000, Burst: 100}
+}
+
+// InternalAPIRateLimit returns the preset for internal API endpoints.
+// It allows 10000 requests per second with a burst capacity of 200 requests per client.
+// Suitable for internal services that need higher throughput than public APIs.
+//
+// Returns:
+//   - RateLimitConfig: A fresh copy of the preset
+func InternalAPIRateLimit() RateLimitConfig {
+	return RateLimitConfig{Rate: 10000, Burst: 200}
+}
+
+// UserWebAPIRateLimit returns the preset for user-facing web APIs.
+// It allows 300 requests per second with a burst capacity of 30 requests per client.
+// Suitable for web applications where users interact directly with the API.
+//
+// Returns:
+//   - RateLimitConfig: A fresh copy of the preset
+func UserWebAPIRateLimit() RateLimitConfig {
+	return RateLimitConfig{Rate: 300, Burst: 30}
+}
+
+// StrictAPIRateLimit returns the preset for sensitive endpoints.
+// It allows 100 requests per second with a burst capacity of 10 requests per client.
+// Suitable for authentication endpoints, payment processing, or other sensitive operations.
+//
+// Returns:
+//   - RateLimitConfig: A fresh copy of the preset
+func StrictAPIRateLimit() RateLimitConfig {
+	return RateLimitConfig{Rate: 100, Burst: 10}
+}
+
 // LoggerMiddleware creates an HTTP middleware that logs request information.
-// This middleware extracts and logs the client's IP address, host, server address,
-// user agent, and request details (method, path, remote address) for each HTTP request.
-// The logging is done using the structured logging package (slog) for better log parsing.
+// This middleware extracts and logs the client's IP address (resolved with ClientIP), host, server address,
+// user agent, route pattern, and request details (method, path, remote address) for each HTTP request.
+// The logging is done using the structured logging package (slog) for better log parsing,
+// with the message "request".
+// The request is logged once the handler returns, so the matched route pattern is known.
 //
 // The middleware logs the following information:
 //   - IP address of the client
 //   - Host from the request URL
 //   - Server address
 //   - User agent string
+//   - Route pattern (e.g., "GET /users/{id}"; see RoutePatternFromContext)
 //   - Request method, path, and remote address
 //
 // Example usage:
@@ -76,37 +253,219 @@ var (
 //   - http.Handler: A new handler that logs requests before passing them to the next handler
 func LoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract the IP address from the request.
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+		// Resolve the client IP, honoring trusted proxies.
+		ip := ClientIP(r)
+
+		r = WithRoutePattern(r)
+		next.ServeHTTP(w, r)
 
 		ctx := r.Context()
 		srvAddr := ctx.Value(http.LocalAddrContextKey).(net.Addr)
 
-		slog.Info(
+		slog.Info("request",
 			"ip_address", ip,
 			"host", r.URL.Host,
 			"server_addr", srvAddr.String(),
 			"user_agent", r.UserAgent(),
-			fmt.Sprintf("%s - %s (%s)", r.Method, r.URL.Path, r.RemoteAddr),
+			"route", RoutePatternFromContext(ctx),
+			"request", fmt.Sprintf("%s - %s (%s)", r.Method, r.URL.Path, r.RemoteAddr),
 		)
-
-		next.ServeHTTP(w, r)
 	})
 }
 
+// LogFormat selects the format of access log lines written by LoggerMiddlewareWithFormat.
+type LogFormat int
+
+const (
+	// LogFormatJSON logs requests with slog like LoggerMiddleware. It is the default.
+	LogFormatJSON LogFormat = iota
+
+	// LogFormatCommon writes Apache Common Log Format lines:
+	// host - - [time] "method path proto" status bytes
+	LogFormatCommon
+
+	// LogFormatCombined writes Apache Combined Log Format lines, which append the
+	// referer and user agent to the Common Log Format:
+	// host - - [time] "method path proto" status bytes "referer" "user-agent"
+	LogFormatCombined
+)
+
+// clfTimeLayout is the timestamp layout of the Common and Combined Log Formats.
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// LoggerMiddlewareWithFormat creates middleware that writes an access log line per request in the given format.
+// Log pipelines built for Apache-style logs can consume LogFormatCommon or
+// LogFormatCombined lines written to out. The host is the client IP resolved with
+// ClientIP, the time is when the request arrived, the path includes the query string,
+// and the byte count is the size of the response body ("-" when empty). Lines are
+// written with a single Write call each, serialized across requests.
+//
+// LogFormatJSON returns LoggerMiddleware unchanged and ignores out.
+//
+// Example usage:
+//
+//	accessLog := LoggerMiddlewareWithFormat(LogFormatCombined, os.Stdout)
+//	handler := accessLog(router)
+//	// 203.0.113.7 - - [15/Jan/2024:10:30:00 +0000] "GET /api/users?page=2 HTTP/1.1" 200 512 "-" "curl/8.4.0"
+//
+// Parameters:
+//   - format: The access log format
+//   - out: The writer receiving log lines (e.g., os.Stdout or a log file)
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that logs requests in the given format
+func LoggerMiddlewareWithFormat(format LogFormat, out io.Writer) func(http.Handler) http.Handler {
+	if format == LogFormatJSON {
+		return LoggerMiddleware
+	}
+
+	var mu sync.Mutex
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := newStatusWriter(w)
+			next.ServeHTTP(sw, r)
+
+			status := sw.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			size := "-"
+			if sw.bytes > 0 {
+				size = fmt.Sprint(sw.bytes)
+			}
+
+			var line bytes.Buffer
+			fmt.Fprintf(&line, "%s - - [%s] \"%s %s %s\" %d %s",
+				ClientIP(r),
+				start.Format(clfTimeLayout),
+				clfEscape(r.Method),
+				clfEscape(r.URL.RequestURI()),
+				clfEscape(r.Proto),
+				status,
+				size,
+			)
+			if format == LogFormatCombined {
+				fmt.Fprintf(&line, " \"%s\" \"%s\"", clfField(r.Referer()), clfField(r.UserAgent()))
+			}
+			line.WriteByte('\n')
+
+			mu.Lock()
+			defer mu.Unlock()
+			if _, err := out.Write(line.Bytes()); err != nil {
+				slog.Error("unable to write access log", "error", err.Error())
+			}
+		})
+	}
+}
+
+// clfEscape escapes backslashes, quotes and control characters in a log line field,
+// so client-controlled values cannot break out of their quotes or forge lines.
+//
+// Parameters:
+//   - s: The field value
+//
+// Returns:
+//   - string: The escaped value
+func clfEscape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// clfField escapes a quoted log line field, using "-" for empty values.
+//
+// Parameters:
+//   - s: The field value
+//
+// Returns:
+//   - string: The escaped value, or "-" if it is empty
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return clfEscape(s)
+}
+
+// SlowRequestMiddleware creates middleware that reports requests slower than a threshold.
+// Performance regressions often show up in tail latency before they move averages.
+// This middleware times each request and, when it takes longer than threshold, logs
+// a warning with the method, route, status and duration, then passes the request
+// metadata and duration to sink, for example to record a metric or a trace.
+//
+// The status is observed through a wrapping writer, and the route pattern is
+// recorded as in LoggerMiddleware. A nil sink only logs.
+//
+// Example usage:
+//
+//	slow := SlowRequestMiddleware(500*time.Millisecond, func(req RequestMeta, d time.Duration) {
+//	    slowRequests.WithLabelValues(req.Route).Observe(d.Seconds())
+//	})
+//	handler := slow(router)
+//
+// Parameters:
+//   - threshold: The duration above which a request is considered slow
+//   - sink: The function receiving slow requests (nil to only log them)
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that reports slow requests
+func SlowRequestMiddleware(threshold time.Duration, sink func(RequestMeta, time.Duration)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r = WithRoutePattern(r)
+			sw := newStatusWriter(w)
+			next.ServeHTTP(sw, r)
+
+			duration := time.Since(start)
+			if duration <= threshold {
+				return
+			}
+
+			status := sw.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			meta := newRequestMeta(r, status)
+
+			slog.Warn("slow request",
+				"method", meta.Method,
+				"route", meta.Route,
+				"path", meta.Path,
+				"status", meta.Status,
+				"duration", duration.String(),
+				"request_id", meta.RequestID,
+			)
+			if sink != nil {
+				sink(meta, duration)
+			}
+		})
+	}
+}
+
 // RateLimitPublic creates middleware that applies public API rate limiting.
-// This middleware uses the RateLimitPublicAPI configuration, which allows
+// This middleware uses the PublicAPIRateLimit preset, which allows
 // 5000 requests per second with a burst capacity of 100 requests.
 // It's suitable for public-facing endpoints that need to handle high traffic.
 //
-// The middleware tracks clients by IP address and applies rate limiting per client.
+// The middleware tracks clients by IP address (resolved with ClientIP) and applies rate limiting per client.
 // When a client exceeds the rate limit, it receives a 429 (Too Many Requests) response
 // with a JSON error message.
 //
+// Every call builds a new RateLimiter whose cleanup goroutine starts with the first
+// request and runs for the lifetime of the process. To stop it with the server, build
+// the limiter with PublicAPIRateLimit().NewLimiterWithContext instead.
+//
 // Example usage:
 //
 //	http.Handle("/api/public", RateLimitPublic(myHandler))
@@ -117,18 +476,22 @@ func LoggerMiddleware(next http.Handler) http.Handler {
 // Returns:
 //   - http.Handler: A new handler that applies public API rate limiting
 func RateLimitPublic(next http.Handler) http.Handler {
-	return rateLimiterMiddleware(next, RateLimitPublicAPI)
+	return rateLimiterMiddleware(next, PublicAPIRateLimit())
 }
 
 // RateLimitInternal creates middleware that applies internal API rate limiting.
-// This middleware uses the RateLimitInternalAPI configuration, which allows
+// This middleware uses the InternalAPIRateLimit preset, which allows
 // 10000 requests per second with a burst capacity of 200 requests.
 // It's suitable for internal service-to-service communication.
 //
-// The middleware tracks clients by IP address and applies rate limiting per client.
+// The middleware tracks clients by IP address (resolved with ClientIP) and applies rate limiting per client.
 // When a client exceeds the rate limit, it receives a 429 (Too Many Requests) response
 // with a JSON error message.
 //
+// Every call builds a new RateLimiter whose cleanup goroutine starts with the first
+// request and runs for the lifetime of the process. To stop it with the server, build
+// the limiter with InternalAPIRateLimit().NewLimiterWithContext instead.
+//
 // Example usage:
 //
 //	http.Handle("/api/internal", RateLimitInternal(myHandler))
@@ -139,18 +502,22 @@ func RateLimitPublic(next http.Handler) http.Handler {
 // Returns:
 //   - http.Handler: A new handler that applies internal API rate limiting
 func RateLimitInternal(next http.Handler) http.Handler {
-	return rateLimiterMiddleware(next, RateLimitInternalAPI)
+	return rateLimiterMiddleware(next, InternalAPIRateLimit())
 }
 
 // RateLimitWeb creates middleware that applies user web API rate limiting.
-// This middleware uses the RateLimitUserWebAPI configuration, which allows
+// This middleware uses the UserWebAPIRateLimit preset, which allows
 // 300 requests per second with a burst capacity of 30 requests.
 // It's suitable for web applications where users interact directly with the API.
 //
-// The middleware tracks clients by IP address and applies rate limiting per client.
+// The middleware tracks clients by IP address (resolved with ClientIP) and applies rate limiting per client.
 // When a client exceeds the rate limit, it receives a 429 (Too Many Requests) response
 // with a JSON error message.
 //
+// Every call builds a new RateLimiter whose cleanup goroutine starts with the first
+// request and runs for the lifetime of the process. To stop it with the server, build
+// the limiter with UserWebAPIRateLimit().NewLimiterWithContext instead.
+//
 // Example usage:
 //
 //	http.Handle("/api/web", RateLimitWeb(myHandler))
@@ -161,18 +528,22 @@ func RateLimitInternal(next http.Handler) http.Handler {
 // Returns:
 //   - http.Handler: A new handler that applies user web API rate limiting
 func RateLimitWeb(next http.Handler) http.Handler {
-	return rateLimiterMiddleware(next, RateLimitUserWebAPI)
+	return rateLimiterMiddleware(next, UserWebAPIRateLimit())
 }
 
 // RateLimitStrict creates middleware that applies strict API rate limiting.
-// This middleware uses the RateLimitStrictAPI configuration, which allows
+// This middleware uses the StrictAPIRateLimit preset, which allows
 // 100 requests per second with a burst capacity of 10 requests.
 // It's suitable for sensitive endpoints like authentication or payment processing.
 //
-// The middleware tracks clients by IP address and applies rate limiting per client.
+// The middleware tracks clients by IP address (resolved with ClientIP) and applies rate limiting per client.
 // When a client exceeds the rate limit, it receives a 429 (Too Many Requests) response
 // with a JSON error message.
 //
+// Every call builds a new RateLimiter whose cleanup goroutine starts with the first
+// request and runs for the lifetime of the process. To stop it with the server, build
+// the limiter with StrictAPIRateLimit().NewLimiterWithContext instead.
+//
 // Example usage:
 //
 //	http.Handle("/api/auth", RateLimitStrict(myHandler))
@@ -183,67 +554,382 @@ func RateLimitWeb(next http.Handler) http.Handler {
 // Returns:
 //   - http.Handler: A new handler that applies strict API rate limiting
 func RateLimitStrict(next http.Handler) http.Handler {
-	return rateLimiterMiddleware(next, RateLimitStrictAPI)
+	return rateLimiterMiddleware(next, StrictAPIRateLimit())
 }
 
-// rateLimiterMiddleware is the internal implementation of rate limiting middleware.
-// This function creates a rate limiter that tracks clients by IP address and applies
-// the specified rate limit configuration. It includes automatic cleanup of old client
-// entries to prevent memory leaks.
-//
-// The middleware:
-//   - Tracks clients by their IP address
-//   - Applies rate limiting per client using the provided rate limiter
-//   - Automatically cleans up client entries older than 5 minutes
-//   - Returns a 429 status with a JSON error message when rate limits are exceeded
+// rateLimiterMiddleware is the internal implementation of the preset rate limiting middleware.
+// This function builds a new RateLimiter from the given config on every call, so that
+// each middleware instance has independent state and every client receives its own
+// limiter with the preset's configuration.
 //
 // Parameters:
 //   - next: The next HTTP handler in the middleware chain
-//   - rateLimit: The rate limiter configuration to apply
+//   - config: The rate limit configuration to apply
 //
 // Returns:
 //   - http.Handler: A new handler that applies the specified rate limiting
-func rateLimiterMiddleware(next http.Handler, rateLimit RateLimit) http.Handler {
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
-	}
-	var (
-		mu      sync.Mutex
-		clients = make(map[string]*client)
-	)
-	go func() {
-		for {
-			time.Sleep(time.Minute)
-			// Lock the mutex to protect this section from race conditions.
-			mu.Lock()
-			for ip, client := range clients {
-				if time.Since(client.lastSeen) > 5*time.Minute {
-					delete(clients, ip)
-				}
-			}
-			mu.Unlock()
-		}
-	}()
+func rateLimiterMiddleware(next http.Handler, config RateLimitConfig) http.Handler {
+	return config.NewLimiter().Handler(next)
+}
+
+// rateLimitClient tracks the limiter and last activity of a single client.
+type rateLimitClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+	refunded float64 // Tokens given back by refunded requests (see refund)
+}
+
+// allow reports whether a request may proceed at now, taking a token for it.
+// Refunded tokens are spent first. When less than a whole token has been refunded,
+// the token is taken from the limiter as long as the limiter's tokens and the refunded
+// fraction add up to one, leaving the limiter in debt by the refunded fraction.
+//
+// Parameters:
+//   - now: The time of the request
+//
+// Returns:
+//   - bool: true if a token was taken, false if the client is rate limited
+func (c *rateLimitClient) allow(now time.Time) bool {
+	c.capRefunded(now)
+	if c.refunded >= 1 {
+		c.refunded--
+		return true
+	}
+	if c.limiter.TokensAt(now)+c.refunded < 1-tokenEpsilon {
+		return false
+	}
+	// The refunded fraction pays for the debt the reservation leaves in the limiter.
+	c.limiter.ReserveN(now, 1)
+	return true
+}
+
+// refund gives back the token taken for a request.
+// Unlike cancelling the request's reservation, which moves the limiter's clock back to
+// the time the reservation was made and so credits the refill since then a second
+// time, the token is kept aside and spent by the next call to allow.
+//
+// Parameters:
+//   - now: The time of the refund
+func (c *rateLimitClient) refund(now time.Time) {
+	c.refunded++
+	c.capRefunded(now)
+}
+
+// capRefunded drops refunded tokens that would not fit in the bucket at now, so that
+// refunds never raise a client's budget above its burst.
+//
+// Parameters:
+//   - now: The current time
+func (c *rateLimitClient) capRefunded(now time.Time) {
+	room := float64(c.limiter.Burst()) - c.limiter.TokensAt(now)
+	c.refunded = max(0, min(c.refunded, room))
+}
+
+// tokenEpsilon absorbs the rounding of fractional token counts in rateLimitClient.
+const tokenEpsilon = 1e-9
+
+// RateLimiter is a per-client rate limiter whose limits can be changed at runtime.
+// Clients are tracked by IP address and each receives its own token bucket.
+// The rate and burst can be adjusted with SetRate without rebuilding the middleware,
+// which lets operators raise or lower limits without redeploying.
+type RateLimiter struct {
+	mu           sync.Mutex
+	limit        rate.Limit
+	burst        int
+	clients      map[string]*rateLimitClient
+	bypassHeader string
+	bypassSecret string
+	refundOn     map[int]bool // Status classes (e.g., 4 for 4xx) whose requests are refunded
+
+	load          func() float64 // Optional load signal in [0, 1] scaling the rate down
+	loadFactor    float64        // The last sampled load factor
+	loadCheckedAt time.Time      // When the load signal was last sampled
+
+	ctx     context.Context    // Bounds the cleanup goroutine
+	stop    context.CancelFunc // Cancels ctx (see Close)
+	janitor sync.Once          // Starts the cleanup goroutine with the first client
+}
+
+const (
+	// loadCheckInterval is the minimum time between two samples of a RateLimiter's load signal.
+	loadCheckInterval = time.Second
+
+	// minLoadRateFraction is the fraction of the configured rate still allowed at full load.
+	minLoadRateFraction = 0.1
+)
+
+// NewRateLimiter creates a new RateLimiter with the specified rate and burst.
+// The limiter runs a background goroutine that removes client entries that
+// have not been seen for more than 5 minutes, preventing memory leaks. The
+// goroutine starts with the first request and runs until Close is called; use
+// NewRateLimiterWithContext to tie it to a server's lifetime instead.
+//
+// Example usage:
+//
+//	limiter := NewRateLimiter(rate.Limit(100), 10)
+//	http.Handle("/api", limiter.Handler(myHandler))
+//
+//	// Later, e.g. from an admin endpoint or config watcher:
+//	limiter.SetRate(rate.Limit(50), 5)
+//
+// Parameters:
+//   - r: The number of requests per second allowed for each client
+//   - b: The burst capacity for each client
+//
+// Returns:
+//   - *RateLimiter: A new RateLimiter instance
+func NewRateLimiter(r rate.Limit, b int) *RateLimiter {
+	return NewRateLimiterWithContext(context.Background(), r, b)
+}
+
+// NewRateLimiterWithContext creates a new RateLimiter whose background cleanup stops when ctx is cancelled.
+// It behaves like NewRateLimiter, but the goroutine removing idle clients exits
+// promptly once ctx is done (or Close is called) instead of leaking past the server's
+// lifetime. The limiter keeps serving requests after cancellation; only the cleanup stops.
+//
+// Example usage:
+//
+//	ctx, cancel := context.WithCancel(context.Background())
+//	defer cancel()
+//
+//	limiter := NewRateLimiterWithContext(ctx, rate.Limit(100), 10)
+//	server := NewServer("8080").WithHandler(limiter.Handler(router))
+//	err := server.Run(ctx)
+//
+// Parameters:
+//   - ctx: The context bounding the limiter's background work
+//   - r: The number of requests per second allowed for each client
+//   - b: The burst capacity for each client
+//
+// Returns:
+//   - *RateLimiter: A new RateLimiter instance
+func NewRateLimiterWithContext(ctx context.Context, r rate.Limit, b int) *RateLimiter {
+	ctx, stop := context.WithCancel(ctx)
+	return &RateLimiter{
+		limit:   r,
+		burst:   b,
+		clients: make(map[string]*rateLimitClient),
+		ctx:     ctx,
+		stop:    stop,
+	}
+}
+
+// Close stops the limiter's background cleanup goroutine.
+// The limiter keeps serving requests afterwards, but idle clients are no longer
+// removed. Close is safe to call more than once.
+//
+// Example usage:
+//
+//	limiter := NewRateLimiter(rate.Limit(100), 10)
+//	defer limiter.Close()
+func (rl *RateLimiter) Close() {
+	rl.stop()
+}
+
+// SetRate changes the rate and burst of the limiter at runtime.
+// The new configuration is applied to every existing client limiter, as well as
+// to limiters created for new clients. It is safe to call concurrently with
+// requests being served.
+//
+// Parameters:
+//   - r: The new number of requests per second allowed for each client
+//   - b: The new burst capacity for each client
+func (rl *RateLimiter) SetRate(r rate.Limit, b int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.limit = r
+	rl.burst = b
+	effective := rl.effectiveLimit()
+	for _, c := range rl.clients {
+		c.limiter.SetLimit(effective)
+		c.limiter.SetBurst(b)
+	}
+}
+
+// WithLoadFactor makes the limiter tighten its rate while the service is under load.
+// This method returns the RateLimiter instance, following the builder pattern for
+// configuration.
+//
+// The load function reports the current load as a factor between 0 (idle) and 1
+// (saturated), for example from CPU usage, queue depth or a health check. It is
+// sampled at most once per second while requests are served, and the rate of every
+// client limiter is then scaled to rate * (1 - load), never below 10% of the
+// configured rate so clients are slowed down rather than locked out. Values outside
+// [0, 1] are clamped. The burst capacity is not changed.
+//
+// Example usage:
+//
+//	limiter := NewRateLimiter(rate.Limit(100), 20).WithLoadFactor(func() float64 {
+//	    return float64(inFlight.Load()) / maxInFlight
+//	})
+//
+// Parameters:
+//   - load: The function reporting the current load factor (nil disables load scaling)
+//
+// Returns:
+//   - *RateLimiter: The RateLimiter instance with load scaling configured
+func (rl *RateLimiter) WithLoadFactor(load func() float64) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.load = load
+	rl.loadFactor = 0
+	rl.loadCheckedAt = time.Time{}
+	return rl
+}
+
+// EffectiveRate returns the rate currently applied to each client.
+// It equals the configured rate unless it has been scaled down by WithLoadFactor.
+//
+// Returns:
+//   - rate.Limit: The effective number of requests per second allowed for each client
+func (rl *RateLimiter) EffectiveRate() rate.Limit {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.effectiveLimit()
+}
+
+// effectiveLimit returns the configured rate scaled by the last sampled load factor.
+// The caller must hold the lock.
+//
+// Returns:
+//   - rate.Limit: The effective rate
+func (rl *RateLimiter) effectiveLimit() rate.Limit {
+	if rl.load == nil || rl.limit == rate.Inf {
+		return rl.limit
+	}
+	return rl.limit * rate.Limit(max(1-rl.loadFactor, minLoadRateFraction))
+}
+
+// sampleLoad samples the load signal, at most once per loadCheckInterval, and applies
+// the resulting rate to every client limiter.
+//
+// Parameters:
+//   - now: The current time
+func (rl *RateLimiter) sampleLoad(now time.Time) {
+	rl.mu.Lock()
+	load := rl.load
+	if load == nil || now.Sub(rl.loadCheckedAt) < loadCheckInterval {
+		rl.mu.Unlock()
+		return
+	}
+	rl.loadCheckedAt = now
+	rl.mu.Unlock()
+
+	// Call the load signal without holding the lock, since it may be slow.
+	factor := min(max(load(), 0), 1)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.loadFactor = factor
+	effective := rl.effectiveLimit()
+	for _, c := range rl.clients {
+		c.limiter.SetLimit(effective)
+	}
+}
+
+// WithBypass allows trusted callers to skip rate limiting by presenting a shared secret.
+// This method returns the RateLimiter instance, following the builder pattern for
+// configuration. It is intended for service-to-service calls behind the internal
+// limiter.
+//
+// Requests whose header value matches the secret (compared in constant time) are
+// passed straight to the next handler without consuming any rate budget. Requests
+// with a missing or invalid secret fall through to normal rate limiting. An empty
+// header name or secret disables the bypass.
+//
+// Example usage:
+//
+//	limiter := NewRateLimiter(rate.Limit(100), 10).
+//	    WithBypass("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+//
+// Parameters:
+//   - header: The request header carrying the bypass secret (e.g., "X-Internal-Token")
+//   - secret: The shared secret that grants the bypass
+//
+// Returns:
+//   - *RateLimiter: The RateLimiter instance with the bypass configured
+func (rl *RateLimiter) WithBypass(header, secret string) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 
+	rl.bypassHeader = header
+	rl.bypassSecret = secret
+	return rl
+}
+
+// WithRefundOnStatusClass refunds a client's rate budget for requests answered with the given status classes.
+// This method returns the RateLimiter instance, following the builder pattern for
+// configuration.
+//
+// A status class is the first digit of a status code, so 4 refunds every 4xx
+// response. This lets APIs avoid charging clients for requests that fail validation.
+// Each request reserves its token before the handler runs; the response status is
+// observed through a wrapping writer once the handler returns, and the reservation
+// is cancelled when the status class matches, which returns the token to the client's
+// budget (never exceeding the burst capacity). Calling the method again replaces the classes; no
+// classes disables refunds.
+//
+// Example usage:
+//
+//	limiter := NewRateLimiter(rate.Limit(10), 20).WithRefundOnStatusClass(4)
+//
+// Parameters:
+//   - classes: The status classes to refund (e.g., 4 for 4xx)
+//
+// Returns:
+//   - *RateLimiter: The RateLimiter instance with refunds configured
+func (rl *RateLimiter) WithRefundOnStatusClass(classes ...int) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.refundOn = make(map[int]bool, len(classes))
+	for _, class := range classes {
+		rl.refundOn[class] = true
+	}
+	return rl
+}
+
+// Handler wraps an HTTP handler with per-client rate limiting.
+// When a client exceeds the rate limit, it receives a 429 (Too Many Requests)
+// response with a JSON error message. Requests presenting a valid bypass secret
+// (see WithBypass) are not rate limited, and requests answered with a refunded
+// status class (see WithRefundOnStatusClass) do not count against the budget.
+//
+// Parameters:
+//   - next: The next HTTP handler in the middleware chain
+//
+// Returns:
+//   - http.Handler: A new handler that applies rate limiting
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract the IP address from the request.
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		if rl.isBypassed(r) {
+			next.ServeHTTP(w, r)
 			return
 		}
+
+		rl.sampleLoad(time.Now())
+
+		// Resolve the client IP, honoring trusted proxies.
+		ip := ClientIP(r)
 		// Lock the mutex to protect this section from race conditions.
-		mu.Lock()
-		if _, found := clients[ip]; !found {
-			clients[ip] = &client{limiter: rateLimit}
+		rl.mu.Lock()
+		c, found := rl.clients[ip]
+		if !found {
+			c = &rateLimitClient{limiter: rate.NewLimiter(rl.effectiveLimit(), rl.burst)}
+			rl.clients[ip] = c
+			rl.janitor.Do(func() { go rl.cleanup(rl.ctx) })
 		}
-		clients[ip].lastSeen = time.Now()
-		if !clients[ip].limiter.Allow() {
-			mu.Unlock()
+		now := time.Now()
+		c.lastSeen = now
+		if !c.allow(now) {
+			rl.mu.Unlock()
 
 			message := Message{
-				Status:    "Request Failed",
+				Status:    StatusRateLimited,
 				Body:      "Rate limit reached. Please wait 5 minutes and try again.",
 				Locked:    true,
 				Timestamp: time.Now(),
@@ -253,68 +939,231 @@ func rateLimiterMiddleware(next http.Handler, rateLimit RateLimit) http.Handler
 			json.NewEncoder(w).Encode(&message)
 			return
 		}
-		mu.Unlock()
-		next.ServeHTTP(w, r)
+		refundOn := rl.refundOn
+		rl.mu.Unlock()
+
+		if len(refundOn) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := newStatusWriter(w)
+		next.ServeHTTP(sw, r)
+		if refundOn[sw.Status()/100] {
+			rl.mu.Lock()
+			c.refund(time.Now())
+			rl.mu.Unlock()
+		}
 	})
 }
 
+// isBypassed reports whether the request presents the configured bypass secret.
+//
+// Parameters:
+//   - r: The HTTP request to inspect
+//
+// Returns:
+//   - bool: true if the request carries a valid bypass secret, false otherwise
+func (rl *RateLimiter) isBypassed(r *http.Request) bool {
+	rl.mu.Lock()
+	header, secret := rl.bypassHeader, rl.bypassSecret
+	rl.mu.Unlock()
+
+	if header == "" || secret == "" {
+		return false
+	}
+
+	value := r.Header.Get(header)
+	return value != "" && tools.SecureCompare(value, secret)
+}
+
+// cleanup periodically removes clients that have not been seen for 5 minutes.
+// It returns when ctx is cancelled.
+//
+// Parameters:
+//   - ctx: The context bounding the cleanup loop
+func (rl *RateLimiter) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Lock the mutex to protect this section from race conditions.
+		rl.mu.Lock()
+		for ip, c := range rl.clients {
+			if time.Since(c.lastSeen) > 5*time.Minute {
+				delete(rl.clients, ip)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// ClerkAuthMiddleware creates middleware that verifies Clerk session tokens.
+// The session token is read from the "Authorization: Bearer <token>" header and
+// verified with the provided Clerk client. On success, the session claims are
+// stored in the request context and can be retrieved with ClerkSessionFromContext.
+//
+// Example usage:
+//
+//	router.Use(ClerkAuthMiddleware(clerkClient))
+//
+// Parameters:
+//   - clerk: The Clerk client used to verify session tokens
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that requires a valid Clerk session
 func ClerkAuthMiddleware(clerk clerk.Client) func(next http.Handler) http.Handler {
+	return ClerkAuthMiddlewareWithOptions(clerk, AuthOptions{})
+}
+
+// ClerkAuthMiddlewareWithOptions creates Clerk session middleware with token lookup options.
+// It behaves like ClerkAuthMiddleware, but can additionally read the session token from
+// a cookie when the Authorization header is absent (see AuthOptions). The header always
+// takes precedence over the cookie. With AuthOptions.VerifyCacheTTL set, repeated
+// verifications of the same session token within the window reuse the first result.
+//
+// Example usage:
+//
+//	router.Use(ClerkAuthMiddlewareWithOptions(clerkClient, AuthOptions{CookieName: "__session"}))
+//
+// Parameters:
+//   - client: The Clerk client used to verify session tokens
+//   - opts: Options controlling where the session token is read from
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that requires a valid Clerk session
+func ClerkAuthMiddlewareWithOptions(client clerk.Client, opts AuthOptions) func(next http.Handler) http.Handler {
+	verify := func(token string) (*clerk.SessionClaims, error) {
+		return client.VerifyToken(token)
+	}
+	if opts.VerifyCacheTTL > 0 {
+		cache := newVerifyCache[*clerk.SessionClaims](opts.VerifyCacheTTL)
+		verify = func(token string) (*clerk.SessionClaims, error) {
+			return cache.verify(token, func(token string) (*clerk.SessionClaims, time.Time, error) {
+				session, err := client.VerifyToken(token)
+				if err != nil || session.Expiry == nil {
+					return session, time.Time{}, err
+				}
+				return session, session.Expiry.Time(), nil
+			})
+		}
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			type SessionName string
-			var ClerkSessionName SessionName = "clerksession"
-
-			// Get the session token from the Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			// Get the session token from the Authorization header or the configured cookie
+			sessionToken, err := tokenFromRequest(r, opts)
+			if errors.Is(err, errMissingToken) {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
-
-			// The token should be in the format "Bearer <token>"
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
+			if err != nil {
 				http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
 				return
 			}
 
-			sessionToken := parts[1]
-
 			// Verify the session
-			session, err := clerk.VerifyToken(sessionToken)
+			session, err := verify(sessionToken)
 			if err != nil {
 				http.Error(w, "Invalid session", http.StatusUnauthorized)
 				return
 			}
+			if opts.WebSocketProtocol {
+				acceptWebSocketProtocol(w, r)
+			}
 
 			// Add the session to the request context
-			ctx := context.WithValue(r.Context(), ClerkSessionName, session)
+			ctx := context.WithValue(r.Context(), clerkSessionContextKey, session)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// ClerkSessionFromContext returns the Clerk session claims stored by ClerkAuthMiddleware.
+//
+// Parameters:
+//   - ctx: The request context
+//
+// Returns:
+//   - *clerk.SessionClaims: The verified session claims, or nil if none were stored
+//   - bool: true if session claims were found in the context, false otherwise
+func ClerkSessionFromContext(ctx context.Context) (*clerk.SessionClaims, bool) {
+	session, ok := ctx.Value(clerkSessionContextKey).(*clerk.SessionClaims)
+	return session, ok
+}
+
+var (
+	// errInvalidWebhookSignature is sent to clients whose webhook signature could not be verified.
+	errInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+	// errWebhookSecretNotConfigured is sent when the webhook signing secret cannot be decoded.
+	errWebhookSecretNotConfigured = errors.New("webhook signing secret not configured")
+)
+
+// ClerkWebhookMiddleware creates middleware that verifies Clerk webhook signatures.
+// Clerk delivers webhooks through Svix, signing each payload with the endpoint's
+// signing secret. This middleware verifies the svix-id, svix-timestamp and
+// svix-signature headers against the request body with tools.VerifyWebhook, rejecting
+// unsigned, tampered or replayed (older than tools.WebhookTolerance) requests with a
+// 401 (Unauthorized) JSON error; the reason is logged rather than sent to the client.
+// The body is read up to DefaultMaxBodyBytes (larger bodies receive a 413) and
+// restored afterwards, so the next handler can read it as usual.
+//
+// The secret is decoded once when the middleware is created. If it is malformed, the
+// error is logged and every webhook is answered with a 500 JSON error.
+//
+// Example usage:
+//
+//	webhook := ClerkWebhookMiddleware(clerkClient, os.Getenv("CLERK_WEBHOOK_SECRET"))
+//	http.Handle("POST /webhooks/clerk", webhook(clerkEventsHandler))
+//
+// Parameters:
+//   - clerk: The Clerk client
+//   - secret: The endpoint's signing secret ("whsec_...")
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that verifies Clerk webhooks
 func ClerkWebhookMiddleware(clerk clerk.Client, secret string) func(next http.Handler) http.Handler {
+	// Decode the configured signing secret
+	signingSecret, secretErr := tools.DecodeWebhookSecret(secret)
+	if secretErr != nil {
+		slog.Error("invalid clerk webhook signing secret", "error", secretErr.Error())
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-			// Retrieve the signing secret from environment variables
-			signingSecret := secret
-			if signingSecret == "" {
-				http.Error(w, "Webhook signing secret not configured", http.StatusInternalServerError)
+			if secretErr != nil {
+				writeError(w, http.StatusInternalServerError, errWebhookSecretNotConfigured)
 				return
 			}
 
-			// Extract the signature from headers
-			signature := r.Header.Get("X-Clerk-Signature")
-			if signature == "" {
-				http.Error(w, "Invalid clerk webhook signature: ", http.StatusUnauthorized)
+			payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, DefaultMaxBodyBytes))
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					writeError(w, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+					return
+				}
+				writeError(w, http.StatusBadRequest, errors.New("unable to read request body"))
 				return
 			}
+			r.Body = io.NopCloser(bytes.NewReader(payload))
 
-			// Verify the webhook signature
-			if signingSecret != signature {
-				http.Error(w, "Invalid webhook signature: ", http.StatusUnauthorized)
+			// Verify the webhook signature using a constant-time comparison
+			err = tools.VerifyWebhook(payload, signingSecret,
+				r.Header.Get("svix-id"), r.Header.Get("svix-timestamp"), r.Header.Get("svix-signature"))
+			if err != nil {
+				slog.Info("rejected clerk webhook",
+					"path", r.URL.Path,
+					"svix_id", r.Header.Get("svix-id"),
+					"error", err.Error(),
+				)
+				writeError(w, http.StatusUnauthorized, errInvalidWebhookSignature)
 				return
 			}
 
@@ -323,3 +1172,364 @@ func ClerkWebhookMiddleware(clerk clerk.Client, secret string) func(next http.Ha
 		})
 	}
 }
+
+// TenantOptions configures how TenantMiddleware resolves the tenant ID.
+// By default the tenant ID is read from a request header. When JWT is set,
+// the middleware instead derives the tenant ID from a claim of the bearer token,
+// which cannot be spoofed by the client.
+type TenantOptions struct {
+	Required bool       // Whether to reject requests without a tenant ID (400)
+	JWT      *tools.JWT // Optional JWT service used to derive the tenant ID from the bearer token
+	Claim    string     // The JWT claim holding the tenant ID (defaults to DefaultTenantClaim)
+}
+
+// TenantMiddleware creates middleware that extracts a tenant/org ID from each request.
+// This middleware standardizes tenant resolution for multi-tenant APIs. The tenant ID
+// is read from the given header (DefaultTenantHeader if empty), or from a claim of the
+// bearer token when opts.JWT is set, and must be a well-formed UUID.
+//
+// The middleware:
+//   - Returns 400 with a JSON error when the tenant ID is malformed
+//   - Returns 400 with a JSON error when the tenant ID is missing and opts.Required is true
+//   - Returns 401 with a JSON error when the bearer token is invalid in derive-from-JWT mode
+//   - Stores the tenant ID in the request context, retrievable with TenantFromContext
+//
+// Example usage:
+//
+//	tenant := TenantMiddleware("X-Org-ID", TenantOptions{Required: true})
+//	http.Handle("/api/projects", tenant(myHandler))
+//
+// Parameters:
+//   - header: The request header holding the tenant ID (e.g., "X-Org-ID")
+//   - opts: The tenant resolution options
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that resolves and validates the tenant ID
+func TenantMiddleware(header string, opts TenantOptions) func(http.Handler) http.Handler {
+	if header == "" {
+		header = DefaultTenantHeader
+	}
+	claim := opts.Claim
+	if claim == "" {
+		claim = DefaultTenantClaim
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID := strings.TrimSpace(r.Header.Get(header))
+
+			if opts.JWT != nil {
+				tenantID = ""
+				if token, ok := bearerToken(r.Header.Get("Authorization")); ok {
+					value, err := opts.JWT.Claim(token, claim)
+					if err != nil && !errors.Is(err, tools.ErrClaimNotFound) {
+						writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid bearer token"))
+						return
+					}
+					tenantID = value
+				}
+			}
+
+			if tenantID == "" {
+				if opts.Required {
+					writeError(w, http.StatusBadRequest, fmt.Errorf("missing tenant id"))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !tools.IsValidUUID(tenantID) {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("malformed tenant id"))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), tenantContextKey, tenantID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// TenantFromContext returns the tenant ID stored by TenantMiddleware.
+// Handlers use this accessor to scope queries and authorization checks to the
+// current tenant.
+//
+// Example usage:
+//
+//	tenantID, ok := TenantFromContext(r.Context())
+//	if !ok {
+//	    // no tenant was supplied
+//	}
+//
+// Parameters:
+//   - ctx: The request context
+//
+// Returns:
+//   - string: The tenant ID, or an empty string if none was stored
+//   - bool: true if a tenant ID was found in the context, false otherwise
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantContextKey).(string)
+	return tenantID, ok
+}
+
+// bearerToken extracts the token from an Authorization header value.
+// The header must be in the format "Bearer <token>".
+//
+// Parameters:
+//   - authHeader: The raw Authorization header value
+//
+// Returns:
+//   - string: The bearer token
+//   - bool: true if the header contained a well-formed bearer token, false otherwise
+func bearerToken(authHeader string) (string, bool) {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// DefaultBodyReadTimeout is the default deadline used by BodyReadTimeoutMiddleware
+// for reading a request body.
+const DefaultBodyReadTimeout = time.Second * 10
+
+// BodyReadTimeoutMiddleware creates middleware that limits how long a request body may take to read.
+// Slowloris-style clients drip request bodies to hold connections open. Beyond the
+// server-wide read timeout, this middleware sets a per-request read deadline using
+// http.ResponseController, so a slow body read fails once the deadline passes.
+//
+// When the deadline is exceeded while the handler reads the body, the middleware
+// responds with a 408 (Request Timeout) JSON error and "Connection: close", and any
+// response the handler writes afterwards is discarded. Otherwise the deadline is
+// cleared once the handler returns so it does not affect later requests on the same
+// connection. If the underlying connection does not support read deadlines, requests pass through
+// unchanged.
+//
+// Example usage:
+//
+//	http.Handle("/api/upload", BodyReadTimeoutMiddleware(5*time.Second)(uploadHandler))
+//
+// Parameters:
+//   - timeout: The maximum time allowed to read the body (DefaultBodyReadTimeout if <= 0)
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces the body read deadline
+func BodyReadTimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	if timeout <= 0 {
+		timeout = DefaultBodyReadTimeout
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rc := http.NewResponseController(w)
+			if err := rc.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sw := &slowClientWriter{statusWriter: newStatusWriter(w)}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &slowClientBody{ReadCloser: r.Body, writer: sw}
+			}
+
+			next.ServeHTTP(sw, r)
+
+			// Keep the deadline after a timeout so the server does not block draining
+			// the rest of the slow body; the connection is closed after the 408 anyway.
+			if !sw.hasTimedOut() {
+				rc.SetReadDeadline(time.Time{})
+			}
+		})
+	}
+}
+
+// slowClientWriter discards handler output once the request body read has timed out,
+// so the 408 response written by BodyReadTimeoutMiddleware is the only response sent.
+type slowClientWriter struct {
+	*statusWriter
+	mu       sync.Mutex
+	timedOut bool
+}
+
+// WriteHeader forwards the status unless the body read has timed out.
+func (sw *slowClientWriter) WriteHeader(status int) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.timedOut {
+		return
+	}
+	sw.statusWriter.WriteHeader(status)
+}
+
+// Write forwards the body unless the body read has timed out.
+func (sw *slowClientWriter) Write(b []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return sw.statusWriter.Write(b)
+}
+
+// timeout writes the 408 response (if nothing was written yet) and discards any later output.
+func (sw *slowClientWriter) timeout() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.timedOut {
+		return
+	}
+	sw.timedOut = true
+	if !sw.wroteHeader {
+		sw.Header().Set("Connection", "close")
+		writeError(sw.statusWriter, http.StatusRequestTimeout, errors.New("timed out reading request body"))
+	}
+}
+
+// hasTimedOut reports whether the body read has timed out.
+func (sw *slowClientWriter) hasTimedOut() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.timedOut
+}
+
+// slowClientBody reports read deadline errors on the request body to its slowClientWriter.
+type slowClientBody struct {
+	io.ReadCloser
+	writer *slowClientWriter
+}
+
+// Read reads from the underlying body, triggering the 408 response on a deadline error.
+func (b *slowClientBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+		b.writer.timeout()
+	}
+	return n, err
+}
+
+// ConcurrencyMode determines what ConcurrencyLimitMiddleware does when all slots are in use.
+type ConcurrencyMode int
+
+const (
+	// ConcurrencyQueue makes requests wait for a free slot until their context is done.
+	ConcurrencyQueue ConcurrencyMode = iota
+
+	// ConcurrencyReject immediately rejects requests with 503 when no slot is free.
+	ConcurrencyReject
+)
+
+// ConcurrencyLimitMiddleware creates middleware that caps the number of in-flight requests.
+// This protects CPU- or memory-bound services from overload by allowing at most limit
+// requests to be handled concurrently across all clients. Slots are tracked with a
+// buffered channel semaphore and are always released when the handler returns, even
+// if it panics.
+//
+// When every slot is in use:
+//   - ConcurrencyReject responds immediately with 503 (Service Unavailable), a
+//     Retry-After header and a JSON error body
+//   - ConcurrencyQueue waits for a slot until the request context is done (client
+//     disconnect or deadline), then responds with the same 503
+//
+// Example usage:
+//
+//	limit := ConcurrencyLimitMiddleware(runtime.NumCPU()*4, ConcurrencyQueue)
+//	http.Handle("/api/render", limit(renderHandler))
+//
+// Parameters:
+//   - limit: The maximum number of concurrent requests (values below 1 are treated as 1)
+//   - mode: The behavior when all slots are in use
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces the concurrency limit
+func ConcurrencyLimitMiddleware(limit int, mode ConcurrencyMode) func(http.Handler) http.Handler {
+	if limit < 1 {
+		limit = 1
+	}
+	slots := make(chan struct{}, limit)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				if mode == ConcurrencyReject {
+					respondOverloaded(w)
+					return
+				}
+				select {
+				case slots <- struct{}{}:
+				case <-r.Context().Done():
+					respondOverloaded(w)
+					return
+				}
+			}
+			defer func() { <-slots }()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// respondOverloaded writes a 503 (Service Unavailable) JSON error with a Retry-After header.
+//
+// Parameters:
+//   - w: The HTTP response writer
+func respondOverloaded(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	writeError(w, http.StatusServiceUnavailable, errors.New("server is at capacity, please retry"))
+}
+
+// hopByHopHeaders lists the hop-by-hop headers defined by RFC 7230, section 6.1,
+// plus the widely used non-standard Proxy-Connection header. These headers are
+// meaningful only for a single transport-level connection and must not be
+// forwarded by proxies.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"TE",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// StripHopByHopHeaders creates middleware that removes hop-by-hop headers from incoming requests.
+// When a service sits behind another proxy, hop-by-hop headers such as Connection,
+// Keep-Alive and Proxy-Authorization can leak through and confuse handlers or
+// downstream services that the request is forwarded to. This middleware removes the
+// RFC 7230 hop-by-hop headers, as well as any header named in the incoming Connection
+// header, before the next handler runs.
+//
+// Because the Upgrade and Connection headers are removed, do not apply this
+// middleware to WebSocket endpoints.
+//
+// Example usage:
+//
+//	http.Handle("/api", StripHopByHopHeaders(proxyHandler))
+//
+// Parameters:
+//   - next: The next HTTP handler in the middleware chain
+//
+// Returns:
+//   - http.Handler: A new handler that strips hop-by-hop headers before calling next
+func StripHopByHopHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Remove headers listed in the Connection header first, since the
+		// Connection header itself is removed below.
+		for _, value := range r.Header.Values("Connection") {
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					r.Header.Del(name)
+				}
+			}
+		}
+
+		for _, name := range hopByHopHeaders {
+			r.Header.Del(name)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,736 @@
+package anvil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arbenlabs/anvil/tools"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/time/rate"
+)
+
+// statusHandler answers every request with the given status.
+func statusHandler(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+}
+
+// limitedGet sends a GET request through handler and returns the response status.
+func limitedGet(handler http.Handler) int {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api", nil))
+	return rec.Code
+}
+
+func TestRateLimiterRefundOnStatusClass(t *testing.T) {
+	// A single token that practically never refills.
+	limiter := NewRateLimiter(rate.Limit(0.001), 1).WithRefundOnStatusClass(4)
+	invalid := limiter.Handler(statusHandler(http.StatusBadRequest))
+	valid := limiter.Handler(statusHandler(http.StatusOK))
+
+	for i := range 3 {
+		if got := limitedGet(invalid); got != http.StatusBadRequest {
+			t.Fatalf("refunded request %d status = %d, want %d", i, got, http.StatusBadRequest)
+		}
+	}
+	if got := limitedGet(valid); got != http.StatusOK {
+		t.Fatalf("status after refunds = %d, want %d", got, http.StatusOK)
+	}
+	if got := limitedGet(valid); got != http.StatusTooManyRequests {
+		t.Errorf("status after the token was consumed = %d, want %d", got, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimiterRefundDoesNotExceedBurst(t *testing.T) {
+	limiter := NewRateLimiter(rate.Limit(0.001), 2).WithRefundOnStatusClass(4)
+	invalid := limiter.Handler(statusHandler(http.StatusBadRequest))
+	valid := limiter.Handler(statusHandler(http.StatusOK))
+
+	for range 5 {
+		limitedGet(invalid)
+	}
+	for i := range 2 {
+		if got := limitedGet(valid); got != http.StatusOK {
+			t.Fatalf("request %d status = %d, want %d", i, got, http.StatusOK)
+		}
+	}
+	if got := limitedGet(valid); got != http.StatusTooManyRequests {
+		t.Errorf("status after the burst = %d, want %d", got, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimiterRefundOverlappingRequests(t *testing.T) {
+	c := &rateLimitClient{limiter: rate.NewLimiter(rate.Limit(10), 10)}
+	start := time.Now()
+	later := start.Add(100 * time.Millisecond)
+
+	// Two slow requests and seven fast ones start together, leaving one token.
+	for range 9 {
+		c.allow(start)
+	}
+	// A fast request arrives while the slow ones are running and takes the refilled token.
+	if !c.allow(later) {
+		t.Fatal("allow() during the slow requests = false, want true")
+	}
+	// Both slow requests are refunded once they finish.
+	c.refund(later)
+	c.refund(later)
+
+	allowed := 0
+	for c.allow(later) {
+		allowed++
+	}
+	if allowed != 3 {
+		t.Errorf("requests allowed after the refunds = %d, want 3", allowed)
+	}
+}
+
+func TestRateLimiterRejectsWith429(t *testing.T) {
+	limiter := NewRateLimiter(rate.Limit(0.001), 1)
+	handler := limiter.Handler(statusHandler(http.StatusOK))
+
+	limitedGet(handler)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	var msg Message
+	if err := json.NewDecoder(rec.Body).Decode(&msg); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if msg.Status != StatusRateLimited || !msg.Status.Valid() || !msg.Locked {
+		t.Errorf("message = %+v, want a locked %q message", msg, StatusRateLimited)
+	}
+}
+
+func TestMessageStatusValid(t *testing.T) {
+	for _, status := range []MessageStatus{StatusRequestFailed, StatusRateLimited} {
+		if !status.Valid() {
+			t.Errorf("%q.Valid() = false, want true", status)
+		}
+	}
+	if MessageStatus("Unknown").Valid() {
+		t.Error(`"Unknown".Valid() = true, want false`)
+	}
+}
+
+// webhookSecret is the raw signing key of the Clerk webhook tests.
+var webhookSecret = []byte("clerk-webhook-test-signing-key")
+
+// signedWebhook builds a webhook request carrying the Svix signature headers for body.
+func signedWebhook(body string) *http.Request {
+	id, timestamp, signature := tools.SignWebhook([]byte(body), webhookSecret)
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/clerk", strings.NewReader(body))
+	r.Header.Set("svix-id", id)
+	r.Header.Set("svix-timestamp", timestamp)
+	r.Header.Set("svix-signature", signature)
+	return r
+}
+
+func TestClerkWebhookMiddleware(t *testing.T) {
+	secret := "whsec_" + base64.StdEncoding.EncodeToString(webhookSecret)
+	const body = `{"type":"user.created"}`
+
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		status int
+	}{
+		{name: "signed", req: func() *http.Request { return signedWebhook(body) }, status: http.StatusOK},
+		{name: "tampered body", req: func() *http.Request {
+			r := signedWebhook(body)
+			r.Body = io.NopCloser(strings.NewReader(`{"type":"user.deleted"}`))
+			return r
+		}, status: http.StatusUnauthorized},
+		{name: "expired timestamp", req: func() *http.Request {
+			r := signedWebhook(body)
+			r.Header.Set("svix-timestamp", strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+			return r
+		}, status: http.StatusUnauthorized},
+		{name: "unsigned", req: func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/webhooks/clerk", strings.NewReader(body))
+		}, status: http.StatusUnauthorized},
+		{name: "oversized body", req: func() *http.Request {
+			return signedWebhook(strings.Repeat("a", int(DefaultMaxBodyBytes)+1))
+		}, status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			var got string
+			handler := ClerkWebhookMiddleware(nil, secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				got = string(b)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tt.req())
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			switch tt.status {
+			case http.StatusOK:
+				if got != body {
+					t.Errorf("next handler read %q, want the original body", got)
+				}
+			case http.StatusUnauthorized:
+				if !strings.Contains(rec.Body.String(), errInvalidWebhookSignature.Error()) {
+					t.Errorf("body = %q, want the generic signature error", rec.Body.String())
+				}
+				if !strings.Contains(logs.String(), "rejected clerk webhook") {
+					t.Errorf("logs = %q, want the rejection reason", logs)
+				}
+			}
+		})
+	}
+}
+
+func TestClerkWebhookMiddlewareInvalidSecret(t *testing.T) {
+	logs := captureLogs(t)
+	handler := ClerkWebhookMiddleware(nil, "whsec_not base64!")(statusHandler(http.StatusOK))
+	if !strings.Contains(logs.String(), "invalid clerk webhook signing secret") {
+		t.Errorf("logs = %q, want the secret error", logs)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedWebhook(`{}`))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+// orgToken signs a token for the middleware tests carrying an org_id claim.
+func orgToken(t *testing.T, key []byte, orgID string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":    "myapp.com",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"org_id": orgID,
+	}).SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return token
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	const tenantID = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	key := []byte("tenant-test-signing-key")
+	jwtService := tools.NewJsonWebToken("myapp.com", key)
+
+	tests := []struct {
+		name    string
+		opts    TenantOptions
+		headers map[string]string
+		status  int
+		tenant  string
+	}{
+		{name: "header", headers: map[string]string{DefaultTenantHeader: tenantID}, status: http.StatusOK, tenant: tenantID},
+		{name: "missing optional", status: http.StatusOK},
+		{name: "missing required", opts: TenantOptions{Required: true}, status: http.StatusBadRequest},
+		{name: "malformed", headers: map[string]string{DefaultTenantHeader: "acme"}, status: http.StatusBadRequest},
+		{
+			name:    "jwt claim",
+			opts:    TenantOptions{JWT: jwtService},
+			headers: map[string]string{"Authorization": "Bearer " + orgToken(t, key, tenantID), DefaultTenantHeader: "ignored"},
+			status:  http.StatusOK,
+			tenant:  tenantID,
+		},
+		{
+			name:    "invalid jwt",
+			opts:    TenantOptions{JWT: jwtService},
+			headers: map[string]string{"Authorization": "Bearer " + orgToken(t, []byte("other-key"), tenantID)},
+			status:  http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := TenantMiddleware("", tt.opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = TenantFromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/projects", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got != tt.tenant {
+				t.Errorf("TenantFromContext() = %q, want %q", got, tt.tenant)
+			}
+		})
+	}
+}
+
+func TestRateLimiterSetRate(t *testing.T) {
+	limiter := NewRateLimiter(rate.Limit(0.001), 1)
+	handler := limiter.Handler(statusHandler(http.StatusOK))
+
+	limitedGet(handler)
+	if got := limitedGet(handler); got != http.StatusTooManyRequests {
+		t.Fatalf("status before SetRate = %d, want %d", got, http.StatusTooManyRequests)
+	}
+
+	limiter.SetRate(rate.Inf, 1)
+	for i := range 5 {
+		if got := limitedGet(handler); got != http.StatusOK {
+			t.Fatalf("request %d after SetRate status = %d, want %d", i, got, http.StatusOK)
+		}
+	}
+}
+
+func TestRateLimiterSetRateConcurrent(t *testing.T) {
+	limiter := NewRateLimiter(rate.Limit(1000), 100)
+	handler := limiter.Handler(statusHandler(http.StatusOK))
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				if i == 0 {
+					limiter.SetRate(rate.Limit(1000+j), 100+j)
+					continue
+				}
+				limitedGet(handler)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := limiter.EffectiveRate(); got != rate.Limit(1049) {
+		t.Errorf("EffectiveRate() = %v, want the last rate set", got)
+	}
+}
+
+func TestRateLimiterBypass(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "valid secret", token: "internal-secret", want: http.StatusOK},
+		{name: "invalid secret", token: "guess", want: http.StatusTooManyRequests},
+		{name: "absent header", want: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(rate.Limit(0.001), 1).WithBypass("X-Internal-Token", "internal-secret")
+			handler := limiter.Handler(statusHandler(http.StatusOK))
+
+			limitedGet(handler)
+			r := httptest.NewRequest(http.MethodGet, "/api", nil)
+			if tt.token != "" {
+				r.Header.Set("X-Internal-Token", tt.token)
+			}
+			if rec := record(handler, r); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestBodyReadTimeoutMiddleware(t *testing.T) {
+	handler := BodyReadTimeoutMiddleware(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	t.Run("trickle body", func(t *testing.T) {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		defer conn.Close()
+
+		fmt.Fprint(conn, "POST /upload HTTP/1.1\r\nHost: example.com\r\nContent-Length: 100\r\n\r\n")
+		go func() {
+			for range 10 {
+				if _, err := conn.Write([]byte("x")); err != nil {
+					return
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+		}()
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatalf("ReadResponse() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestTimeout {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusRequestTimeout)
+		}
+	})
+
+	t.Run("prompt body", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/upload", "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("POST error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+
+	t.Run("no deadline support", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("payload"))
+		if rec := record(handler, r); rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	})
+}
+
+// blockingHandler signals entered for every request and blocks until release is closed.
+func blockingHandler(entered chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestConcurrencyLimitMiddlewareReject(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := ConcurrencyLimitMiddleware(2, ConcurrencyReject)(blockingHandler(entered, release))
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limitedGet(handler)
+		}()
+		<-entered
+	}
+
+	for range 3 {
+		rec := record(handler, httptest.NewRequest(http.MethodGet, "/api", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("status at capacity = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+		}
+		if rec.Header().Get("Retry-After") == "" {
+			t.Error("Retry-After header missing")
+		}
+	}
+
+	close(release)
+	wg.Wait()
+	go func() { <-entered }()
+	if got := limitedGet(handler); got != http.StatusOK {
+		t.Errorf("status after the slots were released = %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestConcurrencyLimitMiddlewareQueue(t *testing.T) {
+	var inFlight, peak int
+	var mu sync.Mutex
+	handler := ConcurrencyLimitMiddleware(2, ConcurrencyQueue)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 10)
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = limitedGet(handler)
+		}()
+	}
+	wg.Wait()
+
+	for i, status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("request %d status = %d, want %d once queued", i, status, http.StatusOK)
+		}
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", peak)
+	}
+}
+
+func TestConcurrencyLimitMiddlewareQueueDeadline(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := ConcurrencyLimitMiddleware(1, ConcurrencyQueue)(blockingHandler(entered, release))
+
+	go limitedGet(handler)
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	rec := record(handler, httptest.NewRequest(http.MethodGet, "/api", nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status after the queue deadline = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestConcurrencyLimitMiddlewareReleasesOnPanic(t *testing.T) {
+	handler := ConcurrencyLimitMiddleware(1, ConcurrencyReject)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("panic") {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	func() {
+		defer func() { recover() }()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api?panic", nil))
+	}()
+	if got := limitedGet(handler); got != http.StatusOK {
+		t.Errorf("status after a panic = %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestStripHopByHopHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.Header.Set("Connection", "keep-alive, X-Internal-Hop")
+	r.Header.Add("Connection", "X-Other-Hop")
+	r.Header.Set("Keep-Alive", "timeout=5")
+	r.Header.Set("Proxy-Authorization", "Basic c2VjcmV0")
+	r.Header.Set("Proxy-Connection", "keep-alive")
+	r.Header.Set("Upgrade", "h2c")
+	r.Header.Set("X-Internal-Hop", "1")
+	r.Header.Set("X-Other-Hop", "1")
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("X-Request-ID", "req-123")
+
+	var seen http.Header
+	StripHopByHopHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+	})).ServeHTTP(httptest.NewRecorder(), r)
+
+	for _, name := range []string{"Connection", "Keep-Alive", "Proxy-Authorization", "Proxy-Connection", "Upgrade", "X-Internal-Hop", "X-Other-Hop"} {
+		if value := seen.Get(name); value != "" {
+			t.Errorf("%s = %q, want it stripped", name, value)
+		}
+	}
+	for _, name := range []string{"Authorization", "X-Request-ID"} {
+		if seen.Get(name) == "" {
+			t.Errorf("%s was stripped, want end-to-end headers kept", name)
+		}
+	}
+}
+
+func TestRateLimitPresetsHaveIndependentState(t *testing.T) {
+	first := RateLimitStrict(statusHandler(http.StatusOK))
+	second := RateLimitStrict(statusHandler(http.StatusOK))
+
+	limited := false
+	for range 10 * StrictAPIRateLimit().Burst {
+		if limitedGet(first) == http.StatusTooManyRequests {
+			limited = true
+			break
+		}
+	}
+	if !limited {
+		t.Fatal("first middleware never rate limited the client")
+	}
+	if got := limitedGet(second); got != http.StatusOK {
+		t.Errorf("second middleware status = %d, want %d with its own budget", got, http.StatusOK)
+	}
+}
+
+func TestRateLimitPresetsReturnCopies(t *testing.T) {
+	preset := StrictAPIRateLimit()
+	preset.Burst = 1
+	if got := StrictAPIRateLimit().Burst; got != 10 {
+		t.Errorf("StrictAPIRateLimit().Burst = %d after modifying a copy, want 10", got)
+	}
+
+	limiter := RateLimitConfig{Rate: rate.Limit(0.001), Burst: 1}.NewLimiter()
+	handler := limiter.Handler(statusHandler(http.StatusOK))
+	if first, second := limitedGet(handler), limitedGet(handler); first != http.StatusOK || second != http.StatusTooManyRequests {
+		t.Errorf("statuses = %d, %d; want %d then %d", first, second, http.StatusOK, http.StatusTooManyRequests)
+	}
+}
+
+func TestSlowRequestMiddleware(t *testing.T) {
+	var reported []RequestMeta
+	slow := SlowRequestMiddleware(20*time.Millisecond, func(req RequestMeta, d time.Duration) {
+		if d <= 20*time.Millisecond {
+			t.Errorf("sink duration = %s, want more than the threshold", d)
+		}
+		reported = append(reported, req)
+	})
+
+	router := NewRouter()
+	router.Route(http.MethodGet, "/reports/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	router.Route(http.MethodGet, "/health", statusHandler(http.StatusOK))
+	handler := slow(router)
+
+	logs := captureLogs(t)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	if len(reported) != 0 || logs.Len() != 0 {
+		t.Fatalf("fast request reported %v with logs %q, want nothing", reported, logs.String())
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/reports/42", nil))
+	if len(reported) != 1 {
+		t.Fatalf("sink called %d times for a slow request, want 1", len(reported))
+	}
+	if got := reported[0]; got.Method != http.MethodGet || got.Route != "GET /reports/{id}" || got.Status != http.StatusAccepted {
+		t.Errorf("sink meta = %+v, want GET /reports/{id} with status %d", got, http.StatusAccepted)
+	}
+	if out := logs.String(); !strings.Contains(out, "slow request") || !strings.Contains(out, "status=202") {
+		t.Errorf("logs = %q, want a slow request warning with the status", out)
+	}
+}
+
+func TestRateLimiterWithLoadFactor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	load := 0.0
+	limiter := NewRateLimiterWithContext(ctx, rate.Limit(100), 10).WithLoadFactor(func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return load
+	})
+	setLoad := func(factor float64) {
+		mu.Lock()
+		load = factor
+		mu.Unlock()
+	}
+	handler := limiter.Handler(statusHandler(http.StatusOK))
+
+	if got := limitedGet(handler); got != http.StatusOK {
+		t.Fatalf("status = %d, want %d", got, http.StatusOK)
+	}
+	if got := limiter.EffectiveRate(); got != 100 {
+		t.Errorf("EffectiveRate() idle = %v, want 100", got)
+	}
+
+	tests := []struct {
+		load float64
+		want rate.Limit
+	}{
+		{load: 0.75, want: 25},
+		{load: 2, want: 10},
+		{load: -1, want: 100},
+	}
+
+	now := time.Now()
+	for _, tt := range tests {
+		setLoad(tt.load)
+		now = now.Add(2 * loadCheckInterval)
+		limiter.sampleLoad(now)
+
+		if got := limiter.EffectiveRate(); got != tt.want {
+			t.Errorf("EffectiveRate() at load %v = %v, want %v", tt.load, got, tt.want)
+		}
+		limiter.mu.Lock()
+		for ip, c := range limiter.clients {
+			if got := c.limiter.Limit(); got != tt.want {
+				t.Errorf("client %s limit at load %v = %v, want %v", ip, tt.load, got, tt.want)
+			}
+		}
+		limiter.mu.Unlock()
+	}
+
+	setLoad(0.5)
+	limiter.sampleLoad(now.Add(loadCheckInterval / 2))
+	if got := limiter.EffectiveRate(); got != 100 {
+		t.Errorf("EffectiveRate() sampled within the interval = %v, want 100", got)
+	}
+}
+
+func TestLoggerMiddlewareWithFormat(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "hello")
+	})
+	timestamp := `\[\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\]`
+
+	tests := []struct {
+		name   string
+		format LogFormat
+		setup  func(r *http.Request)
+		want   string
+	}{
+		{
+			name:   "common",
+			format: LogFormatCommon,
+			setup:  func(r *http.Request) {},
+			want:   `^192\.0\.2\.1 - - ` + timestamp + ` "POST /api/users\?page=2 HTTP/1\.1" 201 5\n$`,
+		},
+		{
+			name:   "combined",
+			format: LogFormatCombined,
+			setup: func(r *http.Request) {
+				r.Header.Set("Referer", "https://example.com/signup")
+				r.Header.Set("User-Agent", "curl/8.4.0")
+			},
+			want: `^192\.0\.2\.1 - - ` + timestamp + ` "POST /api/users\?page=2 HTTP/1\.1" 201 5 "https://example\.com/signup" "curl/8\.4\.0"\n$`,
+		},
+		{
+			name:   "combined without referer",
+			format: LogFormatCombined,
+			setup: func(r *http.Request) {
+				r.Header.Set("User-Agent", `evil" 200 "forged`)
+			},
+			want: `^192\.0\.2\.1 - - ` + timestamp + ` "POST /api/users\?page=2 HTTP/1\.1" 201 5 "-" "evil\\" 200 \\"forged"\n$`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			r := httptest.NewRequest(http.MethodPost, "/api/users?page=2", nil)
+			tt.setup(r)
+			LoggerMiddlewareWithFormat(tt.format, &out)(handler).ServeHTTP(httptest.NewRecorder(), r)
+
+			if !regexp.MustCompile(tt.want).MatchString(out.String()) {
+				t.Errorf("log line = %q, want it to match %s", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package anvil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrUnknownRateLimiter is returned by RateLimiterRegistry when no limiter is registered under a name.
+var ErrUnknownRateLimiter = errors.New("unknown rate limiter")
+
+// RateLimiterOption configures a RateLimiter registered with RateLimiterRegistry.Register.
+// The RateLimiter builder methods can be used directly, for example
+// func(rl *RateLimiter) { rl.WithRefundOnStatusClass(4) }.
+type RateLimiterOption func(*RateLimiter)
+
+// RateLimiterRegistry holds named rate limiter configurations.
+// Beyond the presets, services often need a handful of limits of their own (e.g.,
+// "login" or "search"). Registering them in one place keeps the numbers out of the
+// route definitions, and every route using a name shares that limiter's client state.
+type RateLimiterRegistry struct {
+	ctx      context.Context
+	mu       sync.RWMutex
+	limiters map[string]*RateLimiter
+}
+
+// NewRateLimiterRegistry creates an empty registry.
+// Limiters registered with it are created with NewRateLimiterWithContext, so their
+// background cleanup stops when ctx is cancelled.
+//
+// Example usage:
+//
+//	limits := NewRateLimiterRegistry(ctx)
+//	limits.Register("login", rate.Limit(1), 5)
+//	limits.Register("search", rate.Limit(20), 40, func(rl *RateLimiter) {
+//	    rl.WithRefundOnStatusClass(4)
+//	})
+//
+//	login, err := limits.Middleware("login")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	router.Route("POST", "/api/login", login(loginHandler))
+//
+// Parameters:
+//   - ctx: The context bounding the background work of registered limiters
+//
+// Returns:
+//   - *RateLimiterRegistry: A new, empty registry
+func NewRateLimiterRegistry(ctx context.Context) *RateLimiterRegistry {
+	return &RateLimiterRegistry{
+		ctx:      ctx,
+		limiters: make(map[string]*RateLimiter),
+	}
+}
+
+// Register creates a limiter with the given rate and burst and stores it under a name.
+// The options are applied in order to the new limiter. Registering a name again keeps
+// its existing limiter, with its client state and background cleanup, and updates it
+// in place with SetRate and the options, so middleware retrieved earlier applies the
+// new limits too.
+//
+// Parameters:
+//   - name: The name of the configuration (e.g., "login")
+//   - r: The number of requests per second allowed for each client
+//   - b: The burst capacity for each client
+//   - opts: Options applied to the limiter
+//
+// Returns:
+//   - *RateLimiter: The registered limiter
+func (reg *RateLimiterRegistry) Register(name string, r rate.Limit, b int, opts ...RateLimiterOption) *RateLimiter {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	rl, ok := reg.limiters[name]
+	if ok {
+		rl.SetRate(r, b)
+	} else {
+		rl = NewRateLimiterWithContext(reg.ctx, r, b)
+		reg.limiters[name] = rl
+	}
+	for _, opt := range opts {
+		opt(rl)
+	}
+	return rl
+}
+
+// Limiter returns the limiter registered under a name.
+//
+// Parameters:
+//   - name: The name of the configuration
+//
+// Returns:
+//   - *RateLimiter: The registered limiter
+//   - error: An error wrapping ErrUnknownRateLimiter if the name is not registered
+func (reg *RateLimiterRegistry) Limiter(name string) (*RateLimiter, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	rl, ok := reg.limiters[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (registered: %v)", ErrUnknownRateLimiter, name, reg.names())
+	}
+	return rl, nil
+}
+
+// Middleware returns the rate limiting middleware of the limiter registered under a name.
+//
+// Parameters:
+//   - name: The name of the configuration
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware applying the named limiter
+//   - error: An error wrapping ErrUnknownRateLimiter if the name is not registered
+func (reg *RateLimiterRegistry) Middleware(name string) (func(http.Handler) http.Handler, error) {
+	rl, err := reg.Limiter(name)
+	if err != nil {
+		return nil, err
+	}
+	return rl.Handler, nil
+}
+
+// names returns the registered names in sorted order. The caller must hold the lock.
+//
+// Returns:
+//   - []string: The registered names
+func (reg *RateLimiterRegistry) names() []string {
+	names := make([]string, 0, len(reg.limiters))
+	for name := range reg.limiters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
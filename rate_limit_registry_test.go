@@ -0,0 +1,48 @@
+package anvil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiterRegistryReregisterUpdatesInPlace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	limits := NewRateLimiterRegistry(ctx)
+
+	first := limits.Register("login", rate.Limit(0.001), 1)
+	login, err := limits.Middleware("login")
+	if err != nil {
+		t.Fatalf("Middleware() error = %v", err)
+	}
+	handler := login(statusHandler(http.StatusOK))
+
+	second := limits.Register("login", rate.Limit(0.001), 3, func(rl *RateLimiter) {
+		rl.WithRefundOnStatusClass(4)
+	})
+	if second != first {
+		t.Fatal("Register() replaced the limiter of an existing name")
+	}
+
+	for i := range 3 {
+		if got := limitedGet(handler); got != http.StatusOK {
+			t.Fatalf("request %d status = %d, want %d with the updated burst", i, got, http.StatusOK)
+		}
+	}
+	if got := limitedGet(handler); got != http.StatusTooManyRequests {
+		t.Errorf("status after the burst = %d, want %d", got, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimiterRegistryUnknownName(t *testing.T) {
+	limits := NewRateLimiterRegistry(context.Background())
+	limits.Register("search", rate.Limit(10), 20)
+
+	if _, err := limits.Middleware("login"); !errors.Is(err, ErrUnknownRateLimiter) {
+		t.Errorf("Middleware(login) error = %v, want %v", err, ErrUnknownRateLimiter)
+	}
+}
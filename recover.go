@@ -0,0 +1,209 @@
+package anvil
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// RequestMeta describes the request during which an error occurred.
+// It is passed to the ErrorReporter so that reports sent to services such as Sentry
+// can be correlated with logs and users, and to the sink of SlowRequestMiddleware.
+type RequestMeta struct {
+	RequestID string // The request ID set by RequestIDMiddleware, if any
+	UserID    string // The authenticated user from JWTAuthMiddleware or ClerkAuthMiddleware, if any
+	SessionID string // The Clerk session ID, if any
+	Method    string // The request method
+	Path      string // The request path
+	Route     string // The matched route pattern, if known (see RoutePatternFromContext)
+	Status    int    // The response status code
+	Panic     bool   // Whether the error was recovered from a panic
+	Stack     []byte // The stack trace of the panic, if Panic is set
+}
+
+// ErrorReporter receives errors together with the request they occurred in.
+// See SetErrorReporter.
+type ErrorReporter func(err error, req *RequestMeta)
+
+// errorReporter holds the reporter shared by HandlerFunc and RecoverMiddleware.
+var errorReporter atomic.Pointer[ErrorReporter]
+
+// SetErrorReporter sets the function that receives errors for reporting.
+// Errors returned by APIFuncs wrapped with HandlerFunc and panics recovered by
+// RecoverMiddleware are passed to the reporter along with request metadata: the
+// request ID, the user and session from the auth middleware, the method, path, route
+// and response status. It is safe to call concurrently with requests being served.
+//
+// RespondWithError has no access to the request, so handlers calling it directly
+// should call ReportError themselves. The reporter is called synchronously on the
+// request goroutine and should hand slow work off to a queue.
+//
+// Example usage:
+//
+//	SetErrorReporter(func(err error, req *RequestMeta) {
+//	    if req.Status < 500 {
+//	        return // client errors are not worth reporting
+//	    }
+//	    sentry.CaptureException(fmt.Errorf("%s %s (request %s, user %s): %w",
+//	        req.Method, req.Route, req.RequestID, req.UserID, err))
+//	})
+//
+// Parameters:
+//   - reporter: The function receiving errors (nil disables reporting)
+func SetErrorReporter(reporter ErrorReporter) {
+	if reporter == nil {
+		errorReporter.Store(nil)
+		return
+	}
+	errorReporter.Store(&reporter)
+}
+
+// ReportError passes an error and the metadata of its request to the reporter set with SetErrorReporter.
+// HandlerFunc and RecoverMiddleware call it automatically; call it directly when
+// responding with RespondWithError outside of HandlerFunc. The status recorded in the
+// metadata is the one RespondWithError would use for the error.
+//
+// Example usage:
+//
+//	if err := process(r); err != nil {
+//	    ReportError(r, err)
+//	    RespondWithError(w, err)
+//	    return
+//	}
+//
+// Parameters:
+//   - r: The HTTP request during which the error occurred
+//   - err: The error to report
+func ReportError(r *http.Request, err error) {
+	reportError(r, err, errorStatus(err), nil)
+}
+
+// RecoverMiddleware creates middleware that recovers from panics in downstream handlers.
+// A panicking handler would otherwise drop the connection without a response. This
+// middleware logs the panic with its stack trace, passes it to the reporter set with
+// SetErrorReporter, and responds with a 500 (Internal Server Error) JSON error if
+// nothing has been written yet. The reported metadata includes the route pattern when
+// the mux is a Router or is wrapped with RecordRoutePattern.
+//
+// Panics with http.ErrAbortHandler are re-raised, since they are the standard way to
+// abort a response deliberately.
+//
+// Example usage:
+//
+//	handler := RequestIDMiddleware(RequestIDOptions{})(RecoverMiddleware(router))
+//
+// Parameters:
+//   - next: The next HTTP handler in the middleware chain
+//
+// Returns:
+//   - http.Handler: A new handler that recovers from panics
+func RecoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := newStatusWriter(w)
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			err, ok := recovered.(error)
+			if !ok {
+				err = fmt.Errorf("%v", recovered)
+			}
+			err = fmt.Errorf("panic: %w", err)
+			stack := debug.Stack()
+
+			slog.Error("recovered from panic",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"error", err.Error(),
+				"stack", string(stack),
+			)
+			reportError(r, err, http.StatusInternalServerError, stack)
+
+			if !sw.wroteHeader {
+				writeError(sw, http.StatusInternalServerError, errors.New(InternalErrorMessage))
+			}
+		}()
+
+		r = WithRoutePattern(r)
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// reportError builds the request metadata and calls the configured reporter, if any.
+//
+// Parameters:
+//   - r: The HTTP request during which the error occurred
+//   - err: The error to report
+//   - status: The status code of the error response
+//   - stack: The panic stack trace (nil for returned errors)
+func reportError(r *http.Request, err error, status int, stack []byte) {
+	reporter := errorReporter.Load()
+	if reporter == nil {
+		return
+	}
+
+	meta := newRequestMeta(r, status)
+	meta.Panic = stack != nil
+	meta.Stack = stack
+
+	(*reporter)(err, &meta)
+}
+
+// newRequestMeta collects the metadata of a request from its context.
+// The route is read from the request itself when it has been through the mux, such as
+// in HandlerFunc, and otherwise from its route pattern holder.
+//
+// Parameters:
+//   - r: The HTTP request
+//   - status: The response status code
+//
+// Returns:
+//   - RequestMeta: The request metadata
+func newRequestMeta(r *http.Request, status int) RequestMeta {
+	ctx := r.Context()
+	meta := RequestMeta{
+		RequestID: RequestIDFromContext(ctx),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Route:     r.Pattern,
+		Status:    status,
+	}
+	if meta.Route == "" {
+		meta.Route = RoutePatternFromContext(ctx)
+	}
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		meta.UserID = claims.ID
+	} else if session, ok := ClerkSessionFromContext(ctx); ok {
+		meta.UserID = session.Subject
+		meta.SessionID = session.SessionID
+	}
+	return meta
+}
+
+// errorStatus returns the status code RespondWithError uses for an error.
+//
+// Parameters:
+//   - err: The error being responded with
+//
+// Returns:
+//   - int: 500 for a wrapped *InternalError, the status of a wrapped *APIError, or 400 (Bad Request)
+func errorStatus(err error) int {
+	var internal *InternalError
+	if errors.As(err, &internal) {
+		return http.StatusInternalServerError
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status != 0 {
+		return apiErr.Status
+	}
+	return http.StatusBadRequest
+}
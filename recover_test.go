@@ -0,0 +1,48 @@
+package anvil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestMetaRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.Handler
+	}{
+		{
+			name: "returned error",
+			handler: HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				return errors.New("boom")
+			}),
+		},
+		{
+			name: "panic",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLogs(t)
+			var reported *RequestMeta
+			SetErrorReporter(func(err error, req *RequestMeta) { reported = req })
+			t.Cleanup(func() { SetErrorReporter(nil) })
+
+			router := NewRouter()
+			router.Route(http.MethodGet, "/items/{id}", tt.handler)
+			RecoverMiddleware(router).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/42", nil))
+
+			if reported == nil {
+				t.Fatal("error was not reported")
+			}
+			if reported.Route != "GET /items/{id}" {
+				t.Errorf("Route = %q, want %q", reported.Route, "GET /items/{id}")
+			}
+		})
+	}
+}
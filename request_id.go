@@ -0,0 +1,141 @@
+package anvil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/arbenlabs/anvil/tools"
+)
+
+const (
+	// DefaultRequestIDHeader is the default header carrying the request/correlation ID.
+	DefaultRequestIDHeader = "X-Request-ID"
+
+	// TraceparentHeader is the W3C Trace Context header.
+	TraceparentHeader = "traceparent"
+)
+
+var (
+	// defaultRequestIDPattern accepts IDs made of URL- and log-safe characters, up to 128 long.
+	defaultRequestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+	// traceparentPattern matches the W3C Trace Context traceparent format:
+	// version-traceid-parentid-flags, all lowercase hex.
+	traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+// RequestIDOptions configures how RequestIDMiddleware validates and generates IDs.
+// Zero values are replaced with sensible defaults.
+type RequestIDOptions struct {
+	Header  string         // Header carrying the ID (defaults to DefaultRequestIDHeader)
+	Pattern *regexp.Regexp // Pattern an incoming ID must match (defaults to 1-128 URL-safe characters)
+	W3C     bool           // Validate and generate IDs in the W3C traceparent format (implied when Header is "traceparent")
+}
+
+// RequestIDMiddleware creates middleware that guarantees every request carries a valid correlation ID.
+// This middleware reads the ID from the configured header and validates it, either
+// against a regular expression or against the W3C Trace Context traceparent format.
+// It is useful when integrating with external tracing systems that reject malformed IDs.
+//
+// The middleware:
+//   - Preserves a valid incoming ID
+//   - Regenerates the ID when the incoming value is malformed
+//   - Generates an ID when none was supplied (a UUID, or a random traceparent in W3C mode)
+//   - Rewrites the request header so downstream handlers and proxies always see a valid ID
+//   - Echoes the ID in the response header and stores it in the request context,
+//     retrievable with RequestIDFromContext
+//
+// Example usage:
+//
+//	router.Use(RequestIDMiddleware(RequestIDOptions{}))
+//	router.Use(RequestIDMiddleware(RequestIDOptions{Header: TraceparentHeader}))
+//
+// Parameters:
+//   - opts: The header, validation and generation options
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that validates and propagates the request ID
+func RequestIDMiddleware(opts RequestIDOptions) func(http.Handler) http.Handler {
+	if opts.Header == "" {
+		opts.Header = DefaultRequestIDHeader
+	}
+	if strings.EqualFold(opts.Header, TraceparentHeader) {
+		opts.W3C = true
+	}
+	if opts.Pattern == nil {
+		opts.Pattern = defaultRequestIDPattern
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(opts.Header)
+
+			var valid bool
+			if opts.W3C {
+				valid = isValidTraceparent(id)
+			} else {
+				valid = opts.Pattern.MatchString(id)
+			}
+
+			if !valid {
+				if opts.W3C {
+					id = generateTraceparent()
+				} else {
+					id = tools.GenerateUUID()
+				}
+				r.Header.Set(opts.Header, id)
+			}
+
+			w.Header().Set(opts.Header, id)
+
+			ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequestIDFromContext returns the request ID stored by RequestIDMiddleware.
+//
+// Parameters:
+//   - ctx: The request context
+//
+// Returns:
+//   - string: The request ID, or an empty string if RequestIDMiddleware did not run
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// isValidTraceparent reports whether the value is a well-formed W3C traceparent.
+// Besides the overall format, the specification forbids version "ff" and all-zero
+// trace and parent IDs.
+//
+// Parameters:
+//   - value: The traceparent header value
+//
+// Returns:
+//   - bool: true if the value is a valid traceparent, false otherwise
+func isValidTraceparent(value string) bool {
+	if !traceparentPattern.MatchString(value) {
+		return false
+	}
+
+	parts := strings.Split(value, "-")
+	return parts[0] != "ff" &&
+		parts[1] != strings.Repeat("0", 32) &&
+		parts[2] != strings.Repeat("0", 16)
+}
+
+// generateTraceparent creates a new version-00, sampled W3C traceparent with random IDs.
+//
+// Returns:
+//   - string: A new traceparent value
+func generateTraceparent() string {
+	ids := make([]byte, 24)
+	rand.Read(ids)
+	return "00-" + hex.EncodeToString(ids[:16]) + "-" + hex.EncodeToString(ids[16:]) + "-01"
+}
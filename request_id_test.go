@@ -0,0 +1,70 @@
+package anvil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+// serveRequestID sends a request with the given header value through RequestIDMiddleware.
+// It returns the ID echoed in the response, the ID the next handler saw in its request
+// header and the ID stored in the context.
+func serveRequestID(opts RequestIDOptions, header, value string) (echoed, downstream, stored string) {
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	if value != "" {
+		r.Header.Set(header, value)
+	}
+	rec := record(RequestIDMiddleware(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Get(header)
+		stored = RequestIDFromContext(r.Context())
+	})), r)
+	return rec.Header().Get(header), downstream, stored
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		name     string
+		opts     RequestIDOptions
+		header   string
+		incoming string
+		want     *regexp.Regexp
+		preserve bool
+	}{
+		{name: "valid id", header: DefaultRequestIDHeader, incoming: "req-123", preserve: true},
+		{name: "invalid id", header: DefaultRequestIDHeader, incoming: "bad id\n", want: defaultRequestIDPattern},
+		{name: "absent id", header: DefaultRequestIDHeader, want: defaultRequestIDPattern},
+		{name: "custom pattern", opts: RequestIDOptions{Pattern: regexp.MustCompile(`^req-\d+$`)}, header: DefaultRequestIDHeader, incoming: "abc", want: defaultRequestIDPattern},
+		{name: "valid traceparent", opts: RequestIDOptions{Header: TraceparentHeader}, header: TraceparentHeader, incoming: traceparent, preserve: true},
+		{name: "zero trace id", opts: RequestIDOptions{Header: TraceparentHeader}, header: TraceparentHeader, incoming: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", want: traceparentPattern},
+		{name: "absent traceparent", opts: RequestIDOptions{Header: TraceparentHeader}, header: TraceparentHeader, want: traceparentPattern},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			echoed, downstream, stored := serveRequestID(tt.opts, tt.header, tt.incoming)
+			if echoed != downstream || echoed != stored {
+				t.Fatalf("echoed %q, downstream %q, stored %q; want the same ID everywhere", echoed, downstream, stored)
+			}
+			if tt.preserve {
+				if echoed != tt.incoming {
+					t.Errorf("ID = %q, want the incoming %q", echoed, tt.incoming)
+				}
+				return
+			}
+			if echoed == tt.incoming || !tt.want.MatchString(echoed) {
+				t.Errorf("ID = %q, want a newly generated ID", echoed)
+			}
+		})
+	}
+}
+
+func TestGenerateTraceparentIsValid(t *testing.T) {
+	for range 10 {
+		if id := generateTraceparent(); !isValidTraceparent(id) {
+			t.Fatalf("generateTraceparent() = %q, want a valid traceparent", id)
+		}
+	}
+}
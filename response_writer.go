@@ -0,0 +1,119 @@
+package anvil
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// statusWriter is an http.ResponseWriter wrapper that records the response status
+// and the number of body bytes written. Middleware use it to observe the outcome of
+// the next handler without buffering the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// newStatusWriter wraps a response writer so its status and size can be observed.
+//
+// Parameters:
+//   - w: The response writer to wrap
+//
+// Returns:
+//   - *statusWriter: The wrapping writer
+func newStatusWriter(w http.ResponseWriter) *statusWriter {
+	return &statusWriter{ResponseWriter: w}
+}
+
+// WriteHeader records the status code and forwards it to the underlying writer.
+// Only the first call is recorded, mirroring net/http semantics.
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.wroteHeader {
+		return
+	}
+	sw.status = status
+	sw.wroteHeader = true
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the number of bytes written, implicitly writing a 200 status first
+// if no status has been written yet.
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher when the underlying writer supports it.
+func (sw *statusWriter) Flush() {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer so http.ResponseController can
+// reach optional interfaces such as deadlines and hijacking.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// Status returns the response status code, or 200 if the handler wrote a body
+// without an explicit status. It returns 0 if nothing has been written yet.
+func (sw *statusWriter) Status() int {
+	return sw.status
+}
+
+// bufferedResponse is an http.ResponseWriter that records a response in memory so it
+// can be replayed to several clients.
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+// Header returns the recorded response headers.
+func (br *bufferedResponse) Header() http.Header {
+	return br.header
+}
+
+// WriteHeader records the status code of the first call.
+func (br *bufferedResponse) WriteHeader(status int) {
+	if br.wroteHeader {
+		return
+	}
+	br.status = status
+	br.wroteHeader = true
+}
+
+// Write records body bytes, implying a 200 status if none was set.
+func (br *bufferedResponse) Write(b []byte) (int, error) {
+	if !br.wroteHeader {
+		br.WriteHeader(http.StatusOK)
+	}
+	return br.body.Write(b)
+}
+
+// replay writes the recorded headers, status and body to w.
+//
+// Parameters:
+//   - w: The response writer of the client receiving the response
+func (br *bufferedResponse) replay(w http.ResponseWriter) {
+	for name, values := range br.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+
+	status := br.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(br.body.Bytes())
+}
@@ -0,0 +1,65 @@
+package anvil
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/arbenlabs/anvil/tools"
+)
+
+// RetryMiddleware creates middleware that transparently retries safe requests on 5xx responses.
+// Read-only endpoints that proxy a flaky downstream often fail transiently. For GET and
+// HEAD requests, this middleware buffers the handler's response and, if its status is
+// 500 or above, discards it and invokes the handler again after a backoff delay, up to
+// attempts times in total. The last response is sent to the client whatever its status.
+//
+// Requests with any other method are never retried, since re-running a non-idempotent
+// handler could repeat its side effects. Because responses are buffered until the handler
+// returns, this middleware is unsuitable for streaming responses. Retrying stops early
+// when the request context is done.
+//
+// Example usage:
+//
+//	retry := RetryMiddleware(3, tools.BackoffConfig{Initial: 50 * time.Millisecond, Jitter: 0.2})
+//	http.Handle("/api/quotes", retry(quotesHandler))
+//
+// Parameters:
+//   - attempts: The maximum number of times the handler is invoked (values below 1 are treated as 1)
+//   - backoff: The backoff configuration used between attempts
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that retries safe requests on 5xx responses
+func RetryMiddleware(attempts int, backoff tools.BackoffConfig) func(http.Handler) http.Handler {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts == 1 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var recorder *bufferedResponse
+			for attempt := 0; attempt < attempts; attempt++ {
+				recorder = &bufferedResponse{header: make(http.Header)}
+				next.ServeHTTP(recorder, r)
+				if recorder.status < http.StatusInternalServerError || attempt == attempts-1 {
+					break
+				}
+
+				timer := time.NewTimer(backoff.Delay(attempt))
+				select {
+				case <-r.Context().Done():
+					timer.Stop()
+					recorder.replay(w)
+					return
+				case <-timer.C:
+				}
+			}
+
+			recorder.replay(w)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package anvil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arbenlabs/anvil/tools"
+)
+
+// flakyHandler fails with 502 the given number of times, then answers 200 with a body.
+func flakyHandler(failures int, calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		if *calls <= failures {
+			w.Header().Set("X-Attempt-Failed", "true")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("upstream failed"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("quotes"))
+	})
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	backoff := tools.BackoffConfig{Initial: time.Millisecond}
+
+	tests := []struct {
+		name     string
+		method   string
+		attempts int
+		failures int
+		status   int
+		body     string
+		calls    int
+	}{
+		{name: "get succeeds on third attempt", method: http.MethodGet, attempts: 3, failures: 2, status: http.StatusOK, body: "quotes", calls: 3},
+		{name: "get exhausts attempts", method: http.MethodGet, attempts: 2, failures: 5, status: http.StatusBadGateway, body: "upstream failed", calls: 2},
+		{name: "post is not retried", method: http.MethodPost, attempts: 3, failures: 2, status: http.StatusBadGateway, body: "upstream failed", calls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			handler := RetryMiddleware(tt.attempts, backoff)(flakyHandler(tt.failures, &calls))
+			rec := record(handler, httptest.NewRequest(tt.method, "/api/quotes", nil))
+
+			if rec.Code != tt.status || rec.Body.String() != tt.body {
+				t.Errorf("response = %d %q, want %d %q", rec.Code, rec.Body.String(), tt.status, tt.body)
+			}
+			if calls != tt.calls {
+				t.Errorf("calls = %d, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRetryMiddlewareDiscardsFailedAttempts(t *testing.T) {
+	calls := 0
+	handler := RetryMiddleware(3, tools.BackoffConfig{Initial: time.Millisecond})(flakyHandler(1, &calls))
+	rec := record(handler, httptest.NewRequest(http.MethodGet, "/api/quotes", nil))
+
+	if got := rec.Header().Get("X-Attempt-Failed"); got != "" {
+		t.Errorf("X-Attempt-Failed = %q, want the headers of the failed attempt discarded", got)
+	}
+}
@@ -0,0 +1,97 @@
+package anvil
+
+import (
+	"context"
+	"net/http"
+)
+
+// routePattern holds the route pattern matched for a request. It is placed in the
+// context before routing and filled in once the mux has dispatched the request, so
+// middleware running outside the mux can read it after the handler returns.
+type routePattern struct {
+	pattern string
+}
+
+// RecordRoutePattern wraps an http.ServeMux so that the matched route pattern is recorded.
+// Middleware such as LoggerMiddleware that runs outside the mux only sees the concrete
+// path (e.g., "/users/123"), which makes logs and metrics high-cardinality. Wrapping
+// the mux with this function records the pattern that matched (e.g.,
+// "GET /users/{id}") for RoutePatternFromContext. Router records the pattern itself
+// and does not need to be wrapped.
+//
+// Example usage:
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("GET /users/{id}", getUserHandler)
+//	server := NewServer("8080").WithHandler(LoggerMiddleware(RecordRoutePattern(mux)))
+//
+// Parameters:
+//   - mux: The mux (or any handler that sets Request.Pattern) to wrap
+//
+// Returns:
+//   - http.Handler: A handler that records the matched route pattern
+func RecordRoutePattern(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer recordRoutePattern(r)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// RoutePatternFromContext returns the route pattern matched for the request.
+// The pattern is available to middleware that runs outside the mux (for example
+// logging or metrics middleware) once the handler has returned, provided the request
+// went through WithRoutePattern and the mux is a Router or is wrapped with
+// RecordRoutePattern. Handlers registered on the mux can
+// read Request.Pattern directly.
+//
+// Example usage:
+//
+//	r = WithRoutePattern(r)
+//	next.ServeHTTP(w, r)
+//	requests.WithLabelValues(RoutePatternFromContext(r.Context())).Inc()
+//
+// Parameters:
+//   - ctx: The request context
+//
+// Returns:
+//   - string: The matched route pattern, or an empty string if it was not recorded
+func RoutePatternFromContext(ctx context.Context) string {
+	if holder, ok := ctx.Value(routePatternContextKey).(*routePattern); ok {
+		return holder.pattern
+	}
+	return ""
+}
+
+// WithRoutePattern returns the request with a route pattern holder in its context.
+// Middleware that reads RoutePatternFromContext after the handler returns must pass
+// the returned request down the chain, so that the mux can fill in the holder. The
+// holder placed by an outer middleware is reused if there is one, so every layer
+// sees the same pattern.
+//
+// Example usage:
+//
+//	r = WithRoutePattern(r)
+//	next.ServeHTTP(w, r)
+//	route := RoutePatternFromContext(r.Context())
+//
+// Parameters:
+//   - r: The HTTP request
+//
+// Returns:
+//   - *http.Request: The request carrying a route pattern holder
+func WithRoutePattern(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(routePatternContextKey).(*routePattern); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), routePatternContextKey, &routePattern{}))
+}
+
+// recordRoutePattern stores the request's matched pattern in its route pattern holder, if any.
+//
+// Parameters:
+//   - r: The HTTP request after the mux has dispatched it
+func recordRoutePattern(r *http.Request) {
+	if holder, ok := r.Context().Value(routePatternContextKey).(*routePattern); ok && r.Pattern != "" {
+		holder.pattern = r.Pattern
+	}
+}
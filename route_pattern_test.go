@@ -0,0 +1,66 @@
+package anvil
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// servedRequest builds a request carrying the local address set by http.Server.
+func servedRequest(method, target string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}
+	return r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, addr))
+}
+
+func TestLoggerMiddlewareLogsRoutePattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{id}", statusHandler(http.StatusOK))
+	router := NewRouter()
+	router.Route(http.MethodGet, "/users/{id}", statusHandler(http.StatusOK))
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+	}{
+		{name: "recorded mux", handler: RecordRoutePattern(mux)},
+		{name: "router", handler: router},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			LoggerMiddleware(tt.handler).ServeHTTP(httptest.NewRecorder(), servedRequest(http.MethodGet, "/users/123"))
+
+			out := logs.String()
+			if !strings.Contains(out, `msg=request `) {
+				t.Errorf("logs = %q, want the request message", out)
+			}
+			if !strings.Contains(out, `route="GET /users/{id}"`) {
+				t.Errorf("logs = %q, want the route pattern", out)
+			}
+		})
+	}
+}
+
+func TestWithRoutePatternReusesHolder(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{id}", statusHandler(http.StatusOK))
+
+	outer := WithRoutePattern(httptest.NewRequest(http.MethodGet, "/users/123", nil))
+	inner := WithRoutePattern(outer)
+	if inner != outer {
+		t.Error("WithRoutePattern() replaced the holder of an outer middleware")
+	}
+
+	RecordRoutePattern(mux).ServeHTTP(httptest.NewRecorder(), inner)
+	if got := RoutePatternFromContext(outer.Context()); got != "GET /users/{id}" {
+		t.Errorf("RoutePatternFromContext() = %q, want %q", got, "GET /users/{id}")
+	}
+	if got := RoutePatternFromContext(context.Background()); got != "" {
+		t.Errorf("RoutePatternFromContext(without holder) = %q, want none", got)
+	}
+}
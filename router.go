@@ -0,0 +1,138 @@
+package anvil
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// NotFoundHandler returns a handler that responds with a JSON 404 (Not Found) error.
+// The default http.ServeMux responds with a plaintext "404 page not found", which is
+// inconsistent with the JSON error shape used by RespondWithError. This handler uses
+// the same error format so clients can parse every error response the same way.
+//
+// Example usage:
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("/", NotFoundHandler())
+//
+// Returns:
+//   - http.Handler: A handler that always responds with a JSON 404 error
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, errors.New("route not found"))
+	})
+}
+
+// MethodNotAllowedHandler returns a handler that responds with a JSON 405 (Method Not Allowed) error.
+// This handler is used when a path exists but does not accept the request method,
+// and follows the same error format as RespondWithError. When allowed methods are
+// given, they are listed in the Allow header, as RFC 9110 requires for 405 responses.
+//
+// Example usage:
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("GET /users", listUsers)
+//	mux.Handle("POST /users", createUser)
+//	mux.Handle("/users", MethodNotAllowedHandler(http.MethodGet, http.MethodPost))
+//
+// Parameters:
+//   - allowed: The methods the path accepts, listed in the Allow header
+//
+// Returns:
+//   - http.Handler: A handler that always responds with a JSON 405 error
+func MethodNotAllowedHandler(allowed ...string) http.Handler {
+	allow := strings.Join(allowed, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allow != "" {
+			w.Header().Set("Allow", allow)
+		}
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	})
+}
+
+// Router is a thin wrapper around http.ServeMux that installs JSON fallbacks.
+// Unknown routes are answered by NotFoundHandler and known paths requested with an
+// unregistered method are answered by MethodNotAllowedHandler, so every error the
+// router produces matches the package's JSON error shape.
+type Router struct {
+	mux   *http.ServeMux
+	mu    sync.Mutex
+	paths map[string][]string // Methods registered per path pattern, in registration order
+}
+
+// NewRouter creates a new Router with JSON 404 and 405 fallbacks installed.
+//
+// Example usage:
+//
+//	router := NewRouter()
+//	router.Route(http.MethodGet, "/users/{id}", HandlerFunc(getUser))
+//	router.Route(http.MethodPost, "/users", HandlerFunc(createUser))
+//
+//	server := NewServer("8080").WithHandler(router)
+//
+// Returns:
+//   - *Router: A new Router instance
+func NewRouter() *Router {
+	rt := &Router{
+		mux:   http.NewServeMux(),
+		paths: make(map[string][]string),
+	}
+	rt.mux.Handle("/", NotFoundHandler())
+	return rt
+}
+
+// Route registers a handler for the given method and path pattern.
+// The pattern uses the http.ServeMux syntax (e.g., "/users/{id}"). The first time a
+// path is registered, a method-less fallback is registered for the same path, so that
+// requests using any other method receive a JSON 405 response instead of a 404. The
+// response's Allow header lists every method registered for the path.
+//
+// Parameters:
+//   - method: The HTTP method to match (e.g., http.MethodGet)
+//   - pattern: The path pattern to match (e.g., "/users/{id}")
+//   - handler: The HTTP handler to serve matching requests
+func (rt *Router) Route(method, pattern string, handler http.Handler) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	method = strings.ToUpper(method)
+	rt.mux.Handle(method+" "+pattern, handler)
+
+	// "/" is already the catch-all 404 handler.
+	if pattern == "/" {
+		return
+	}
+	if _, ok := rt.paths[pattern]; !ok {
+		rt.mux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			MethodNotAllowedHandler(rt.allowedMethods(pattern)...).ServeHTTP(w, r)
+		}))
+	}
+	rt.paths[pattern] = append(rt.paths[pattern], method)
+}
+
+// allowedMethods returns the methods registered for a path pattern.
+//
+// Parameters:
+//   - pattern: The path pattern
+//
+// Returns:
+//   - []string: The registered methods, in registration order
+func (rt *Router) allowedMethods(pattern string) []string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	return append([]string(nil), rt.paths[pattern]...)
+}
+
+// ServeHTTP dispatches the request to the handler registered for its method and path.
+// The matched route pattern is recorded for RoutePatternFromContext.
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - r: The HTTP request to dispatch
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer recordRoutePattern(r) // deferred so the pattern is also recorded when the handler panics
+	rt.mux.ServeHTTP(w, r)
+}
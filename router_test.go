@@ -0,0 +1,107 @@
+package anvil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newUsersRouter returns a router serving GET and POST /users and GET /users/{id}.
+func newUsersRouter() *Router {
+	router := NewRouter()
+	router.Route(http.MethodGet, "/users", statusHandler(http.StatusOK))
+	router.Route(http.MethodPost, "/users", statusHandler(http.StatusCreated))
+	router.Route(http.MethodGet, "/users/{id}", statusHandler(http.StatusOK))
+	return router
+}
+
+func TestRouterJSONFallbacks(t *testing.T) {
+	router := newUsersRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		code   string
+	}{
+		{name: "registered route", method: http.MethodPost, target: "/users", status: http.StatusCreated},
+		{name: "unknown path", method: http.MethodGet, target: "/orders", status: http.StatusNotFound, code: "not_found"},
+		{name: "unregistered method", method: http.MethodDelete, target: "/users/1", status: http.StatusMethodNotAllowed, code: "method_not_allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.code == "" {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if body := decodeErrorBody(t, rec); body["code"] != tt.code {
+				t.Errorf("code = %q, want %q", body["code"], tt.code)
+			}
+		})
+	}
+}
+
+func TestNotFoundHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NotFoundHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if body := decodeErrorBody(t, rec); body["error"] != "route not found" {
+		t.Errorf("error = %q, want route not found", body["error"])
+	}
+}
+
+func TestRouterAllowHeader(t *testing.T) {
+	router := newUsersRouter()
+
+	tests := []struct {
+		method string
+		target string
+		allow  string
+	}{
+		{method: http.MethodDelete, target: "/users", allow: "GET, POST"},
+		{method: http.MethodOptions, target: "/users", allow: "GET, POST"},
+		{method: http.MethodPut, target: "/users/42", allow: "GET"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s status = %d, want %d", tt.method, tt.target, rec.Code, http.StatusMethodNotAllowed)
+		}
+		if got := rec.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s Allow = %q, want %q", tt.method, tt.target, got, tt.allow)
+		}
+	}
+}
+
+func TestMethodNotAllowedHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	MethodNotAllowedHandler(http.MethodGet, http.MethodPost).ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/users", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("response = %d with Allow %q, want 405 with GET, POST", rec.Code, rec.Header().Get("Allow"))
+	}
+	if body := decodeErrorBody(t, rec); body["code"] != CodeMethodNotAllowed {
+		t.Errorf("code = %q, want %q", body["code"], CodeMethodNotAllowed)
+	}
+
+	rec = httptest.NewRecorder()
+	MethodNotAllowedHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/users", nil))
+	if _, ok := rec.Header()["Allow"]; ok {
+		t.Errorf("Allow = %q without allowed methods, want no header", rec.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,74 @@
+package anvil
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// ServerStats is a snapshot of the request concurrency of an HTTPServer (see HTTPServer.Stats).
+type ServerStats struct {
+	InFlight    int64 `json:"in_flight"`     // The number of requests currently being handled
+	Total       int64 `json:"total"`         // The number of requests handled to completion since the server started
+	MaxInFlight int64 `json:"max_in_flight"` // The highest number of requests handled at the same time
+}
+
+// serverStats holds the counters behind ServerStats.
+type serverStats struct {
+	inFlight    atomic.Int64
+	total       atomic.Int64
+	maxInFlight atomic.Int64
+}
+
+// Stats returns a snapshot of the server's request concurrency.
+// The counters cover every request served by Run or Start, are updated atomically
+// and can be read at any time, for example from an admin endpoint or a periodic log
+// line while tuning capacity. Unlike a metrics exporter, Stats is a plain in-process
+// accessor. The snapshot is not taken atomically as a whole, so under load the
+// counters may be off by the requests that started or finished while it was read.
+//
+// Example usage:
+//
+//	server := NewServer("8080").WithHandler(router)
+//	admin.HandleFunc("GET /debug/stats", func(w http.ResponseWriter, r *http.Request) {
+//	    RespondWithSuccess(w, http.StatusOK, server.Stats())
+//	})
+//
+// Returns:
+//   - ServerStats: The current in-flight, total and peak in-flight request counts
+func (h *HTTPServer) Stats() ServerStats {
+	return ServerStats{
+		InFlight:    h.stats.inFlight.Load(),
+		Total:       h.stats.total.Load(),
+		MaxInFlight: h.stats.maxInFlight.Load(),
+	}
+}
+
+// handler wraps a handler so that every request it serves is counted.
+// A nil handler is replaced by http.DefaultServeMux, mirroring http.Server.
+//
+// Parameters:
+//   - next: The handler serving the requests
+//
+// Returns:
+//   - http.Handler: A handler updating the counters around each request
+func (s *serverStats) handler(next http.Handler) http.Handler {
+	if next == nil {
+		next = http.DefaultServeMux
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := s.inFlight.Add(1)
+		for {
+			peak := s.maxInFlight.Load()
+			if current <= peak || s.maxInFlight.CompareAndSwap(peak, current) {
+				break
+			}
+		}
+		defer func() {
+			s.inFlight.Add(-1)
+			s.total.Add(1)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
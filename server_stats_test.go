@@ -0,0 +1,43 @@
+package anvil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestHTTPServerStats(t *testing.T) {
+	const concurrent = 5
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	server := NewServer("8080").WithHandler(blockingHandler(entered, release))
+	handler := server.newServer().Handler
+
+	var wg sync.WaitGroup
+	for range concurrent {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/reports", nil))
+		}()
+	}
+	for range concurrent {
+		<-entered
+	}
+
+	if got := server.Stats(); got != (ServerStats{InFlight: concurrent, MaxInFlight: concurrent}) {
+		t.Errorf("Stats() at the peak = %+v, want %d in flight", got, concurrent)
+	}
+
+	close(release)
+	wg.Wait()
+
+	go func() { <-entered }()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/reports", nil))
+
+	want := ServerStats{InFlight: 0, Total: concurrent + 1, MaxInFlight: concurrent}
+	if got := server.Stats(); got != want {
+		t.Errorf("Stats() after the requests = %+v, want %+v", got, want)
+	}
+}
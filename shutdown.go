@@ -0,0 +1,97 @@
+package anvil
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ShutdownRetryAfter is the Retry-After delay sent with 503 responses while the server shuts down.
+const ShutdownRetryAfter = 5 * time.Second
+
+// errShuttingDown is the client-facing error for requests received during shutdown.
+var errShuttingDown = errors.New("server is shutting down")
+
+// WithDrainDelay keeps the listener open for a while after shutdown begins, answering new requests with 503.
+// This method returns the HTTPServer instance, following the builder pattern for
+// configuration.
+//
+// Once Run receives its shutdown signal, requests that have not started yet are
+// answered with a 503 (Service Unavailable) JSON error carrying Retry-After and
+// "Connection: close", while requests already in flight run to completion. Without a
+// delay, the listener closes right away and clients that still route to this
+// instance get connection errors; with one, load balancers have time to notice the
+// failing health checks (see Draining) and take the instance out of rotation, and
+// clients receive a clean response they can retry elsewhere. The delay counts
+// towards the time the process needs to stop, but not towards ShutdownTimeout.
+//
+// Example usage:
+//
+//	server := NewServer("8080").
+//	    WithHandler(router).
+//	    WithDrainDelay(10 * time.Second)
+//
+// Parameters:
+//   - delay: How long to keep answering new requests with 503 before closing the listener
+//
+// Returns:
+//   - *HTTPServer: The HTTPServer instance
+func (h *HTTPServer) WithDrainDelay(delay time.Duration) *HTTPServer {
+	h.drainDelay = delay
+	return h
+}
+
+// Draining reports whether the server has begun shutting down.
+// Readiness checks can use it to fail as soon as shutdown begins, so load balancers
+// stop sending traffic during the drain delay.
+//
+// Example usage:
+//
+//	readiness := func(ctx context.Context) error {
+//	    if server.Draining() {
+//	        return errors.New("shutting down")
+//	    }
+//	    return nil
+//	}
+//
+// Returns:
+//   - bool: true once shutdown has begun
+func (h *HTTPServer) Draining() bool {
+	return h.draining.Load()
+}
+
+// beginDrain marks the server as draining, runs the shutdown hooks and waits for the drain delay.
+func (h *HTTPServer) beginDrain() {
+	h.draining.Store(true)
+	runHooks(h.onShutdown)
+
+	if h.drainDelay > 0 {
+		slog.Info("draining before shutdown", "delay", h.drainDelay.String())
+		time.Sleep(h.drainDelay)
+	}
+}
+
+// drainHandler wraps a handler so that requests starting while the server drains receive a 503.
+//
+// Parameters:
+//   - next: The handler serving the requests
+//
+// Returns:
+//   - http.Handler: A handler rejecting new requests during shutdown
+func (h *HTTPServer) drainHandler(next http.Handler) http.Handler {
+	if next == nil {
+		next = http.DefaultServeMux
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.draining.Load() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(ShutdownRetryAfter/time.Second)))
+			w.Header().Set("Connection", "close")
+			writeError(w, http.StatusServiceUnavailable, errShuttingDown)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,94 @@
+package anvil
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHTTPServerDrainRejectsLateRequests(t *testing.T) {
+	captureLogs(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	base := "http://" + listener.Addr().String()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/slow", blockingHandler(entered, release))
+	mux.Handle("/", statusHandler(http.StatusOK))
+
+	ready := make(chan struct{})
+	shutdown := make(chan struct{})
+	server := NewServer("0").
+		WithListener(listener).
+		WithHandler(mux).
+		WithDrainDelay(500 * time.Millisecond).
+		OnReady(func() { close(ready) }).
+		OnShutdown(func() { close(shutdown) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx) }()
+	<-ready
+
+	inFlight := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			t.Errorf("in-flight GET error = %v", err)
+			inFlight <- 0
+			return
+		}
+		resp.Body.Close()
+		inFlight <- resp.StatusCode
+	}()
+	<-entered
+
+	if server.Draining() {
+		t.Error("Draining() = true before shutdown")
+	}
+	cancel()
+	<-shutdown
+	if !server.Draining() {
+		t.Error("Draining() = false after shutdown began")
+	}
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get(base + "/users")
+	if err != nil {
+		t.Fatalf("late GET error = %v, want a clean response", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("late request status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "5" {
+		t.Errorf("Retry-After = %q, want 5", got)
+	}
+	if !resp.Close {
+		t.Error("late response does not close the connection")
+	}
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body["error"] != errShuttingDown.Error() {
+		t.Errorf("late response body = %v, %v; want %q", body, err, errShuttingDown)
+	}
+
+	close(release)
+	if got := <-inFlight; got != http.StatusOK {
+		t.Errorf("in-flight request status = %d, want %d", got, http.StatusOK)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() error = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after the drain")
+	}
+}
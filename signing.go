@@ -0,0 +1,157 @@
+package anvil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arbenlabs/anvil/tools"
+)
+
+// errInvalidSignature is the client-facing error for requests whose signature cannot be verified.
+var errInvalidSignature = errors.New("invalid request signature")
+
+// SignatureOptions configures SignatureMiddleware.
+type SignatureOptions struct {
+	// Secret resolves the shared secret of the client named by the key ID header.
+	// Returning an error rejects the request, for example for unknown or disabled clients.
+	Secret func(keyID string) ([]byte, error)
+
+	// RequiredHeaders lists headers that every signature must cover (e.g., "host",
+	// "content-type"), so they cannot be altered without invalidating it.
+	RequiredHeaders []string
+
+	// Tolerance is the maximum age (and clock skew) of the signature timestamp.
+	// It defaults to tools.RequestSignatureTolerance.
+	Tolerance time.Duration
+}
+
+// SignatureMiddleware creates middleware that verifies HMAC-signed server-to-server requests.
+// Clients sign requests with tools.SignRequest: an HMAC-SHA256, keyed with a per-client
+// secret, over a canonical string of the method, path, sorted query parameters,
+// selected headers, timestamp and body hash (see tools.CanonicalRequest). This
+// middleware resolves the secret of the client named by tools.SignatureKeyIDHeader,
+// recomputes the signature and compares it in constant time. On success, the key ID
+// is stored in the request context, where it can be retrieved with
+// SignatureKeyIDFromContext.
+//
+// Requests that are unsigned, signed by an unknown client, do not cover the required
+// headers, carry a stale timestamp or do not match their signature receive a 401
+// (Unauthorized) JSON error; the reason is logged rather than sent to the client.
+// The body is read up to DefaultMaxBodyBytes (larger bodies receive a 413) and
+// restored, so the next handler reads it as usual.
+//
+// A signed request can be replayed unchanged within the tolerance. Endpoints that
+// must not run twice should additionally use DedupeMiddleware or idempotency keys.
+//
+// Example usage:
+//
+//	signed := SignatureMiddleware(SignatureOptions{
+//	    Secret: func(keyID string) ([]byte, error) {
+//	        return clientSecrets.Lookup(keyID)
+//	    },
+//	    RequiredHeaders: []string{"host", "content-type"},
+//	})
+//	http.Handle("/internal/", signed(internalHandler))
+//
+// Parameters:
+//   - opts: The secret resolver, required headers and timestamp tolerance
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that requires a valid request signature
+func SignatureMiddleware(opts SignatureOptions) func(http.Handler) http.Handler {
+	tolerance := opts.Tolerance
+	if tolerance <= 0 {
+		tolerance = tools.RequestSignatureTolerance
+	}
+
+	required := make([]string, 0, len(opts.RequiredHeaders))
+	for _, name := range opts.RequiredHeaders {
+		required = append(required, strings.ToLower(name))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, DefaultMaxBodyBytes))
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					writeError(w, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+					return
+				}
+				writeError(w, http.StatusBadRequest, errors.New("unable to read request body"))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			keyID := r.Header.Get(tools.SignatureKeyIDHeader)
+			if err := verifySignature(r, body, keyID, opts.Secret, required, tolerance); err != nil {
+				slog.Info("rejected request signature",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"key_id", keyID,
+					"error", err.Error(),
+				)
+				writeError(w, http.StatusUnauthorized, errInvalidSignature)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), signatureKeyContextKey, keyID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// SignatureKeyIDFromContext returns the key ID of the client whose signature SignatureMiddleware verified.
+//
+// Parameters:
+//   - ctx: The request context
+//
+// Returns:
+//   - string: The key ID of the signing client
+//   - bool: true if the request was verified by SignatureMiddleware, false otherwise
+func SignatureKeyIDFromContext(ctx context.Context) (string, bool) {
+	keyID, ok := ctx.Value(signatureKeyContextKey).(string)
+	return keyID, ok
+}
+
+// verifySignature checks that a request is signed by a known client and covers the required headers.
+//
+// Parameters:
+//   - r: The HTTP request
+//   - body: The request body
+//   - keyID: The key ID named by the request
+//   - secret: The resolver of client secrets
+//   - required: The lowercased headers the signature must cover
+//   - tolerance: The maximum age of the signature timestamp
+//
+// Returns:
+//   - error: The reason the signature is rejected, or nil if it is valid
+func verifySignature(r *http.Request, body []byte, keyID string, secret func(string) ([]byte, error), required []string, tolerance time.Duration) error {
+	if keyID == "" {
+		return errors.New("missing key id")
+	}
+	if secret == nil {
+		return errors.New("no secret resolver configured")
+	}
+
+	signed := tools.SignedHeaders(r)
+	for _, name := range required {
+		if !slices.Contains(signed, name) {
+			return fmt.Errorf("header %s is not signed", name)
+		}
+	}
+
+	key, err := secret(keyID)
+	if err != nil {
+		return fmt.Errorf("resolving secret: %w", err)
+	}
+	return tools.VerifyRequestSignature(r, body, key, tolerance)
+}
@@ -0,0 +1,98 @@
+package anvil
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arbenlabs/anvil/tools"
+)
+
+// signingSecret is the secret shared with the "reports-service" client.
+var signingSecret = []byte("reports-service-shared-secret")
+
+// signedCharge returns a charge request signed by reports-service over the given headers.
+func signedCharge(t *testing.T, body string, headers ...string) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "https://billing.internal/api/charges?currency=eur", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if err := tools.SignRequest(r, "reports-service", signingSecret, headers...); err != nil {
+		t.Fatalf("SignRequest() error = %v", err)
+	}
+	return r
+}
+
+func TestSignatureMiddleware(t *testing.T) {
+	captureLogs(t)
+	signed := SignatureMiddleware(SignatureOptions{
+		Secret: func(keyID string) ([]byte, error) {
+			if keyID != "reports-service" {
+				return nil, errors.New("unknown client")
+			}
+			return signingSecret, nil
+		},
+		RequiredHeaders: []string{"Host", "Content-Type"},
+	})
+
+	const body = `{"amount":100}`
+	tests := []struct {
+		name   string
+		r      func() *http.Request
+		status int
+	}{
+		{name: "signed", r: func() *http.Request {
+			return signedCharge(t, body, "host", "content-type")
+		}, status: http.StatusOK},
+		{name: "tampered body", r: func() *http.Request {
+			r := signedCharge(t, body, "host", "content-type")
+			r.Body = io.NopCloser(strings.NewReader(`{"amount":100000}`))
+			return r
+		}, status: http.StatusUnauthorized},
+		{name: "expired timestamp", r: func() *http.Request {
+			r := signedCharge(t, body, "host", "content-type")
+			r.Header.Set(tools.SignatureTimestampHeader, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+			return r
+		}, status: http.StatusUnauthorized},
+		{name: "unknown client", r: func() *http.Request {
+			r := signedCharge(t, body, "host", "content-type")
+			r.Header.Set(tools.SignatureKeyIDHeader, "other-service")
+			return r
+		}, status: http.StatusUnauthorized},
+		{name: "required header not signed", r: func() *http.Request {
+			return signedCharge(t, body, "host")
+		}, status: http.StatusUnauthorized},
+		{name: "unsigned", r: func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/api/charges", strings.NewReader(body))
+		}, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keyID, received string
+			handler := signed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				keyID, _ = SignatureKeyIDFromContext(r.Context())
+				read, _ := io.ReadAll(r.Body)
+				received = string(read)
+			}))
+			rec := record(handler, tt.r())
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				if body := decodeErrorBody(t, rec); body["error"] != errInvalidSignature.Error() {
+					t.Errorf("body = %v, want %q", body, errInvalidSignature)
+				}
+				return
+			}
+			if keyID != "reports-service" || received != body {
+				t.Errorf("next handler saw key %q and body %q, want reports-service and the original body", keyID, received)
+			}
+		})
+	}
+}
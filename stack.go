@@ -0,0 +1,38 @@
+package anvil
+
+import (
+	"context"
+	"net/http"
+)
+
+// DefaultStack returns the middleware chain most services put in front of their router.
+// Requests pass, in order, through RequestIDMiddleware (default options),
+// LoggerMiddleware and a per-client rate limiter using the PublicAPIRateLimit preset.
+//
+// The rate limiter is created with NewRateLimiterWithContext, so its background
+// cleanup stops when ctx is cancelled. Pass the same context given to
+// HTTPServer.Run so nothing outlives the server.
+//
+// Example usage:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+//	defer stop()
+//
+//	server := NewServer("8080").WithHandler(DefaultStack(ctx)(router))
+//	if err := server.Run(ctx); err != nil {
+//	    log.Fatal(err)
+//	}
+//
+// Parameters:
+//   - ctx: The server lifecycle context bounding the middleware's background work
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware applying the default chain
+func DefaultStack(ctx context.Context) func(http.Handler) http.Handler {
+	requestID := RequestIDMiddleware(RequestIDOptions{})
+	limiter := PublicAPIRateLimit().NewLimiterWithContext(ctx)
+
+	return func(next http.Handler) http.Handler {
+		return requestID(LoggerMiddleware(limiter.Handler(next)))
+	}
+}
@@ -0,0 +1,89 @@
+package anvil
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cleanupGoroutines counts the running rate limiter cleanup goroutines.
+func cleanupGoroutines() int {
+	buf := make([]byte, 1<<20)
+	for runtime.Stack(buf, true) == len(buf) {
+		buf = make([]byte, 2*len(buf))
+	}
+	buf = buf[:runtime.Stack(buf, true)]
+	return strings.Count(string(buf), "anvil.(*RateLimiter).cleanup(")
+}
+
+// settledCleanupGoroutines counts the cleanup goroutines once those started by earlier
+// tests, which may not have been scheduled yet, are running.
+func settledCleanupGoroutines() int {
+	n := cleanupGoroutines()
+	for {
+		time.Sleep(10 * time.Millisecond)
+		m := cleanupGoroutines()
+		if m == n {
+			return n
+		}
+		n = m
+	}
+}
+
+// waitForCleanupGoroutines polls until the number of cleanup goroutines is want.
+func waitForCleanupGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for cleanupGoroutines() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("cleanup goroutines = %d, want %d", cleanupGoroutines(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRateLimiterCleanupStopsWithContext(t *testing.T) {
+	before := settledCleanupGoroutines()
+	ctx, cancel := context.WithCancel(context.Background())
+	limiter := NewRateLimiterWithContext(ctx, 10, 10)
+	limitedGet(limiter.Handler(statusHandler(http.StatusOK)))
+	waitForCleanupGoroutines(t, before+1)
+
+	cancel()
+	waitForCleanupGoroutines(t, before)
+}
+
+func TestRateLimiterCleanupStartsLazily(t *testing.T) {
+	before := settledCleanupGoroutines()
+	limiter := NewRateLimiter(10, 10)
+	handler := RateLimitPublic(limiter.Handler(statusHandler(http.StatusOK)))
+	if got := cleanupGoroutines(); got != before {
+		t.Fatalf("cleanup goroutines before any request = %d, want %d", got, before)
+	}
+
+	limitedGet(handler)
+	waitForCleanupGoroutines(t, before+2)
+
+	limiter.Close()
+	limiter.Close()
+	waitForCleanupGoroutines(t, before+1)
+}
+
+func TestDefaultStackStopsWithContext(t *testing.T) {
+	before := settledCleanupGoroutines()
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := DefaultStack(ctx)(statusHandler(http.StatusOK))
+
+	captureLogs(t)
+	rec := record(handler, servedRequest(http.MethodGet, "/api"))
+	if rec.Code != http.StatusOK || rec.Header().Get(DefaultRequestIDHeader) == "" {
+		t.Errorf("response = %d with request ID %q, want 200 with an ID", rec.Code, rec.Header().Get(DefaultRequestIDHeader))
+	}
+	waitForCleanupGoroutines(t, before+1)
+
+	cancel()
+	waitForCleanupGoroutines(t, before)
+}
@@ -0,0 +1,322 @@
+package anvil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+const (
+	// StreamStatusTrailer is the trailer in which StreamNDJSON and StreamSSE report
+	// how the stream ended: "ok" or "error".
+	StreamStatusTrailer = "X-Stream-Status"
+
+	// StreamErrorTrailer is the trailer carrying the error message when a stream ends with an error.
+	StreamErrorTrailer = "X-Stream-Error"
+)
+
+// ServeContentStream serves a downloadable file with full Range and conditional request support.
+// This function wraps http.ServeContent, which handles Accept-Ranges, Content-Range,
+// 206 (Partial Content) responses, multi-range requests, and If-Modified-Since /
+// If-Range validation, and adapts it to the package's conventions:
+//
+//   - A Content-Disposition: attachment header is set with the given file name,
+//     correctly encoded for non-ASCII names
+//   - Failures to determine the content size are returned as an error before anything
+//     is written, so HandlerFunc can format them
+//   - Error statuses produced by http.ServeContent (e.g., 416 Range Not Satisfiable)
+//     are answered with a JSON error body instead of plain text
+//
+// The Content-Type is detected from the file name extension, falling back to
+// sniffing the first 512 bytes of the content. Set the Content-Type header before
+// calling this function to override it.
+//
+// Example usage:
+//
+//	func downloadReport(w http.ResponseWriter, r *http.Request) error {
+//	    f, err := os.Open("reports/2024.pdf")
+//	    if err != nil {
+//	        return err
+//	    }
+//	    defer f.Close()
+//	    stat, _ := f.Stat()
+//	    return ServeContentStream(w, r, "2024.pdf", stat.ModTime(), f)
+//	}
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - r: The HTTP request (used for Range and conditional headers)
+//   - name: The file name presented to the client and used for Content-Type detection
+//   - modtime: The last modification time (zero to omit Last-Modified)
+//   - content: The content to serve; it must support seeking
+//
+// Returns:
+//   - error: Any error that occurred while determining the content size
+func ServeContentStream(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.ReadSeeker) error {
+	if _, err := content.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("unable to determine content size: %w", err)
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to rewind content: %w", err)
+	}
+
+	if name != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+
+	http.ServeContent(&jsonErrorWriter{ResponseWriter: w}, r, name, modtime, content)
+	return nil
+}
+
+// jsonErrorWriter replaces plain-text error responses written by net/http helpers
+// (such as http.ServeContent) with the package's JSON error shape. Successful
+// responses pass through unchanged.
+type jsonErrorWriter struct {
+	http.ResponseWriter
+	failed bool
+}
+
+// WriteHeader writes a JSON error body for error statuses and forwards other statuses unchanged.
+func (jw *jsonErrorWriter) WriteHeader(status int) {
+	if status < http.StatusBadRequest {
+		jw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	jw.failed = true
+	jw.Header().Del("Content-Length")
+	jw.Header().Del("Content-Disposition")
+	jw.Header().Del("Content-Type")
+	writeError(jw.ResponseWriter, status, errors.New(http.StatusText(status)))
+}
+
+// Write discards the plain-text body of error responses and forwards everything else.
+func (jw *jsonErrorWriter) Write(b []byte) (int, error) {
+	if jw.failed {
+		return len(b), nil
+	}
+	return jw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController.
+func (jw *jsonErrorWriter) Unwrap() http.ResponseWriter {
+	return jw.ResponseWriter
+}
+
+// DeclareTrailers announces the trailers a response will send after its body.
+// Trailers must be declared in the Trailer header before the status is written, and
+// can then be set with SetTrailer once the body has been streamed. They are only
+// delivered with chunked (HTTP/1.1) or HTTP/2 responses, so the response must not
+// set Content-Length.
+//
+// Example usage:
+//
+//	DeclareTrailers(w, "X-Checksum")
+//	w.WriteHeader(http.StatusOK)
+//	n, _ := io.Copy(io.MultiWriter(w, hash), export)
+//	SetTrailer(w, "X-Checksum", hex.EncodeToString(hash.Sum(nil)))
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - names: The trailer names to declare
+func DeclareTrailers(w http.ResponseWriter, names ...string) {
+	for _, name := range names {
+		w.Header().Add("Trailer", name)
+	}
+}
+
+// SetTrailer sets the value of a trailer declared with DeclareTrailers.
+// Call it after the body has been written; values of undeclared trailers are not sent.
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - name: The declared trailer name
+//   - value: The trailer value
+func SetTrailer(w http.ResponseWriter, name, value string) {
+	w.Header().Set(name, value)
+}
+
+// StreamNDJSON streams values as newline-delimited JSON (application/x-ndjson).
+// The produce function is called with a send function that encodes one value per line
+// and flushes it to the client. The response is started on the first send, so if
+// produce fails before sending anything its error is returned and can be answered
+// with a regular error response, for example by HandlerFunc.
+//
+// Once streaming has started, the status can no longer change. The outcome is then
+// reported in the StreamStatusTrailer ("ok" or "error") and StreamErrorTrailer
+// trailers. An error from produce is logged, passed to the reporter set with
+// SetErrorReporter and described in the trailer like RespondWithError would describe
+// it, so an *InternalError only exposes the generic message; nil is returned, since
+// the response is already complete.
+//
+// Example usage:
+//
+//	func exportUsers(w http.ResponseWriter, r *http.Request) error {
+//	    return StreamNDJSON(w, r, func(send func(any) error) error {
+//	        rows, err := db.QueryContext(r.Context(), "SELECT id, email FROM users")
+//	        if err != nil {
+//	            return NewInternalError(err)
+//	        }
+//	        defer rows.Close()
+//	        for rows.Next() {
+//	            var u User
+//	            if err := rows.Scan(&u.ID, &u.Email); err != nil {
+//	                return NewInternalError(err)
+//	            }
+//	            if err := send(u); err != nil {
+//	                return err
+//	            }
+//	        }
+//	        return rows.Err()
+//	    })
+//	}
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - r: The HTTP request (used for error reporting)
+//   - produce: The function sending the values
+//
+// Returns:
+//   - error: The error of produce if it failed before anything was sent, nil otherwise
+func StreamNDJSON(w http.ResponseWriter, r *http.Request, produce func(send func(v any) error) error) error {
+	sw := newStreamWriter(w, "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	return sw.finish(r, produce(func(v any) error {
+		sw.start()
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return sw.flush()
+	}))
+}
+
+// StreamSSE streams values as server-sent events (text/event-stream).
+// The produce function is called with a send function that writes one event with the
+// given event name (empty for the default "message" event) and the value encoded as
+// JSON in its data field, then flushes it to the client. The response is started, the
+// error is handled and the outcome is reported in trailers like StreamNDJSON.
+//
+// Example usage:
+//
+//	func progress(w http.ResponseWriter, r *http.Request) error {
+//	    return StreamSSE(w, r, func(send func(string, any) error) error {
+//	        for p := range job.Progress(r.Context()) {
+//	            if err := send("progress", p); err != nil {
+//	                return err
+//	            }
+//	        }
+//	        return job.Err()
+//	    })
+//	}
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - r: The HTTP request (used for error reporting)
+//   - produce: The function sending the events
+//
+// Returns:
+//   - error: The error of produce if it failed before anything was sent, nil otherwise
+func StreamSSE(w http.ResponseWriter, r *http.Request, produce func(send func(event string, v any) error) error) error {
+	sw := newStreamWriter(w, "text/event-stream")
+	return sw.finish(r, produce(func(event string, v any) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		sw.start()
+		if event != "" {
+			if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		return sw.flush()
+	}))
+}
+
+// streamWriter starts streamed responses lazily and reports their outcome in trailers.
+type streamWriter struct {
+	w           http.ResponseWriter
+	rc          *http.ResponseController
+	contentType string
+	started     bool
+}
+
+// newStreamWriter creates a stream writer for a response of the given media type.
+//
+// Parameters:
+//   - w: The HTTP response writer
+//   - contentType: The media type of the stream
+//
+// Returns:
+//   - *streamWriter: A stream writer that has not started the response yet
+func newStreamWriter(w http.ResponseWriter, contentType string) *streamWriter {
+	return &streamWriter{w: w, rc: http.NewResponseController(w), contentType: contentType}
+}
+
+// start declares the outcome trailers and writes the headers, once.
+func (sw *streamWriter) start() {
+	if sw.started {
+		return
+	}
+	sw.started = true
+
+	h := sw.w.Header()
+	h.Set("Content-Type", sw.contentType)
+	h.Set("Cache-Control", "no-cache")
+	h.Del("Content-Length")
+	DeclareTrailers(sw.w, StreamStatusTrailer, StreamErrorTrailer)
+	sw.w.WriteHeader(http.StatusOK)
+}
+
+// flush sends buffered data to the client, ignoring writers that cannot flush.
+//
+// Returns:
+//   - error: Any error that occurred while flushing
+func (sw *streamWriter) flush() error {
+	if err := sw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// finish completes the stream and reports its outcome.
+// A stream that never started returns err for a regular error response; an empty
+// successful stream is started so the client receives the headers and trailers.
+//
+// Parameters:
+//   - r: The HTTP request (used for error reporting)
+//   - err: The error returned by the producer
+//
+// Returns:
+//   - error: err if the stream never started, nil otherwise
+func (sw *streamWriter) finish(r *http.Request, err error) error {
+	if err != nil && !sw.started {
+		return err
+	}
+	sw.start()
+
+	if err == nil {
+		SetTrailer(sw.w, StreamStatusTrailer, "ok")
+		return nil
+	}
+
+	status := errorStatus(err)
+	ReportError(r, err)
+	LoggerFromContext(r.Context()).Error("stream ended with error",
+		"request_id", RequestIDFromContext(r.Context()),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err.Error(),
+	)
+	SetTrailer(sw.w, StreamStatusTrailer, "error")
+	SetTrailer(sw.w, StreamErrorTrailer, formatError(status, err)["error"])
+	return nil
+}
@@ -0,0 +1,172 @@
+package anvil
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveDownload serves the fixture report through ServeContentStream.
+func serveDownload(t *testing.T, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	modtime := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	if err := ServeContentStream(rec, r, "report 2024.txt", modtime, strings.NewReader("0123456789")); err != nil {
+		t.Fatalf("ServeContentStream() error = %v", err)
+	}
+	return rec
+}
+
+func TestServeContentStreamFull(t *testing.T) {
+	rec := serveDownload(t, httptest.NewRequest(http.MethodGet, "/download", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Fatalf("response = %d %q, want 200 with the full content", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Errorf("Accept-Ranges = %q, want bytes", got)
+	}
+	if got, want := rec.Header().Get("Content-Disposition"), `attachment; filename="report 2024.txt"`; got != want {
+		t.Errorf("Content-Disposition = %q, want %q", got, want)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+}
+
+func TestServeContentStreamRange(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/download", nil)
+	r.Header.Set("Range", "bytes=2-5")
+	rec := serveDownload(t, r)
+
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "2345" {
+		t.Fatalf("response = %d %q, want 206 with bytes 2-5", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Errorf("Content-Range = %q, want bytes 2-5/10", got)
+	}
+}
+
+func TestServeContentStreamErrorsAsJSON(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/download", nil)
+	r.Header.Set("Range", "bytes=20-30")
+	rec := serveDownload(t, r)
+
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestedRangeNotSatisfiable)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != "" {
+		t.Errorf("Content-Disposition = %q on an error response, want none", got)
+	}
+	if body := decodeErrorBody(t, rec); body["error"] != http.StatusText(http.StatusRequestedRangeNotSatisfiable) {
+		t.Errorf("error = %q, want the status text", body["error"])
+	}
+}
+
+func TestServeContentStreamNotModified(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/download", nil)
+	r.Header.Set("If-Modified-Since", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+	if rec := serveDownload(t, r); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("response = %d %q, want 304 with no body", rec.Code, rec.Body.String())
+	}
+}
+
+// streamedResponse serves handler over HTTP and returns the response with its body fully read.
+func streamedResponse(t *testing.T, handler http.Handler) (*http.Response, string) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	return resp, string(body)
+}
+
+func TestStreamNDJSONTrailers(t *testing.T) {
+	captureLogs(t)
+	tests := []struct {
+		name    string
+		failure error
+		status  string
+		message string
+	}{
+		{name: "ok", status: "ok"},
+		{name: "internal error", failure: NewInternalError(errors.New("cursor closed")), status: "error", message: InternalErrorMessage},
+		{name: "api error", failure: NewAPIError(http.StatusConflict, "", "export cancelled"), status: "error", message: "export cancelled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := streamedResponse(t, HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				return StreamNDJSON(w, r, func(send func(any) error) error {
+					for i := range 2 {
+						if err := send(map[string]int{"id": i}); err != nil {
+							return err
+						}
+					}
+					return tt.failure
+				})
+			}))
+
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+				t.Errorf("response = %d %s, want 200 application/x-ndjson", resp.StatusCode, resp.Header.Get("Content-Type"))
+			}
+			if body != "{\"id\":0}\n{\"id\":1}\n" {
+				t.Errorf("body = %q, want two NDJSON lines", body)
+			}
+			if got := resp.Trailer.Get(StreamStatusTrailer); got != tt.status {
+				t.Errorf("%s = %q, want %q", StreamStatusTrailer, got, tt.status)
+			}
+			if got := resp.Trailer.Get(StreamErrorTrailer); got != tt.message {
+				t.Errorf("%s = %q, want %q", StreamErrorTrailer, got, tt.message)
+			}
+		})
+	}
+}
+
+func TestStreamSSETrailers(t *testing.T) {
+	resp, body := streamedResponse(t, HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return StreamSSE(w, r, func(send func(string, any) error) error {
+			if err := send("progress", map[string]int{"done": 50}); err != nil {
+				return err
+			}
+			return send("", map[string]int{"done": 100})
+		})
+	}))
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	if want := "event: progress\ndata: {\"done\":50}\n\ndata: {\"done\":100}\n\n"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+	if got := resp.Trailer.Get(StreamStatusTrailer); got != "ok" {
+		t.Errorf("%s = %q, want ok", StreamStatusTrailer, got)
+	}
+}
+
+func TestStreamErrorBeforeFirstSend(t *testing.T) {
+	resp, _ := streamedResponse(t, HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return StreamNDJSON(w, r, func(send func(any) error) error {
+			return NewAPIError(http.StatusNotFound, "", "export not found")
+		})
+	}))
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d from a regular error response", resp.StatusCode, http.StatusNotFound)
+	}
+	if got := resp.Trailer.Get(StreamStatusTrailer); got != "" {
+		t.Errorf("%s = %q, want none before the stream started", StreamStatusTrailer, got)
+	}
+}
@@ -0,0 +1,246 @@
+package anvil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errRequestTimeout is the client-facing error for requests that exceed their route timeout.
+var errRequestTimeout = errors.New("request timed out")
+
+// RouteTimeoutMiddleware creates middleware that enforces a request deadline chosen per route pattern.
+// Report exports may legitimately take a minute while everything else should fail
+// within seconds, so one global deadline is either too tight or too loose. The
+// deadline is looked up by the request's route pattern (Request.Pattern, e.g.,
+// "GET /reports/{id}"): first the full pattern, then its path without the method
+// (e.g., "/reports/{id}") so one override can cover every method; requests matching
+// no override use defaults. A deadline <= 0 disables the timeout for those routes.
+//
+// The request context carries the deadline, so handlers and the calls they make
+// should stop once it is done. When the deadline passes, the middleware responds
+// with a 503 (Service Unavailable) JSON error and discards anything the handler
+// writes afterwards; if the handler had already started its response, the response
+// is cut off instead. Panics in the handler are re-raised so RecoverMiddleware can
+// handle them; a panic after the deadline, when the middleware has already
+// returned, is logged with its stack trace and passed to the reporter set with
+// SetErrorReporter instead.
+//
+// Request.Pattern is only known once the mux has matched the request, so the
+// middleware must wrap the handlers registered on the mux rather than the mux
+// itself; outside the mux every request uses defaults.
+//
+// Example usage:
+//
+//	timeouts := RouteTimeoutMiddleware(5*time.Second, map[string]time.Duration{
+//	    "GET /reports/{id}/export": time.Minute,
+//	    "/uploads":                 2 * time.Minute,
+//	})
+//
+//	router := NewRouter()
+//	router.Route(http.MethodGet, "/users/{id}", timeouts(HandlerFunc(getUser)))
+//	router.Route(http.MethodGet, "/reports/{id}/export", timeouts(HandlerFunc(exportReport)))
+//	router.Route(http.MethodPost, "/uploads", timeouts(HandlerFunc(upload)))
+//
+// Parameters:
+//   - defaults: The deadline for routes without an override (no timeout if <= 0)
+//   - overrides: Deadlines keyed by route pattern, with or without the method
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces the route deadlines
+func RouteTimeoutMiddleware(defaults time.Duration, overrides map[string]time.Duration) func(http.Handler) http.Handler {
+	timeouts := make(map[string]time.Duration, len(overrides))
+	for pattern, timeout := range overrides {
+		timeouts[pattern] = timeout
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := routeTimeout(r.Pattern, defaults, timeouts)
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{w: w, header: make(http.Header), ctx: ctx}
+			done := make(chan struct{})
+			panicked := make(chan any)
+			returned := make(chan struct{})
+			defer close(returned)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						select {
+						case panicked <- p:
+						case <-returned:
+							reportLatePanic(r, p)
+						}
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+			case <-ctx.Done():
+				tw.timeout()
+			}
+		})
+	}
+}
+
+// reportLatePanic logs and reports a handler panic that happened after the middleware
+// returned, when nothing is left to re-raise it to.
+//
+// Parameters:
+//   - r: The HTTP request whose handler panicked
+//   - recovered: The value recovered from the panic
+func reportLatePanic(r *http.Request, recovered any) {
+	if recovered == http.ErrAbortHandler {
+		return
+	}
+
+	err, ok := recovered.(error)
+	if !ok {
+		err = fmt.Errorf("%v", recovered)
+	}
+	err = fmt.Errorf("panic after request timeout: %w", err)
+	stack := debug.Stack()
+
+	slog.Error("recovered from panic after request timeout",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err.Error(),
+		"stack", string(stack),
+	)
+	reportError(r, err, http.StatusServiceUnavailable, stack)
+}
+
+// routeTimeout returns the deadline configured for a route pattern.
+//
+// Parameters:
+//   - pattern: The matched route pattern (e.g., "GET /users/{id}")
+//   - defaults: The deadline used when no override matches
+//   - overrides: Deadlines keyed by route pattern, with or without the method
+//
+// Returns:
+//   - time.Duration: The deadline for the route
+func routeTimeout(pattern string, defaults time.Duration, overrides map[string]time.Duration) time.Duration {
+	if pattern == "" {
+		return defaults
+	}
+	if timeout, ok := overrides[pattern]; ok {
+		return timeout
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		if timeout, ok := overrides[strings.TrimSpace(path)]; ok {
+			return timeout
+		}
+	}
+	return defaults
+}
+
+// timeoutWriter passes a handler's response through until its deadline passes.
+// The handler gets its own header map, copied to the real response when it first
+// writes, so it can keep touching headers after the middleware has returned. Writes
+// are checked against the deadline too, so a handler that answers its cancelled
+// context before the middleware notices cannot replace the 503.
+type timeoutWriter struct {
+	w           http.ResponseWriter
+	ctx         context.Context
+	header      http.Header
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+// Header returns the handler's response headers.
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// WriteHeader forwards the headers and status unless the deadline has passed.
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return
+	}
+	tw.writeHeader(status)
+}
+
+// Write forwards the body unless the deadline has passed.
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(b)
+}
+
+// Flush implements http.Flusher when the underlying writer supports it.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeHeader copies the handler's headers and writes the status once.
+// The caller must hold the lock.
+func (tw *timeoutWriter) writeHeader(status int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	for name, values := range tw.header {
+		tw.w.Header()[name] = append([]string(nil), values...)
+	}
+	tw.w.WriteHeader(status)
+}
+
+// timeout stops forwarding the handler's output once the request context is done.
+// The 503 response is written only if the deadline passed and nothing was written
+// yet; a request cancelled by the client gets no response.
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.expired()
+}
+
+// expired reports whether output must be discarded, writing the 503 response the
+// first time the deadline is found to have passed. The caller must hold the lock.
+func (tw *timeoutWriter) expired() bool {
+	if tw.timedOut {
+		return true
+	}
+	err := tw.ctx.Err()
+	if err == nil {
+		return false
+	}
+	tw.timedOut = true
+	if !tw.wroteHeader && errors.Is(err, context.DeadlineExceeded) {
+		writeError(tw.w, http.StatusServiceUnavailable, errRequestTimeout)
+	}
+	return true
+}
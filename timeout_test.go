@@ -0,0 +1,106 @@
+package anvil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowHandler answers after delay, or stops when the request context is done.
+func slowHandler(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			w.Write([]byte("done"))
+		case <-r.Context().Done():
+		}
+	}
+}
+
+func TestRouteTimeoutMiddlewareOverrides(t *testing.T) {
+	timeouts := RouteTimeoutMiddleware(20*time.Millisecond, map[string]time.Duration{
+		"GET /reports/{id}/export": time.Second,
+		"/uploads":                 time.Second,
+	})
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{id}", timeouts(slowHandler(200*time.Millisecond)))
+	mux.Handle("GET /reports/{id}/export", timeouts(slowHandler(50*time.Millisecond)))
+	mux.Handle("POST /uploads", timeouts(slowHandler(50*time.Millisecond)))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+	}{
+		{name: "default timeout aborts", method: http.MethodGet, target: "/users/1", status: http.StatusServiceUnavailable},
+		{name: "pattern override survives", method: http.MethodGet, target: "/reports/1/export", status: http.StatusOK},
+		{name: "path override survives", method: http.MethodPost, target: "/uploads", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestRouteTimeoutMiddlewareDisabled(t *testing.T) {
+	handler := RouteTimeoutMiddleware(0, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("request has a deadline although timeouts are disabled")
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestRouteTimeoutMiddlewareReraisesPanics(t *testing.T) {
+	handler := RouteTimeoutMiddleware(time.Second, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("recover() = %v, want the handler panic", p)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestRouteTimeoutMiddlewareLogsLatePanics(t *testing.T) {
+	logs := captureLogs(t)
+	reported := make(chan *RequestMeta, 1)
+	SetErrorReporter(func(err error, req *RequestMeta) { reported <- req })
+	t.Cleanup(func() { SetErrorReporter(nil) })
+
+	handler := RouteTimeoutMiddleware(10*time.Millisecond, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	select {
+	case req := <-reported:
+		if req.Status != http.StatusServiceUnavailable {
+			t.Errorf("reported status = %d, want %d", req.Status, http.StatusServiceUnavailable)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("late panic was not reported")
+	}
+	if !strings.Contains(logs.String(), "recovered from panic after request timeout") {
+		t.Errorf("logs = %q, want the late panic", logs)
+	}
+}
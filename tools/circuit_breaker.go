@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailureThreshold is the default number of consecutive failures that opens a circuit breaker.
+	DefaultFailureThreshold = 5
+
+	// DefaultCircuitCooldown is the default time an open circuit breaker rejects calls before allowing a trial call.
+	DefaultCircuitCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned by CircuitBreaker.Execute when the call is rejected without running.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through and counts consecutive failures.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen rejects every call with ErrCircuitOpen until the cooldown has passed.
+	CircuitOpen
+
+	// CircuitHalfOpen lets a limited number of trial calls through to probe the dependency.
+	CircuitHalfOpen
+)
+
+// String returns the lowercase name of the state (e.g., "half-open").
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerOptions configures a CircuitBreaker.
+// Zero values are replaced with the package defaults, so CircuitBreakerOptions{} is a
+// sensible starting point.
+type CircuitBreakerOptions struct {
+	FailureThreshold int              // Consecutive failures that open the breaker (defaults to DefaultFailureThreshold)
+	Cooldown         time.Duration    // How long the breaker stays open before a trial call (defaults to DefaultCircuitCooldown)
+	HalfOpenCalls    int              // Concurrent trial calls allowed while half-open (defaults to 1)
+	IsFailure        func(error) bool // Reports whether an error counts as a failure (defaults to err != nil)
+}
+
+// CircuitBreaker stops calling a failing dependency so callers fail fast.
+// It starts closed. After FailureThreshold consecutive failures it opens and rejects
+// calls with ErrCircuitOpen for the cooldown, sparing both the dependency and the
+// callers waiting on its timeouts. It then turns half-open and lets HalfOpenCalls
+// trial calls through: a successful trial closes it again, a failed one reopens it
+// for another cooldown. A CircuitBreaker is safe for concurrent use.
+type CircuitBreaker struct {
+	opts CircuitBreakerOptions
+
+	mu         sync.Mutex
+	state      CircuitState
+	failures   int       // Consecutive failures while closed
+	openedAt   time.Time // When the breaker last opened
+	trials     int       // Trial calls in progress while half-open
+	generation uint64    // Incremented on every state change, so late results of earlier states are ignored
+}
+
+// NewCircuitBreaker creates a closed circuit breaker.
+//
+// Example usage:
+//
+//	payments := NewCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 3, Cooldown: 10 * time.Second})
+//
+//	err := payments.Execute(func() error {
+//	    return client.Charge(ctx, order)
+//	})
+//	if errors.Is(err, ErrCircuitOpen) {
+//	    // the payment provider is failing: answer 503 right away
+//	}
+//
+// Parameters:
+//   - opts: The failure threshold, cooldown and failure classification
+//
+// Returns:
+//   - *CircuitBreaker: A new circuit breaker in the closed state
+func NewCircuitBreaker(opts CircuitBreakerOptions) *CircuitBreaker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = DefaultFailureThreshold
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = DefaultCircuitCooldown
+	}
+	if opts.HalfOpenCalls <= 0 {
+		opts.HalfOpenCalls = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = func(err error) bool { return err != nil }
+	}
+	return &CircuitBreaker{opts: opts}
+}
+
+// Execute runs fn unless the breaker is open, and records its outcome.
+// The error of fn is returned unchanged; a rejected call returns ErrCircuitOpen
+// without running fn. A panic in fn is recorded as a failure and re-raised.
+//
+// Parameters:
+//   - fn: The call to the protected dependency
+//
+// Returns:
+//   - error: The error of fn, or ErrCircuitOpen if the call was rejected
+func (cb *CircuitBreaker) Execute(fn func() error) error {
+	generation, err := cb.before()
+	if err != nil {
+		return err
+	}
+
+	failed := true
+	defer func() {
+		cb.after(generation, failed)
+	}()
+
+	err = fn()
+	failed = cb.opts.IsFailure(err)
+	return err
+}
+
+// State returns the current state of the breaker.
+// An open breaker whose cooldown has passed is reported as half-open.
+//
+// Returns:
+//   - CircuitState: The current state
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.opts.Cooldown {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// before admits or rejects a call.
+//
+// Returns:
+//   - uint64: The generation the call was admitted in
+//   - error: ErrCircuitOpen if the call is rejected
+func (cb *CircuitBreaker) before() (uint64, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen {
+		if time.Since(cb.openedAt) < cb.opts.Cooldown {
+			return 0, ErrCircuitOpen
+		}
+		cb.setState(CircuitHalfOpen)
+	}
+
+	if cb.state == CircuitHalfOpen {
+		if cb.trials >= cb.opts.HalfOpenCalls {
+			return 0, ErrCircuitOpen
+		}
+		cb.trials++
+	}
+	return cb.generation, nil
+}
+
+// after records the outcome of a call admitted in the given generation.
+//
+// Parameters:
+//   - generation: The generation returned by before
+//   - failed: Whether the call failed
+func (cb *CircuitBreaker) after(generation uint64, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if generation != cb.generation {
+		return
+	}
+
+	switch cb.state {
+	case CircuitClosed:
+		if !failed {
+			cb.failures = 0
+			return
+		}
+		cb.failures++
+		if cb.failures >= cb.opts.FailureThreshold {
+			cb.setState(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		cb.trials--
+		if failed {
+			cb.setState(CircuitOpen)
+		} else {
+			cb.setState(CircuitClosed)
+		}
+	}
+}
+
+// setState moves the breaker to a new state and resets the per-state counters.
+// The caller must hold the lock.
+//
+// Parameters:
+//   - state: The new state
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	cb.state = state
+	cb.generation++
+	cb.failures = 0
+	cb.trials = 0
+	if state == CircuitOpen {
+		cb.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// errDownstream is the failure returned by the flaky downstream in these tests.
+var errDownstream = errors.New("downstream unavailable")
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	cb := NewCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 3, Cooldown: cooldown})
+	fail := func() error { return errDownstream }
+
+	for i := range 3 {
+		if err := cb.Execute(fail); !errors.Is(err, errDownstream) {
+			t.Fatalf("call %d error = %v, want %v", i, err, errDownstream)
+		}
+	}
+	if got := cb.State(); got != CircuitOpen {
+		t.Fatalf("State() after 3 failures = %s, want open", got)
+	}
+
+	calls := 0
+	if err := cb.Execute(func() error { calls++; return nil }); !errors.Is(err, ErrCircuitOpen) || calls != 0 {
+		t.Errorf("Execute() while open = %v with %d calls, want %v without calling fn", err, calls, ErrCircuitOpen)
+	}
+
+	time.Sleep(2 * cooldown)
+	if got := cb.State(); got != CircuitHalfOpen {
+		t.Errorf("State() after the cooldown = %s, want half-open", got)
+	}
+	if err := cb.Execute(func() error { return nil }); err != nil {
+		t.Fatalf("trial call error = %v, want nil", err)
+	}
+	if got := cb.State(); got != CircuitClosed {
+		t.Errorf("State() after a successful trial = %s, want closed", got)
+	}
+}
+
+func TestCircuitBreakerFailedTrialReopens(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	cb := NewCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 1, Cooldown: cooldown})
+	cb.Execute(func() error { return errDownstream })
+
+	time.Sleep(2 * cooldown)
+	if err := cb.Execute(func() error { return errDownstream }); !errors.Is(err, errDownstream) {
+		t.Fatalf("trial call error = %v, want %v", err, errDownstream)
+	}
+	if got := cb.State(); got != CircuitOpen {
+		t.Errorf("State() after a failed trial = %s, want open", got)
+	}
+	if err := cb.Execute(func() error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Execute() after a failed trial error = %v, want %v", err, ErrCircuitOpen)
+	}
+}
+
+func TestCircuitBreakerHalfOpenLimitsTrials(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	cb := NewCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 1, Cooldown: cooldown})
+	cb.Execute(func() error { return errDownstream })
+	time.Sleep(2 * cooldown)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- cb.Execute(func() error {
+			close(entered)
+			<-release
+			return nil
+		})
+	}()
+	<-entered
+
+	if err := cb.Execute(func() error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second trial error = %v, want %v", err, ErrCircuitOpen)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("first trial error = %v, want nil", err)
+	}
+}
+
+func TestCircuitBreakerIsFailure(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerOptions{
+		FailureThreshold: 2,
+		IsFailure: func(err error) bool {
+			return err != nil && !errors.Is(err, context.Canceled)
+		},
+	})
+
+	for range 5 {
+		cb.Execute(func() error { return context.Canceled })
+	}
+	if got := cb.State(); got != CircuitClosed {
+		t.Errorf("State() after ignored errors = %s, want closed", got)
+	}
+
+	cb.Execute(func() error { return errDownstream })
+	cb.Execute(func() error { return nil })
+	cb.Execute(func() error { return errDownstream })
+	if got := cb.State(); got != CircuitClosed {
+		t.Errorf("State() after non-consecutive failures = %s, want closed", got)
+	}
+}
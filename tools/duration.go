@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// errInvalidISODuration is returned when an input is not a well-formed ISO-8601 duration.
+	errInvalidISODuration = errors.New("invalid ISO-8601 duration")
+
+	// errAmbiguousISODuration is returned for durations with years or months, whose length depends on the calendar.
+	errAmbiguousISODuration = errors.New("ISO-8601 durations with years or months have no fixed length")
+)
+
+// isoDurationUnits maps each designator to its length, for the date part and the time part.
+var isoDurationUnits = map[bool]map[byte]time.Duration{
+	false: {'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour},
+	true:  {'H': time.Hour, 'M': time.Minute, 'S': time.Second},
+}
+
+// isoDurationOrder lists the designators of each part in the order ISO-8601 requires.
+var isoDurationOrder = map[bool]string{false: "YMWD", true: "HMS"}
+
+// ParseISODuration parses an ISO-8601 duration such as "P3DT2H30M" or "PT1.5S".
+// Go's time.ParseDuration does not understand this format, which is common in
+// configuration files and API inputs.
+//
+// Weeks, days, hours, minutes and seconds are supported, with a day counted as
+// exactly 24 hours. Components must appear in ISO order, each at most once, and may
+// carry a decimal fraction (with "." or ","). A leading "-" negates the duration.
+// Years and months are rejected because their length depends on the calendar date the
+// duration is applied to; use time.Time.AddDate for those.
+//
+// Example usage:
+//
+//	d, err := ParseISODuration("P1DT12H")
+//	// Result: 36h0m0s
+//
+//	_, err = ParseISODuration("P1Y2M10DT2H30M")
+//	// err: ISO-8601 durations with years or months have no fixed length
+//
+// Parameters:
+//   - s: The ISO-8601 duration to parse
+//
+// Returns:
+//   - time.Duration: The parsed duration
+//   - error: An error if the input is malformed, uses years or months, or overflows time.Duration
+func ParseISODuration(s string) (time.Duration, error) {
+	negative := strings.HasPrefix(s, "-")
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(s, "-"), "P")
+	if !ok || rest == "" {
+		return 0, errInvalidISODuration
+	}
+
+	var (
+		total      float64
+		inTime     bool
+		order      = isoDurationOrder[false]
+		components int
+	)
+	for rest != "" {
+		if rest[0] == 'T' {
+			if inTime || len(rest) == 1 {
+				return 0, errInvalidISODuration
+			}
+			inTime, order, rest = true, isoDurationOrder[true], rest[1:]
+			continue
+		}
+
+		end := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != ',' })
+		if end <= 0 {
+			return 0, errInvalidISODuration
+		}
+		value, err := strconv.ParseFloat(strings.Replace(rest[:end], ",", ".", 1), 64)
+		if err != nil {
+			return 0, errInvalidISODuration
+		}
+
+		designator := rest[end]
+		i := strings.IndexByte(order, designator)
+		if i < 0 {
+			return 0, errInvalidISODuration
+		}
+		order, rest = order[i+1:], rest[end+1:]
+		components++
+
+		if !inTime && (designator == 'Y' || designator == 'M') {
+			return 0, errAmbiguousISODuration
+		}
+		total += value * float64(isoDurationUnits[inTime][designator])
+	}
+
+	if components == 0 {
+		return 0, errInvalidISODuration
+	}
+	if total >= math.MaxInt64 {
+		return 0, errors.New("ISO-8601 duration overflows time.Duration")
+	}
+
+	d := time.Duration(math.Round(total))
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
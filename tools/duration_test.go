@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseISODuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{in: "PT30S", want: 30 * time.Second},
+		{in: "PT1.5S", want: 1500 * time.Millisecond},
+		{in: "PT0,5H", want: 30 * time.Minute},
+		{in: "PT2H30M", want: 2*time.Hour + 30*time.Minute},
+		{in: "P1DT12H", want: 36 * time.Hour},
+		{in: "P2W", want: 14 * 24 * time.Hour},
+		{in: "P1W3DT4H5M6S", want: 10*24*time.Hour + 4*time.Hour + 5*time.Minute + 6*time.Second},
+		{in: "-PT15M", want: -15 * time.Minute},
+		{in: "PT0S", want: 0},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseISODuration(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseISODuration(%q) = %v, %v; want %v, nil", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseISODurationErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		err error
+	}{
+		{in: "P1Y2M10DT2H30M", err: errAmbiguousISODuration},
+		{in: "P3M", err: errAmbiguousISODuration},
+		{in: "", err: errInvalidISODuration},
+		{in: "P", err: errInvalidISODuration},
+		{in: "PT", err: errInvalidISODuration},
+		{in: "P1DT", err: errInvalidISODuration},
+		{in: "1h30m", err: errInvalidISODuration},
+		{in: "PT30M2H", err: errInvalidISODuration},
+		{in: "PT1H1H", err: errInvalidISODuration},
+		{in: "P1H", err: errInvalidISODuration},
+		{in: "PTH", err: errInvalidISODuration},
+		{in: "PT1.2.3S", err: errInvalidISODuration},
+		{in: "PT1S2", err: errInvalidISODuration},
+	}
+
+	for _, tt := range tests {
+		if got, err := ParseISODuration(tt.in); !errors.Is(err, tt.err) {
+			t.Errorf("ParseISODuration(%q) = %v, %v; want %v", tt.in, got, err, tt.err)
+		}
+	}
+
+	if _, err := ParseISODuration("P100000W"); err == nil {
+		t.Error("ParseISODuration(P100000W) error = nil, want an overflow error")
+	}
+}
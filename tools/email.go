@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// errInvalidEmail is returned when an input is not a plausible email address.
+var errInvalidEmail = errors.New("invalid email address")
+
+// EmailOptions configures NormalizeEmailWithOptions.
+type EmailOptions struct {
+	// StripGmailAliases canonicalizes Gmail addresses: dots and "+tag" suffixes are
+	// removed from the local part, which is lowercased, and googlemail.com is rewritten
+	// to gmail.com. Gmail delivers all of these variants to the same inbox, so enabling
+	// this prevents users from registering several accounts with one mailbox.
+	StripGmailAliases bool
+}
+
+// NormalizeEmail validates an email address and returns it in a normalized form.
+// Surrounding whitespace is trimmed and the domain is lowercased; the local part is
+// kept as is, since RFC 5321 allows it to be case-sensitive. The address must have
+// the basic RFC 5322 shape of a single bare address (no display name or angle
+// brackets) with a dotted domain.
+//
+// Example usage:
+//
+//	email, err := NormalizeEmail("  Jane.Doe@Example.COM ")
+//	if err != nil {
+//	    // reject the input
+//	}
+//	// Result: "Jane.Doe@example.com"
+//
+// Parameters:
+//   - s: The email address to normalize
+//
+// Returns:
+//   - string: The normalized email address
+//   - error: An error if the input is not a valid email address
+func NormalizeEmail(s string) (string, error) {
+	return NormalizeEmailWithOptions(s, EmailOptions{})
+}
+
+// NormalizeEmailWithOptions validates and normalizes an email address like NormalizeEmail,
+// additionally applying the normalizations enabled in opts.
+//
+// Example usage:
+//
+//	email, err := NormalizeEmailWithOptions("J.Doe+news@googlemail.com", EmailOptions{StripGmailAliases: true})
+//	// Result: "jdoe@gmail.com"
+//
+// Parameters:
+//   - s: The email address to normalize
+//   - opts: Options enabling additional normalizations
+//
+// Returns:
+//   - string: The normalized email address
+//   - error: An error if the input is not a valid email address
+func NormalizeEmailWithOptions(s string, opts EmailOptions) (string, error) {
+	s = strings.TrimSpace(s)
+
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return "", errInvalidEmail
+	}
+
+	at := strings.LastIndex(s, "@")
+	local, domain := s[:at], strings.ToLower(s[at+1:])
+	if len(local) > 64 || !isEmailDomain(domain) {
+		return "", errInvalidEmail
+	}
+
+	if opts.StripGmailAliases && (domain == "gmail.com" || domain == "googlemail.com") {
+		local, _, _ = strings.Cut(local, "+")
+		local = strings.ToLower(strings.ReplaceAll(local, ".", ""))
+		if local == "" {
+			return "", errInvalidEmail
+		}
+		domain = "gmail.com"
+	}
+
+	return local + "@" + domain, nil
+}
+
+// isEmailDomain reports whether a lowercased domain has at least two valid DNS labels.
+//
+// Parameters:
+//   - domain: The domain part of an email address
+//
+// Returns:
+//   - bool: true if the domain is plausible, false otherwise
+func isEmailDomain(domain string) bool {
+	labels := strings.Split(domain, ".")
+	if len(domain) > 253 || len(labels) < 2 {
+		return false
+	}
+
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		in   string
+		opts EmailOptions
+		want string
+	}{
+		{in: "  Jane.Doe@Example.COM ", want: "Jane.Doe@example.com"},
+		{in: "jane+news@example.com", want: "jane+news@example.com"},
+		{in: "J.Doe+news@GoogleMail.com", opts: EmailOptions{StripGmailAliases: true}, want: "jdoe@gmail.com"},
+		{in: "j.doe+news@gmail.com", want: "j.doe+news@gmail.com"},
+		{in: "j.doe+news@example.com", opts: EmailOptions{StripGmailAliases: true}, want: "j.doe+news@example.com"},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeEmailWithOptions(tt.in, tt.opts)
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeEmailWithOptions(%q, %+v) = %q, %v; want %q, nil", tt.in, tt.opts, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeEmailRejectsInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"jane",
+		"jane@",
+		"@example.com",
+		"jane@localhost",
+		"jane@example..com",
+		"jane@-example.com",
+		"jane@exa_mple.com",
+		"Jane Doe <jane@example.com>",
+		"jane@example.com, john@example.com",
+		"a-very-long-local-part-exceeding-the-sixty-four-character-limit-x@example.com",
+	} {
+		if got, err := NormalizeEmail(in); !errors.Is(err, errInvalidEmail) {
+			t.Errorf("NormalizeEmail(%q) = %q, %v; want %v", in, got, err, errInvalidEmail)
+		}
+	}
+
+	if _, err := NormalizeEmailWithOptions("+news@gmail.com", EmailOptions{StripGmailAliases: true}); !errors.Is(err, errInvalidEmail) {
+		t.Errorf("NormalizeEmailWithOptions(+news@gmail.com) error = %v, want %v", err, errInvalidEmail)
+	}
+}
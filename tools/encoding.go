@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Base64URLEncode encodes data as unpadded base64url (RFC 4648 §5).
+// This is the encoding used by JWTs, GenerateSecureToken and most URL-safe tokens
+// and cursors, so values can be placed in URLs, headers and cookies without escaping.
+//
+// Example usage:
+//
+//	cursor := Base64URLEncode([]byte(`{"id":"123"}`))
+//	// Result: "eyJpZCI6IjEyMyJ9"
+//
+// Parameters:
+//   - data: The bytes to encode
+//
+// Returns:
+//   - string: The unpadded base64url encoding of data
+func Base64URLEncode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Base64URLDecode decodes a base64url string produced by Base64URLEncode.
+// Trailing "=" padding is tolerated, so values produced by padded encoders decode
+// as well. Input using the standard base64 alphabet ("+" or "/") or containing any
+// other invalid character is rejected with an error naming the offending position.
+//
+// Example usage:
+//
+//	data, err := Base64URLDecode(r.URL.Query().Get("cursor"))
+//	if err != nil {
+//	    // reject the malformed cursor
+//	}
+//
+// Parameters:
+//   - s: The base64url string to decode
+//
+// Returns:
+//   - []byte: The decoded bytes
+//   - error: An error if s is not valid base64url
+func Base64URLDecode(s string) ([]byte, error) {
+	if i := strings.IndexAny(s, "+/"); i >= 0 {
+		return nil, fmt.Errorf("invalid base64url input: standard base64 character %q at position %d", s[i], i)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64url input: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBase64URLRoundTrip(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{0x00},
+		{0xfb, 0xff},
+		{0xfb, 0xef, 0xbe},
+		[]byte("hello, world"),
+	} {
+		encoded := Base64URLEncode(data)
+		if bytes.ContainsAny([]byte(encoded), "+/=") {
+			t.Errorf("Base64URLEncode(%x) = %q, want unpadded base64url", data, encoded)
+		}
+		decoded, err := Base64URLDecode(encoded)
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("Base64URLDecode(%q) = %x, %v; want %x, nil", encoded, decoded, err, data)
+		}
+	}
+
+	if decoded, err := Base64URLDecode("-_8="); err != nil || !bytes.Equal(decoded, []byte{0xfb, 0xff}) {
+		t.Errorf("Base64URLDecode(padded) = %x, %v; want fbff, nil", decoded, err)
+	}
+}
+
+func TestBase64URLDecodeRejectsMalformed(t *testing.T) {
+	for _, s := range []string{"+/8", "a", "ab$d", "ab cd"} {
+		if decoded, err := Base64URLDecode(s); err == nil {
+			t.Errorf("Base64URLDecode(%q) = %x, want an error", s, decoded)
+		}
+	}
+}
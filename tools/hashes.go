@@ -2,10 +2,15 @@ package tools
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"strings"
 
 	"golang.org/x/crypto/argon2"
@@ -23,6 +28,17 @@ type params struct {
 	keyLength   uint32 // Length of the derived key in bytes (32)
 }
 
+const (
+	// HashSHA256 selects the SHA-256 algorithm in HashReader.
+	HashSHA256 = "sha256"
+
+	// HashSHA512 selects the SHA-512 algorithm in HashReader.
+	HashSHA512 = "sha512"
+
+	// hashReaderBufferSize is the size of the buffer used to stream data through the hasher.
+	hashReaderBufferSize = 32 * 1024
+)
+
 var (
 	// errInvalidHash is returned when the encoded hash format is incorrect.
 	// This error occurs when the hash string doesn't match the expected Argon2 format.
@@ -141,6 +157,107 @@ func IsMatchingInputAndHash(input, encodedHash string) (match bool, err error) {
 	return false, nil
 }
 
+// SecureCompare compares two strings in constant time.
+// This function is intended for comparing secrets such as API keys, webhook
+// signatures, and basic-auth credentials, where a naive == comparison would
+// leak information through timing differences.
+//
+// subtle.ConstantTimeCompare returns immediately when its inputs differ in length,
+// which leaks the length of the secret. To avoid this, both inputs are first hashed
+// with SHA-256 so that the comparison always operates on fixed-length 32-byte digests.
+// The comparison time is therefore independent of both the contents and the length
+// of the inputs (aside from the linear cost of hashing them).
+//
+// Example usage:
+//
+//	if !SecureCompare(r.Header.Get("X-Api-Key"), expectedKey) {
+//	    // reject the request
+//	}
+//
+// Parameters:
+//   - a: The first string to compare (e.g., the value supplied by the client)
+//   - b: The second string to compare (e.g., the expected secret)
+//
+// Returns:
+//   - bool: true if the strings are equal, false otherwise
+func SecureCompare(a, b string) bool {
+	aDigest := sha256.Sum256([]byte(a))
+	bDigest := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(aDigest[:], bDigest[:]) == 1
+}
+
+// GenerateSecureToken creates a cryptographically secure, URL-safe random token.
+// This function reads n random bytes from crypto/rand and encodes them with
+// unpadded base64url, so the result can be used directly in cookies, headers,
+// and query parameters. Use at least 32 bytes for tokens that protect sessions.
+//
+// Example usage:
+//
+//	token, err := GenerateSecureToken(32)
+//	if err != nil {
+//	    // handle error
+//	}
+//	// Result: "Zz8n3c2Xk1..." (43 characters for 32 bytes)
+//
+// Parameters:
+//   - n: The number of random bytes to generate
+//
+// Returns:
+//   - string: The base64url-encoded random token
+//   - error: Any error that occurred during random generation
+func GenerateSecureToken(n uint32) (string, error) {
+	b, err := generateRandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashReader computes the hex-encoded digest of a stream without loading it into memory.
+// This function streams the reader through the selected hash function using a fixed
+// 32 KiB buffer, which makes it suitable for integrity checks on large uploads or files.
+//
+// Supported algorithms are HashSHA256 ("sha256") and HashSHA512 ("sha512"),
+// matched case-insensitively.
+//
+// Example usage:
+//
+//	f, _ := os.Open("upload.bin")
+//	defer f.Close()
+//	digest, err := HashReader(f, HashSHA256)
+//	if err != nil {
+//	    // handle error
+//	}
+//	if digest != expectedDigest {
+//	    // integrity check failed
+//	}
+//
+// Parameters:
+//   - r: The reader to hash (read until EOF)
+//   - algo: The hash algorithm to use ("sha256" or "sha512")
+//
+// Returns:
+//   - string: The lowercase hex-encoded digest
+//   - error: Any error that occurred while reading, or if the algorithm is unsupported
+func HashReader(r io.Reader, algo string) (string, error) {
+	var h hash.Hash
+	switch strings.ToLower(algo) {
+	case HashSHA256:
+		h = sha256.New()
+	case HashSHA512:
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+
+	buf := make([]byte, hashReaderBufferSize)
+	if _, err := io.CopyBuffer(h, r, buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // generateRandomBytes creates a cryptographically secure random byte slice.
 // This function uses crypto/rand to generate random bytes suitable for use
 // as cryptographic salt or other security-sensitive purposes.
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSecureCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "secret", b: "secret", want: true},
+		{a: "", b: "", want: true},
+		{a: "secret", b: "Secret", want: false},
+		{a: "secret", b: "secret-but-longer", want: false},
+		{a: "secret", b: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := SecureCompare(tt.a, tt.b); got != tt.want {
+			t.Errorf("SecureCompare(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestHashReader(t *testing.T) {
+	tests := []struct {
+		algo string
+		want string
+	}{
+		{algo: HashSHA256, want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{algo: "SHA512", want: "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+	}
+
+	for _, tt := range tests {
+		got, err := HashReader(strings.NewReader("abc"), tt.algo)
+		if err != nil || got != tt.want {
+			t.Errorf("HashReader(abc, %s) = %q, %v; want %q, nil", tt.algo, got, err, tt.want)
+		}
+	}
+}
+
+func TestHashReaderLargeStream(t *testing.T) {
+	data := bytes.Repeat([]byte("anvil"), 100_000)
+	sum := sha256.Sum256(data)
+
+	// HalfReader hides io.WriterTo and forces many short reads through the buffer.
+	got, err := HashReader(iotest.HalfReader(bytes.NewReader(data)), HashSHA256)
+	if err != nil || got != hex.EncodeToString(sum[:]) {
+		t.Errorf("HashReader() = %q, %v; want %x, nil", got, err, sum)
+	}
+}
+
+func TestHashReaderErrors(t *testing.T) {
+	if _, err := HashReader(strings.NewReader("abc"), "md5"); err == nil {
+		t.Error("HashReader(md5) error = nil, want an unsupported algorithm error")
+	}
+
+	errRead := errors.New("connection reset")
+	if _, err := HashReader(iotest.ErrReader(errRead), HashSHA256); !errors.Is(err, errRead) {
+		t.Errorf("HashReader(failing reader) error = %v, want %v", err, errRead)
+	}
+}
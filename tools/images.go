@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register the GIF format with image.DecodeConfig
+	_ "image/jpeg" // Register the JPEG format with image.DecodeConfig
+	_ "image/png"  // Register the PNG format with image.DecodeConfig
+	"io"
+	"strings"
+)
+
+// errUnknownImageFormat is returned when the content is not an image in a supported format.
+var errUnknownImageFormat = errors.New("unrecognized image format")
+
+// ValidateImage checks the type and dimensions of an uploaded image.
+// The format is detected by sniffing the content with image.DecodeConfig rather than
+// trusting the file name or Content-Type, and only the image header is read, so
+// oversized images are rejected without decoding their pixels. Supported formats are
+// "png", "jpeg" and "gif", plus any format registered with image.RegisterFormat.
+//
+// Example usage:
+//
+//	file, _, err := r.FormFile("avatar")
+//	if err != nil {
+//	    return err
+//	}
+//	defer file.Close()
+//
+//	format, err := ValidateImage(file, []string{"png", "jpeg"}, 4096, 4096)
+//	if err != nil {
+//	    // reject the upload
+//	}
+//
+// Parameters:
+//   - r: The image content (only its header is consumed)
+//   - allowed: The accepted format names (e.g., "png", "jpeg"); empty accepts every supported format
+//   - maxW: The maximum width in pixels (<= 0 for no limit)
+//   - maxH: The maximum height in pixels (<= 0 for no limit)
+//
+// Returns:
+//   - string: The detected format name
+//   - error: An error if the content is not a supported image, the format is not allowed or a dimension exceeds its limit
+func ValidateImage(r io.Reader, allowed []string, maxW, maxH int) (format string, err error) {
+	config, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return "", errUnknownImageFormat
+	}
+
+	if len(allowed) > 0 {
+		ok := false
+		for _, name := range allowed {
+			if strings.EqualFold(name, format) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return format, fmt.Errorf("image format %s is not allowed", format)
+		}
+	}
+
+	if (maxW > 0 && config.Width > maxW) || (maxH > 0 && config.Height > maxH) {
+		return format, fmt.Errorf("image is %dx%d pixels, exceeding the maximum of %dx%d", config.Width, config.Height, maxW, maxH)
+	}
+
+	return format, nil
+}
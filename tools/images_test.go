@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// encodedImage returns a blank image of the given size encoded in the given format.
+func encodedImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, width, height), []color.Color{color.White})
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encode %s error = %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestValidateImage(t *testing.T) {
+	allowed := []string{"png", "jpeg"}
+
+	tests := []struct {
+		name    string
+		content []byte
+		format  string
+		wantErr bool
+	}{
+		{name: "valid png", content: encodedImage(t, "png", 64, 48), format: "png"},
+		{name: "disallowed gif", content: encodedImage(t, "gif", 64, 48), format: "gif", wantErr: true},
+		{name: "oversized", content: encodedImage(t, "png", 1024, 48), format: "png", wantErr: true},
+		{name: "too tall", content: encodedImage(t, "png", 64, 1024), format: "png", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := ValidateImage(bytes.NewReader(tt.content), allowed, 512, 512)
+			if format != tt.format || (err != nil) != tt.wantErr {
+				t.Errorf("ValidateImage() = %q, %v; want %q, error %v", format, err, tt.format, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := ValidateImage(strings.NewReader("not an image"), nil, 0, 0); !errors.Is(err, errUnknownImageFormat) {
+		t.Errorf("ValidateImage(garbage) error = %v, want %v", err, errUnknownImageFormat)
+	}
+	if format, err := ValidateImage(bytes.NewReader(encodedImage(t, "gif", 4096, 4096)), nil, 0, 0); format != "gif" || err != nil {
+		t.Errorf("ValidateImage() without limits = %q, %v; want gif, nil", format, err)
+	}
+}
@@ -1,13 +1,29 @@
 package tools
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var (
+	// ErrClaimNotFound is returned by Claim when a valid token does not contain the requested claim.
+	ErrClaimNotFound = errors.New("token claim not found")
+
+	// ErrTokenRevoked is returned when a token's jti, or every token of its user, has been revoked in the configured SessionStore.
+	ErrTokenRevoked = errors.New("token has been revoked")
+
+	// ErrTokenTooOld is returned when a token's iat is older than the maximum age set with WithMaxTokenAge.
+	ErrTokenTooOld = errors.New("token exceeds the maximum session age")
+
+	// errTokenNoExpiry is returned by TimeUntilExpiry for a valid token without an "exp" claim.
+	errTokenNoExpiry = errors.New("token has no expiration time")
+)
+
 // JWT represents a JSON Web Token service with configuration for token generation and verification.
 // This struct encapsulates the issuer information and signing key needed for JWT operations.
 // The issuer is typically the domain or service name that creates the token, and the signing key
@@ -15,14 +31,47 @@ import (
 type JWT struct {
 	Issuer     string `json:"issuer"`      // The issuer of the JWT (typically your service domain)
 	SigningKey []byte `json:"signing_key"` // The secret key used to sign and verify tokens
+
+	sessions SessionStore  // Optional store of revoked tokens and users consulted during verification
+	issuers  []string      // Additional issuers accepted during verification besides Issuer
+	maxAge   time.Duration // Maximum age of a token's iat accepted during verification (0 for no limit)
+	private  bool          // Whether Generate also writes Email under its JSON tag name
 }
 
 // JWTClaims represents the custom claims structure for JSON Web Tokens.
 // This struct defines the user-specific data that will be embedded in the JWT.
 // The claims are included in the token payload and can be extracted during verification.
+//
+// Inside the token, the fields are carried by the following claims:
+//
+//	JWTClaims field | token claim
+//	----------------+------------
+//	ID              | user_id
+//	Email           | sub
+//	Scope           | scope
+//
+// Generate writes and Verify reads this mapping, so Verify(Generate(claims)) returns
+// the original ID, Email and Scope. The registered "jti" claim is not derived from the
+// claims: Generate sets it to a random ID unique to each token, so a single session can
+// be revoked without affecting the user's other sessions (see WithSessionStore). When
+// WithPrivateClaims is enabled, Generate additionally writes the email under "email",
+// which Verify prefers over "sub". Tokens issued before the "user_id" claim was
+// introduced carried the ID in "jti"; Verify still reads the ID from "jti" when a
+// token has no "user_id" claim, so those tokens keep working until they expire.
 type JWTClaims struct {
 	ID    string `json:"user_id"` // The unique identifier of the user
 	Email string `json:"email"`   // The email address of the user
+	Scope string `json:"scope"`   // Space-delimited OAuth-style scopes granted to the token (e.g., "read:users write:users")
+}
+
+// tokenClaims is the payload written by Generate.
+// It combines the registered JWT claims with the private claims that do not
+// map onto a registered claim.
+type tokenClaims struct {
+	Scope  string `json:"scope,omitempty"`
+	UserID string `json:"user_id"`         // Always written, so tokens without it are known to predate it
+	Email  string `json:"email,omitempty"` // Written only with WithPrivateClaims
+	jwt.RegisteredClaims
 }
 
 // NewJsonWebToken creates a new JWT service instance with the specified issuer and signing key.
@@ -48,6 +97,114 @@ func NewJsonWebToken(issuer string, key []byte) *JWT {
 	}
 }
 
+// WithSessionStore sets the store of revoked sessions consulted during verification.
+// This method returns the JWT instance with the specified store, following the
+// builder pattern for configuration.
+//
+// When a store is configured, Verify and Claim reject with ErrTokenRevoked any token
+// whose "jti" claim has been revoked with Revoke, which logs out a single session, and
+// any token of a user revoked with RevokeUser that was issued before the revocation,
+// which implements "log out everywhere". A user revocation is compared with the
+// token's "iat" claim, whose precision is one second, so tokens issued in the same
+// second as the revocation stay valid; a session re-issued right after a "log out
+// other devices" action therefore keeps working. Refresh keeps the original "iat", so
+// a revoked session cannot be extended by refreshing it.
+//
+// Example usage:
+//
+//	sessions := NewMemorySessionStore()
+//	jwtService := NewJsonWebToken("myapp.com", key).WithSessionStore(sessions)
+//
+//	// Log out this session, until the token would have expired anyway.
+//	jti, _ := jwtService.Claim(tokenString, "jti")
+//	sessions.Revoke(jti, time.Now().Add(15*time.Minute))
+//
+//	// Log out everywhere, until the longest-lived token would have expired anyway.
+//	sessions.RevokeUser(claims.ID, time.Now().Add(15*time.Minute))
+//
+// Parameters:
+//   - store: The session store to consult (nil disables revocation checks)
+//
+// Returns:
+//   - *JWT: The JWT instance with the session store configured
+func (tkn *JWT) WithSessionStore(store SessionStore) *JWT {
+	tkn.sessions = store
+	return tkn
+}
+
+// WithAcceptedIssuers sets additional issuers accepted during verification.
+// This method returns the JWT instance with the specified issuers, following the
+// builder pattern for configuration.
+//
+// By default, Verify and Claim only accept tokens whose "iss" claim equals the
+// configured Issuer. During a domain migration, tokens issued under the old name
+// remain valid until they expire; listing the old issuer here accepts them while
+// Generate already issues tokens under the new one.
+//
+// Example usage:
+//
+//	jwtService := NewJsonWebToken("auth.newdomain.com", key).
+//	    WithAcceptedIssuers("auth.olddomain.com")
+//
+// Parameters:
+//   - issuers: The additional issuers to accept (none to restore single-issuer validation)
+//
+// Returns:
+//   - *JWT: The JWT instance with the accepted issuers configured
+func (tkn *JWT) WithAcceptedIssuers(issuers ...string) *JWT {
+	tkn.issuers = issuers
+	return tkn
+}
+
+// WithMaxTokenAge caps the absolute age of accepted tokens, measured from their "iat" claim.
+// This method returns the JWT instance with the specified maximum age, following the
+// builder pattern for configuration.
+//
+// Some compliance rules limit how long a session may last regardless of refreshes.
+// With a maximum age set, Verify, VerifyInto and Claim reject tokens issued more than
+// maxAge ago, as well as tokens without an "iat" claim, with ErrTokenTooOld. The
+// check is independent of "exp": a token can still be unexpired and be rejected.
+//
+// Example usage:
+//
+//	jwtService := NewJsonWebToken("myapp.com", key).WithMaxTokenAge(12 * time.Hour)
+//
+// Parameters:
+//   - maxAge: The maximum token age (0 or less disables the check)
+//
+// Returns:
+//   - *JWT: The JWT instance with the maximum token age configured
+func (tkn *JWT) WithMaxTokenAge(maxAge time.Duration) *JWT {
+	tkn.maxAge = maxAge
+	return tkn
+}
+
+// WithPrivateClaims makes Generate also write the email under its JSON tag name.
+// This method returns the JWT instance with the specified mode, following the builder
+// pattern for configuration.
+//
+// By default the user's email is only carried by the registered "sub" claim (see
+// JWTClaims), which confuses people inspecting raw tokens and tooling that looks for
+// "email". When enabled, Generate additionally writes the "email" private claim. The
+// "sub" claim is still written, and Verify prefers "email" when a token carries it,
+// so tokens issued in either mode verify the same way.
+//
+// Example usage:
+//
+//	jwtService := NewJsonWebToken("myapp.com", key).WithPrivateClaims(true)
+//	token, _ := jwtService.Generate(JWTClaims{ID: "123", Email: "user@example.com"}, nil)
+//	// Payload: {"user_id": "123", "email": "user@example.com", "sub": "user@example.com", "jti": "<random>", ...}
+//
+// Parameters:
+//   - enabled: true to write the private claims, false to only write the registered claims
+//
+// Returns:
+//   - *JWT: The JWT instance with the claim mode configured
+func (tkn *JWT) WithPrivateClaims(enabled bool) *JWT {
+	tkn.private = enabled
+	return tkn
+}
+
 // Generate creates a new JSON Web Token with the specified claims and expiration.
 // This function creates a JWT using the HS256 signing algorithm with the configured
 // issuer and signing key. The token includes standard JWT claims (exp, iat, nbf, iss, sub, jti)
@@ -63,7 +220,10 @@ func NewJsonWebToken(issuer string, key []byte) *JWT {
 //   - nbf: Not before time
 //   - iss: Issuer (from JWT configuration)
 //   - sub: Subject (user's email)
-//   - jti: JWT ID (user's ID)
+//   - jti: JWT ID (random and unique to each token)
+//   - user_id: The user's ID
+//   - scope: Space-delimited scopes (omitted when claims.Scope is empty)
+//   - email: The user's email, only with WithPrivateClaims
 //
 // Example usage:
 //
@@ -79,23 +239,107 @@ func NewJsonWebToken(issuer string, key []byte) *JWT {
 //   - string: The signed JWT string
 //   - error: Any error that occurred during token generation
 func (tkn *JWT) Generate(claims JWTClaims, expiration *int) (string, error) {
-	var tokenExpiration time.Duration
+	tokenExpiration := 15 * time.Minute
 
-	if expiration == nil {
-		tokenExpiration = 15 * time.Minute
+	if expiration != nil && *expiration >= 0 {
+		tokenExpiration = time.Duration(*expiration) * time.Minute
 	}
 
-	if *expiration >= 0 {
-		tokenExpiration = time.Duration(*expiration) * time.Minute
+	now := time.Now()
+	return tkn.sign(claims, now, now.Add(tokenExpiration))
+}
+
+// Refresh issues a new token for a valid token that expires within threshold.
+// This supports sliding sessions: a client that keeps making requests receives fresh
+// tokens before its current one expires. The new token carries the ID, email and
+// scope of the original token and has the same lifetime (exp - nbf, or exp - iat for
+// tokens without nbf), counted from now. The original iat is kept, so a maximum age set with WithMaxTokenAge still
+// bounds the whole session, and so does a revocation with RevokeUser. The new token
+// gets its own "jti". Claims outside JWTClaims are not carried over.
+//
+// The token is fully verified first, so expired, revoked or otherwise invalid tokens
+// are never refreshed.
+//
+// Example usage:
+//
+//	fresh, refreshed, err := jwtService.Refresh(tokenString, 5*time.Minute)
+//	if err != nil {
+//	    // Token is invalid
+//	}
+//	if refreshed {
+//	    w.Header().Set("X-New-Token", fresh)
+//	}
+//
+// Parameters:
+//   - tokenString: The JWT string to refresh
+//   - threshold: How close to its expiry a token must be to be refreshed
+//
+// Returns:
+//   - string: The new token, or "" if the token is not close enough to its expiry
+//   - bool: true if a new token was issued
+//   - error: Any error that occurred during verification or signing
+func (tkn *JWT) Refresh(tokenString string, threshold time.Duration) (string, bool, error) {
+	mapClaims, err := tkn.parse(tokenString)
+	if err != nil {
+		return "", false, err
 	}
 
-	jwtClaims := jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExpiration)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		NotBefore: jwt.NewNumericDate(time.Now()),
-		Issuer:    tkn.Issuer,
-		Subject:   claims.Email,
-		ID:        claims.ID,
+	exp, err := mapClaims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return "", false, nil // tokens without an expiry never need refreshing
+	}
+	if time.Until(exp.Time) > threshold {
+		return "", false, nil
+	}
+
+	// The lifetime is counted from when the token was signed (nbf), not from the
+	// original iat, which is carried over and would otherwise grow it on every refresh.
+	iat, _ := mapClaims.GetIssuedAt()
+	signedAt, _ := mapClaims.GetNotBefore()
+	if signedAt == nil {
+		signedAt = iat
+	}
+	lifetime := 15 * time.Minute
+	if signedAt != nil && exp.Time.After(signedAt.Time) {
+		lifetime = exp.Time.Sub(signedAt.Time)
+	}
+	if iat == nil {
+		iat = jwt.NewNumericDate(time.Now())
+	}
+
+	ss, err := tkn.sign(claimsFromMap(mapClaims), iat.Time, time.Now().Add(lifetime))
+	if err != nil {
+		return "", false, err
+	}
+	return ss, true, nil
+}
+
+// sign creates a signed token carrying the user claims.
+//
+// Parameters:
+//   - claims: The user-specific claims to include in the token
+//   - issuedAt: The issued at time
+//   - expiresAt: The expiration time
+//
+// Returns:
+//   - string: The signed JWT string
+//   - error: Any error that occurred during signing
+func (tkn *JWT) sign(claims JWTClaims, issuedAt, expiresAt time.Time) (string, error) {
+	jwtClaims := tokenClaims{
+		Scope:  claims.Scope,
+		UserID: claims.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    tkn.Issuer,
+			Subject:   claims.Email,
+			ID:        GenerateUUID(),
+		},
+	}
+
+	if tkn.private {
+		jwtClaims.Email = claims.Email
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims)
@@ -114,9 +358,13 @@ func (tkn *JWT) Generate(claims JWTClaims, expiration *int) (string, error) {
 //   - Token expiration
 //   - Token not-before time
 //   - Issuer validation
+//   - Token age, when a maximum age is configured (see WithMaxTokenAge)
+//   - Revocation, when a SessionStore is configured (see WithSessionStore)
 //
-// The function returns the user claims (ID and email) if the token is valid,
-// or an error if the token is invalid, expired, or malformed.
+// The function returns the user claims if the token is valid, or an error if the
+// token is invalid, expired, or malformed. The ID is read from the "user_id" claim (or
+// from "jti" for tokens that predate it, see JWTClaims) and the email from the "email" claim when present (see WithPrivateClaims), and otherwise
+// from the "sub" claim, mirroring Generate (see JWTClaims).
 //
 // Example usage:
 //
@@ -133,22 +381,281 @@ func (tkn *JWT) Generate(claims JWTClaims, expiration *int) (string, error) {
 //   - JWTClaims: The user claims extracted from the token (ID and email)
 //   - error: Any error that occurred during verification (invalid signature, expired, etc.)
 func (tkn *JWT) Verify(tokenString string) (JWTClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return tkn.SigningKey, nil
-	})
+	claims, err := tkn.parse(tokenString)
 	if err != nil {
 		return JWTClaims{}, err
 	}
+	return claimsFromMap(claims), nil
+}
+
+// claimsFromMap extracts the user claims from verified token claims, mirroring Generate.
+//
+// Parameters:
+//   - claims: The verified token claims
+//
+// Returns:
+//   - JWTClaims: The user claims
+func claimsFromMap(claims jwt.MapClaims) JWTClaims {
+	email := SafeString(claims, "email")
+	if email == "" {
+		email = SafeString(claims, "sub")
+	}
+
+	return JWTClaims{
+		ID:    userIDClaim(claims),
+		Email: email,
+		Scope: SafeString(claims, "scope"),
+	}
+}
+
+// userIDClaim returns the user ID carried by verified token claims.
+// Tokens issued before the "user_id" claim was introduced carried the ID in "jti", so
+// "jti" is used when the token has no "user_id" claim at all.
+//
+// Parameters:
+//   - claims: The verified token claims
+//
+// Returns:
+//   - string: The user ID, or "" if the token carries none
+func userIDClaim(claims jwt.MapClaims) string {
+	if _, ok := claims["user_id"]; ok {
+		return SafeString(claims, "user_id")
+	}
+	return SafeString(claims, "jti")
+}
+
+// TimeUntilExpiry validates a JSON Web Token and returns how long it remains valid.
+// This function performs the same checks as Verify, so invalid, expired or revoked
+// tokens return an error, and then reports the time left until the "exp" claim.
+// It is useful for showing "session expires in 12 minutes" or for scheduling a
+// refresh (see Refresh).
+//
+// Example usage:
+//
+//	remaining, err := jwtService.TimeUntilExpiry(tokenString)
+//	if err != nil {
+//	    // Token is invalid, expired, or malformed
+//	}
+//	refreshAt := time.Now().Add(remaining - time.Minute)
+//
+// Parameters:
+//   - tokenString: The JWT string to verify
+//
+// Returns:
+//   - time.Duration: The time until the token expires
+//   - error: Any error that occurred during verification, or if the token has no "exp" claim
+func (tkn *JWT) TimeUntilExpiry(tokenString string) (time.Duration, error) {
+	claims, err := tkn.parse(tokenString)
+	if err != nil {
+		return 0, err
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return 0, err
+	}
+	if exp == nil {
+		return 0, errTokenNoExpiry
+	}
+	return time.Until(exp.Time), nil
+}
+
+// Claim validates a JSON Web Token and returns a single named claim as a string.
+// This function performs the same signature and time-based checks as Verify,
+// then looks up the requested claim in the token payload. It is useful for
+// reading claims that are not part of JWTClaims, such as an organization or
+// tenant identifier issued by another service.
+//
+// Example usage:
+//
+//	orgID, err := jwtService.Claim(tokenString, "org_id")
+//	if err != nil {
+//	    // Token is invalid or the claim is missing
+//	}
+//
+// Parameters:
+//   - tokenString: The JWT string to verify
+//   - name: The name of the claim to return (e.g., "org_id")
+//
+// Returns:
+//   - string: The claim value formatted as a string
+//   - error: Any error that occurred during verification, or if the claim is missing
+func (tkn *JWT) Claim(tokenString, name string) (string, error) {
+	claims, err := tkn.parse(tokenString)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := claims[name]
+	if !ok || value == nil {
+		return "", fmt.Errorf("%w: %q", ErrClaimNotFound, name)
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+// VerifyInto validates a JSON Web Token and decodes its claims into a caller-defined struct.
+// This function performs the same signature, time-based, issuer and revocation checks
+// as Verify, but unmarshals the token payload into out instead of JWTClaims. It is
+// useful for reading custom claims with their proper types rather than through a
+// generic map. Embed jwt.RegisteredClaims in the struct to satisfy jwt.Claims.
+//
+// Example usage:
+//
+//	type OrgClaims struct {
+//	    OrgID string `json:"org_id"`
+//	    Scope string `json:"scope"`
+//	    jwt.RegisteredClaims
+//	}
+//
+//	var claims OrgClaims
+//	if err := jwtService.VerifyInto(tokenString, &claims); err != nil {
+//	    // Token is invalid, expired, or malformed
+//	}
+//	// Use claims.OrgID and claims.Subject
+//
+// Parameters:
+//   - tokenString: The JWT string to verify
+//   - out: A pointer to the claims struct to decode into
+//
+// Returns:
+//   - error: Any error that occurred during verification or decoding
+func (tkn *JWT) VerifyInto(tokenString string, out jwt.Claims) error {
+	claims, err := tkn.parse(tokenString)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return fmt.Errorf("unable to decode token claims: %w", err)
+	}
+
+	return nil
+}
+
+// HasScope reports whether the claims grant every one of the required scopes.
+// Scopes are read from the space-delimited Scope claim, following the OAuth 2.0
+// "scope" convention. Matching is exact and case-sensitive. When no scopes are
+// required, HasScope returns true.
+//
+// Example usage:
+//
+//	claims, _ := jwtService.Verify(tokenString)
+//	if !HasScope(claims, "read:users", "write:users") {
+//	    // insufficient scope
+//	}
+//
+// Parameters:
+//   - claims: The verified token claims
+//   - required: The scopes that must all be present
+//
+// Returns:
+//   - bool: true if every required scope is granted, false otherwise
+func HasScope(claims JWTClaims, required ...string) bool {
+	granted := make(map[string]bool)
+	for _, scope := range strings.Fields(claims.Scope) {
+		granted[scope] = true
+	}
+
+	for _, scope := range required {
+		if !granted[scope] {
+			return false
+		}
+	}
+	return true
+}
+
+// parse verifies a token and returns its claims.
+// This is the shared verification path used by Verify, VerifyInto and Claim. It validates the
+// signature and time-based claims, checks the issuer against the accepted issuers and
+// the token age against the maximum age, then rejects tokens revoked in the session store,
+// either by their "jti" or by a revocation of their user issued after their "iat".
+//
+// Parameters:
+//   - tokenString: The JWT string to verify
+//
+// Returns:
+//   - jwt.MapClaims: The verified token claims
+//   - error: Any error that occurred during verification
+func (tkn *JWT) parse(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, tkn.keyFunc)
+	if err != nil {
+		return nil, err
+	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		return JWTClaims{
-			ID:    fmt.Sprint(claims["jti"]),
-			Email: fmt.Sprint(claims["sub"]),
-		}, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("token claims not found")
 	}
 
-	return JWTClaims{}, errors.New("token claims not found")
+	if !tkn.isAcceptedIssuer(SafeString(claims, "iss")) {
+		return nil, jwt.ErrTokenInvalidIssuer
+	}
+
+	if tkn.maxAge > 0 {
+		iat, err := claims.GetIssuedAt()
+		if err != nil || iat == nil || time.Since(iat.Time) > tkn.maxAge {
+			return nil, ErrTokenTooOld
+		}
+	}
+
+	if tkn.sessions != nil {
+		if jti := SafeString(claims, "jti"); jti != "" && tkn.sessions.IsRevoked(jti) {
+			return nil, ErrTokenRevoked
+		}
+		if userID := userIDClaim(claims); userID != "" {
+			if revokedAt, ok := tkn.sessions.UserRevokedAt(userID); ok {
+				iat, err := claims.GetIssuedAt()
+				if err != nil || iat == nil || iat.Time.Before(revokedAt.Truncate(time.Second)) {
+					return nil, ErrTokenRevoked
+				}
+			}
+		}
+	}
+
+	return claims, nil
+}
+
+// isAcceptedIssuer reports whether a token issuer is the configured Issuer or one of the accepted issuers.
+// When no Issuer is configured and no additional issuers are accepted, any issuer is accepted.
+//
+// Parameters:
+//   - iss: The "iss" claim of the token
+//
+// Returns:
+//   - bool: true if the issuer is accepted, false otherwise
+func (tkn *JWT) isAcceptedIssuer(iss string) bool {
+	if tkn.Issuer == "" && len(tkn.issuers) == 0 {
+		return true
+	}
+	if iss == tkn.Issuer && iss != "" {
+		return true
+	}
+	for _, accepted := range tkn.issuers {
+		if iss == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// keyFunc resolves the key used to verify a token's signature.
+// It rejects any token that is not signed with an HMAC method, preventing
+// algorithm-substitution attacks, and returns the configured signing key.
+//
+// Parameters:
+//   - token: The parsed (but not yet verified) token
+//
+// Returns:
+//   - interface{}: The signing key used to verify the token
+//   - error: An error if the token uses an unexpected signing method
+func (tkn *JWT) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, errors.New("unexpected signing method")
+	}
+	return tkn.SigningKey, nil
 }
@@ -0,0 +1,367 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testKey signs the tokens in these tests.
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+// issuedAgo signs a token for claims that was issued the given duration ago and is valid for an hour.
+func issuedAgo(t *testing.T, tkn *JWT, claims JWTClaims, ago time.Duration) string {
+	t.Helper()
+	issuedAt := time.Now().Add(-ago)
+	token, err := tkn.sign(claims, issuedAt, issuedAt.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("sign() error = %v", err)
+	}
+	return token
+}
+
+func TestJWTRoundTrip(t *testing.T) {
+	tkn := NewJsonWebToken("myapp.com", testKey)
+	claims := JWTClaims{ID: "user123", Email: "user@example.com", Scope: "read:users"}
+
+	token, err := tkn.Generate(claims, nil)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	got, err := tkn.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got != claims {
+		t.Errorf("Verify() = %+v, want %+v", got, claims)
+	}
+}
+
+func TestJWTVerifyLegacyToken(t *testing.T) {
+	tkn := NewJsonWebToken("myapp.com", testKey)
+	// Tokens issued before the user_id claim carried the user's ID in jti.
+	now := time.Now()
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Issuer:    "myapp.com",
+		Subject:   "user@example.com",
+		ID:        "user123",
+	}).SignedString(testKey)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	want := JWTClaims{ID: "user123", Email: "user@example.com"}
+	if got, err := tkn.Verify(legacy); err != nil || got != want {
+		t.Errorf("Verify(legacy) = %+v, %v; want %+v, nil", got, err, want)
+	}
+
+	anonymous, _ := tkn.Generate(JWTClaims{Email: "user@example.com"}, nil)
+	if got, _ := tkn.Verify(anonymous); got.ID != "" {
+		t.Errorf("Verify(token without ID).ID = %q, want \"\"", got.ID)
+	}
+}
+
+func TestJWTPrivateClaimsMatchJSONTags(t *testing.T) {
+	tkn := NewJsonWebToken("myapp.com", testKey).WithPrivateClaims(true)
+	claims := JWTClaims{ID: "user123", Email: "user@example.com", Scope: "read:users"}
+	token, _ := tkn.Generate(claims, nil)
+
+	payload, _ := json.Marshal(claims)
+	var tagged map[string]string
+	json.Unmarshal(payload, &tagged)
+	for name, want := range tagged {
+		if got, err := tkn.Claim(token, name); err != nil || got != want {
+			t.Errorf("Claim(%s) = %q, %v; want %q, nil", name, got, err, want)
+		}
+	}
+	if got, _ := tkn.Verify(token); got != claims {
+		t.Errorf("Verify() = %+v, want %+v", got, claims)
+	}
+}
+
+func TestJWTUniqueTokenID(t *testing.T) {
+	tkn := NewJsonWebToken("myapp.com", testKey)
+	claims := JWTClaims{ID: "user123", Email: "user@example.com"}
+
+	first, _ := tkn.Generate(claims, nil)
+	second, _ := tkn.Generate(claims, nil)
+	firstID, err := tkn.Claim(first, "jti")
+	if err != nil {
+		t.Fatalf("Claim(jti) error = %v", err)
+	}
+	secondID, _ := tkn.Claim(second, "jti")
+
+	if firstID == claims.ID {
+		t.Error("jti is the user ID, want a random token ID")
+	}
+	if firstID == secondID {
+		t.Errorf("two tokens share the jti %q", firstID)
+	}
+}
+
+func TestJWTRevokeToken(t *testing.T) {
+	sessions := NewMemorySessionStore()
+	tkn := NewJsonWebToken("myapp.com", testKey).WithSessionStore(sessions)
+	claims := JWTClaims{ID: "user123"}
+
+	revoked, _ := tkn.Generate(claims, nil)
+	other, _ := tkn.Generate(claims, nil)
+	jti, _ := tkn.Claim(revoked, "jti")
+	sessions.Revoke(jti, time.Now().Add(time.Minute))
+
+	if _, err := tkn.Verify(revoked); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Verify(revoked) error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := tkn.Verify(other); err != nil {
+		t.Errorf("Verify(other session) error = %v, want nil", err)
+	}
+}
+
+func TestJWTRevokeUser(t *testing.T) {
+	sessions := NewMemorySessionStore()
+	tkn := NewJsonWebToken("myapp.com", testKey).WithSessionStore(sessions)
+	claims := JWTClaims{ID: "user123"}
+
+	old := issuedAgo(t, tkn, claims, time.Minute)
+	otherUser := issuedAgo(t, tkn, JWTClaims{ID: "user456"}, time.Minute)
+	const ttl = 100 * time.Millisecond
+	sessions.RevokeUser(claims.ID, time.Now().Add(ttl))
+	fresh, _ := tkn.Generate(claims, nil)
+
+	if _, err := tkn.Verify(old); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Verify(token issued before revocation) error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, _, err := tkn.Refresh(old, 2*time.Hour); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Refresh(token issued before revocation) error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := tkn.Verify(fresh); err != nil {
+		t.Errorf("Verify(token issued after revocation) error = %v, want nil", err)
+	}
+	if _, err := tkn.Verify(otherUser); err != nil {
+		t.Errorf("Verify(other user's token) error = %v, want nil", err)
+	}
+
+	time.Sleep(2 * ttl)
+	if _, err := tkn.Verify(old); err != nil {
+		t.Errorf("Verify() after the revocation expired error = %v, want nil", err)
+	}
+}
+
+func TestJWTRefreshKeepsIssuedAt(t *testing.T) {
+	tkn := NewJsonWebToken("myapp.com", testKey)
+	claims := JWTClaims{ID: "user123", Email: "user@example.com"}
+	token := issuedAgo(t, tkn, claims, 55*time.Minute)
+
+	fresh, refreshed, err := tkn.Refresh(token, 10*time.Minute)
+	if err != nil || !refreshed {
+		t.Fatalf("Refresh() = %v, %v; want a new token", refreshed, err)
+	}
+	oldJTI, _ := tkn.Claim(token, "jti")
+	newJTI, _ := tkn.Claim(fresh, "jti")
+	if oldJTI == newJTI {
+		t.Error("refreshed token kept the jti of the original token")
+	}
+	oldIAT, _ := tkn.Claim(token, "iat")
+	newIAT, _ := tkn.Claim(fresh, "iat")
+	if oldIAT != newIAT {
+		t.Errorf("refreshed iat = %s, want %s", newIAT, oldIAT)
+	}
+	if got, _ := tkn.Verify(fresh); got != claims {
+		t.Errorf("Verify(refreshed) = %+v, want %+v", got, claims)
+	}
+}
+
+func TestJWTRefreshKeepsLifetime(t *testing.T) {
+	tkn := NewJsonWebToken("myapp.com", testKey)
+	// A one-hour token of a session that started 55 minutes ago and was refreshed just now.
+	now := time.Now()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims{
+		UserID: "user123",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now.Add(-55 * time.Minute)),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			Issuer:    "myapp.com",
+		},
+	}).SignedString(testKey)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		token, _, err = tkn.Refresh(token, 2*time.Hour)
+		if err != nil {
+			t.Fatalf("Refresh() #%d error = %v", i, err)
+		}
+		remaining, err := tkn.TimeUntilExpiry(token)
+		if err != nil {
+			t.Fatalf("TimeUntilExpiry() after refresh #%d error = %v", i, err)
+		}
+		if remaining <= 59*time.Minute || remaining > time.Hour {
+			t.Errorf("TimeUntilExpiry() after refresh #%d = %s, want just under 1h", i, remaining)
+		}
+	}
+}
+
+func TestJWTClaim(t *testing.T) {
+	tkn := NewJsonWebToken("myapp.com", testKey)
+	token, _ := tkn.Generate(JWTClaims{ID: "user123", Scope: "read:users"}, nil)
+
+	if got, err := tkn.Claim(token, "scope"); err != nil || got != "read:users" {
+		t.Errorf("Claim(scope) = %q, %v; want read:users, nil", got, err)
+	}
+	if _, err := tkn.Claim(token, "org_id"); !errors.Is(err, ErrClaimNotFound) {
+		t.Errorf("Claim(org_id) error = %v, want %v", err, ErrClaimNotFound)
+	}
+	if _, err := NewJsonWebToken("myapp.com", []byte("other-key")).Claim(token, "scope"); err == nil {
+		t.Error("Claim() with another key error = nil, want a signature error")
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	claims := JWTClaims{Scope: "read:users  write:users"}
+	tests := []struct {
+		required []string
+		want     bool
+	}{
+		{required: nil, want: true},
+		{required: []string{"read:users"}, want: true},
+		{required: []string{"read:users", "write:users"}, want: true},
+		{required: []string{"read"}, want: false},
+		{required: []string{"read:users", "admin"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := HasScope(claims, tt.required...); got != tt.want {
+			t.Errorf("HasScope(%q) = %v, want %v", tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestJWTAcceptedIssuers(t *testing.T) {
+	verifier := NewJsonWebToken("auth.newdomain.com", testKey).WithAcceptedIssuers("auth.olddomain.com")
+	claims := JWTClaims{ID: "user123"}
+
+	tests := []struct {
+		issuer string
+		err    error
+	}{
+		{issuer: "auth.newdomain.com"},
+		{issuer: "auth.olddomain.com"},
+		{issuer: "auth.attacker.com", err: jwt.ErrTokenInvalidIssuer},
+	}
+
+	for _, tt := range tests {
+		token, _ := NewJsonWebToken(tt.issuer, testKey).Generate(claims, nil)
+		if _, err := verifier.Verify(token); !errors.Is(err, tt.err) {
+			t.Errorf("Verify(iss=%s) error = %v, want %v", tt.issuer, err, tt.err)
+		}
+	}
+
+	single := NewJsonWebToken("auth.newdomain.com", testKey)
+	old, _ := NewJsonWebToken("auth.olddomain.com", testKey).Generate(claims, nil)
+	if _, err := single.Verify(old); !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Errorf("Verify() without accepted issuers error = %v, want %v", err, jwt.ErrTokenInvalidIssuer)
+	}
+}
+
+// orgClaims is a caller-defined claims type with a custom claim.
+type orgClaims struct {
+	OrgID string `json:"org_id"`
+	Scope string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+func TestJWTVerifyInto(t *testing.T) {
+	tkn := NewJsonWebToken("myapp.com", testKey)
+	now := time.Now()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, orgClaims{
+		OrgID: "org_42",
+		Scope: "read:billing",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "myapp.com",
+			Subject:   "user@example.com",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}).SignedString(testKey)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	var claims orgClaims
+	if err := tkn.VerifyInto(token, &claims); err != nil {
+		t.Fatalf("VerifyInto() error = %v", err)
+	}
+	if claims.OrgID != "org_42" || claims.Scope != "read:billing" || claims.Subject != "user@example.com" {
+		t.Errorf("VerifyInto() = %+v, want the custom and registered claims", claims)
+	}
+
+	var rejected orgClaims
+	if err := NewJsonWebToken("other.com", testKey).VerifyInto(token, &rejected); err == nil || rejected.OrgID != "" {
+		t.Errorf("VerifyInto(wrong issuer) = %+v, %v; want an error and no claims", rejected, err)
+	}
+}
+
+func TestJWTMaxTokenAge(t *testing.T) {
+	tkn := NewJsonWebToken("myapp.com", testKey).WithMaxTokenAge(30 * time.Minute)
+	claims := JWTClaims{ID: "user123"}
+
+	fresh := issuedAgo(t, tkn, claims, time.Minute)
+	if _, err := tkn.Verify(fresh); err != nil {
+		t.Errorf("Verify(fresh token) error = %v, want nil", err)
+	}
+
+	old := issuedAgo(t, tkn, claims, 45*time.Minute)
+	if _, err := tkn.Verify(old); !errors.Is(err, ErrTokenTooOld) {
+		t.Errorf("Verify(old token) error = %v, want %v", err, ErrTokenTooOld)
+	}
+	if _, _, err := tkn.Refresh(old, time.Hour); !errors.Is(err, ErrTokenTooOld) {
+		t.Errorf("Refresh(old token) error = %v, want %v", err, ErrTokenTooOld)
+	}
+
+	noIAT, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "myapp.com",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(testKey)
+	if _, err := tkn.Verify(noIAT); !errors.Is(err, ErrTokenTooOld) {
+		t.Errorf("Verify(token without iat) error = %v, want %v", err, ErrTokenTooOld)
+	}
+
+	if _, err := NewJsonWebToken("myapp.com", testKey).Verify(old); err != nil {
+		t.Errorf("Verify(old token) without a max age error = %v, want nil", err)
+	}
+}
+
+func TestJWTTimeUntilExpiry(t *testing.T) {
+	tkn := NewJsonWebToken("myapp.com", testKey)
+	claims := JWTClaims{ID: "user123"}
+
+	lifetime := 30
+	fresh, _ := tkn.Generate(claims, &lifetime)
+	remaining, err := tkn.TimeUntilExpiry(fresh)
+	if err != nil {
+		t.Fatalf("TimeUntilExpiry() error = %v", err)
+	}
+	if remaining <= 29*time.Minute || remaining > 30*time.Minute {
+		t.Errorf("TimeUntilExpiry() = %s, want just under 30m", remaining)
+	}
+
+	expired := issuedAgo(t, tkn, claims, 2*time.Hour)
+	if _, err := tkn.TimeUntilExpiry(expired); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("TimeUntilExpiry(expired) error = %v, want %v", err, jwt.ErrTokenExpired)
+	}
+
+	noExpiry, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Issuer: "myapp.com"}).SignedString(testKey)
+	if _, err := tkn.TimeUntilExpiry(noExpiry); !errors.Is(err, errTokenNoExpiry) {
+		t.Errorf("TimeUntilExpiry(no exp) error = %v, want %v", err, errTokenNoExpiry)
+	}
+
+	if _, err := NewJsonWebToken("myapp.com", []byte("other-key")).TimeUntilExpiry(fresh); err == nil {
+		t.Error("TimeUntilExpiry() with another key error = nil, want a signature error")
+	}
+}
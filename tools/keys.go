@@ -0,0 +1,169 @@
+package tools
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MapKeysToCamel returns a copy of a map with every key converted from snake_case to camelCase.
+// This is useful when bridging snake_case JSON APIs with camelCase internals. Nested
+// maps, including maps inside slices, are converted recursively; all other values are
+// copied untouched. Keys that are already camelCase are left as they are, all-caps
+// words are lowercased before being capitalized ("USER_NAME" becomes "userName"), and
+// leading underscores are kept ("_id" stays "_id").
+//
+// Several keys can convert to the same key, such as "user_id" and "userId". The key
+// already spelled in the converted form wins; otherwise the key that sorts first wins,
+// so the result does not depend on map iteration order.
+//
+// Example usage:
+//
+//	out := MapKeysToCamel(map[string]interface{}{
+//	    "user_id": 1,
+//	    "billing_address": map[string]interface{}{"postal_code": "10115"},
+//	})
+//	// Result: {"userId": 1, "billingAddress": {"postalCode": "10115"}}
+//
+// Parameters:
+//   - m: The map whose keys to convert
+//
+// Returns:
+//   - map[string]interface{}: A new map with camelCase keys
+func MapKeysToCamel(m map[string]interface{}) map[string]interface{} {
+	return convertMapKeys(m, toCamelCase)
+}
+
+// MapKeysToSnake returns a copy of a map with every key converted from camelCase to snake_case.
+// It is the inverse of MapKeysToCamel. Acronyms are kept together, so "userID" becomes
+// "user_id" and "HTTPServer" becomes "http_server". Nested maps, including maps inside
+// slices, are converted recursively; all other values are copied untouched. Colliding
+// keys such as "userId" and "user_id" are resolved as in MapKeysToCamel.
+//
+// Example usage:
+//
+//	out := MapKeysToSnake(map[string]interface{}{"userId": 1, "createdAt": "2024-01-15"})
+//	// Result: {"user_id": 1, "created_at": "2024-01-15"}
+//
+// Parameters:
+//   - m: The map whose keys to convert
+//
+// Returns:
+//   - map[string]interface{}: A new map with snake_case keys
+func MapKeysToSnake(m map[string]interface{}) map[string]interface{} {
+	return convertMapKeys(m, toSnakeCase)
+}
+
+// convertMapKeys copies a map, converting its keys and those of any nested maps.
+// When several keys convert to the same key, the one that is unchanged by the
+// conversion wins, and otherwise the one that sorts first.
+//
+// Parameters:
+//   - m: The map to copy
+//   - convert: The key conversion
+//
+// Returns:
+//   - map[string]interface{}: The converted copy (nil if m is nil)
+func convertMapKeys(m map[string]interface{}, convert func(string) string) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := make(map[string]interface{}, len(m))
+	for _, key := range keys {
+		converted := convert(key)
+		if _, exists := out[converted]; exists && key != converted {
+			continue
+		}
+		out[converted] = convertValueKeys(m[key], convert)
+	}
+	return out
+}
+
+// convertValueKeys converts the keys of maps nested in a value, leaving other values untouched.
+//
+// Parameters:
+//   - value: The value to convert
+//   - convert: The key conversion
+//
+// Returns:
+//   - interface{}: The converted value
+func convertValueKeys(value interface{}, convert func(string) string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return convertMapKeys(v, convert)
+	case []map[string]interface{}:
+		out := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			out[i] = convertMapKeys(item, convert)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = convertValueKeys(item, convert)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// toCamelCase converts a snake_case key to camelCase (e.g., "user_id" to "userId").
+// All-caps words are lowercased first ("USER_NAME" to "userName") and leading
+// underscores are kept ("_id").
+//
+// Parameters:
+//   - s: The key to convert
+//
+// Returns:
+//   - string: The camelCase key
+func toCamelCase(s string) string {
+	rest := strings.TrimLeft(s, "_")
+
+	var b strings.Builder
+	b.WriteString(s[:len(s)-len(rest)])
+	for i, part := range strings.FieldsFunc(rest, func(r rune) bool { return r == '_' }) {
+		if strings.ToUpper(part) == part {
+			part = strings.ToLower(part)
+		}
+		runes := []rune(part)
+		if i == 0 {
+			runes[0] = unicode.ToLower(runes[0])
+		} else {
+			runes[0] = unicode.ToUpper(runes[0])
+		}
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+// toSnakeCase converts a camelCase key to snake_case (e.g., "userID" to "user_id").
+//
+// Parameters:
+//   - s: The key to convert
+//
+// Returns:
+//   - string: The snake_case key
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
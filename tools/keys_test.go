@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToCamelCase(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "user_id", want: "userId"},
+		{in: "USER_NAME", want: "userName"},
+		{in: "HTTP_server", want: "httpServer"},
+		{in: "_id", want: "_id"},
+		{in: "__meta_data", want: "__metaData"},
+		{in: "userId", want: "userId"},
+		{in: "userID", want: "userID"},
+		{in: "ID", want: "id"},
+		{in: "double__underscore_", want: "doubleUnderscore"},
+	}
+
+	for _, tt := range tests {
+		if got := toCamelCase(tt.in); got != tt.want {
+			t.Errorf("toCamelCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToSnakeCase(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "userId", want: "user_id"},
+		{in: "userID", want: "user_id"},
+		{in: "HTTPServer", want: "http_server"},
+		{in: "_id", want: "_id"},
+		{in: "address2Line", want: "address2_line"},
+		{in: "user_id", want: "user_id"},
+	}
+
+	for _, tt := range tests {
+		if got := toSnakeCase(tt.in); got != tt.want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMapKeysToCamelNested(t *testing.T) {
+	in := map[string]interface{}{
+		"user_id": 1,
+		"billing_address": map[string]interface{}{
+			"POSTAL_CODE": "10115",
+		},
+		"line_items": []interface{}{
+			map[string]interface{}{"unit_price": 5},
+			"untouched_string",
+		},
+		"tags": []map[string]interface{}{{"tag_name": "a"}},
+	}
+	want := map[string]interface{}{
+		"userId": 1,
+		"billingAddress": map[string]interface{}{
+			"postalCode": "10115",
+		},
+		"lineItems": []interface{}{
+			map[string]interface{}{"unitPrice": 5},
+			"untouched_string",
+		},
+		"tags": []map[string]interface{}{{"tagName": "a"}},
+	}
+
+	if got := MapKeysToCamel(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("MapKeysToCamel() = %v, want %v", got, want)
+	}
+	if got := MapKeysToSnake(want); !reflect.DeepEqual(got["billing_address"], map[string]interface{}{"postal_code": "10115"}) {
+		t.Errorf("MapKeysToSnake() nested = %v, want postal_code", got["billing_address"])
+	}
+	if MapKeysToCamel(nil) != nil {
+		t.Error("MapKeysToCamel(nil) != nil")
+	}
+}
+
+func TestMapKeysCollisions(t *testing.T) {
+	for range 20 {
+		camel := MapKeysToCamel(map[string]interface{}{"user_id": "snake", "userId": "camel", "USER_ID": "caps"})
+		if len(camel) != 1 || camel["userId"] != "camel" {
+			t.Fatalf("MapKeysToCamel() = %v, want the camelCase key to win", camel)
+		}
+
+		mixed := MapKeysToCamel(map[string]interface{}{"user_id": "snake", "USER_ID": "caps"})
+		if mixed["userId"] != "caps" {
+			t.Fatalf("MapKeysToCamel() = %v, want the key that sorts first to win", mixed)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package tools
+
+// MergePolicy selects how MergeMaps resolves keys present in both maps.
+type MergePolicy int
+
+const (
+	// MergeOverride replaces the value in dst with the value from src.
+	MergeOverride MergePolicy = iota
+
+	// MergeKeepExisting keeps the value in dst and ignores the value from src.
+	MergeKeepExisting
+
+	// MergeDeep merges nested maps key by key; for any other conflict, including
+	// slices, the value from src replaces the value in dst.
+	MergeDeep
+
+	// MergeDeepAppend merges like MergeDeep, but appends []interface{} values from
+	// src to those in dst instead of replacing them.
+	MergeDeepAppend
+)
+
+// MergeMaps merges src into dst, resolving conflicting keys with the given policy.
+// This is useful for layering configuration (defaults, then file, then environment)
+// or combining decoded JSON objects. Keys only present in src are always added.
+//
+// Nested maps are merged only with MergeDeep and MergeDeepAppend, and only when both
+// values are map[string]interface{}; with the other policies a nested map is one
+// value like any other. Slices are replaced by default: only MergeDeepAppend appends,
+// and only for []interface{} values (as produced by encoding/json), so other slice
+// types are always replaced. Values taken from src are deep-copied, so later changes
+// to dst never modify src.
+//
+// Example usage:
+//
+//	defaults := map[string]interface{}{
+//	    "server": map[string]interface{}{"port": 8080, "timeout": "30s"},
+//	    "tags":   []interface{}{"api"},
+//	}
+//	overrides := map[string]interface{}{
+//	    "server": map[string]interface{}{"port": 9090},
+//	    "tags":   []interface{}{"internal"},
+//	}
+//	config := MergeMaps(defaults, overrides, MergeDeep)
+//	// Result: {"server": {"port": 9090, "timeout": "30s"}, "tags": ["internal"]}
+//	// With MergeDeepAppend, "tags" would be ["api", "internal"].
+//
+// Parameters:
+//   - dst: The map to merge into; it is modified in place (nil allocates a new map)
+//   - src: The map whose entries are merged into dst
+//   - policy: The conflict policy
+//
+// Returns:
+//   - map[string]interface{}: The merged map (dst, or a new map if dst was nil)
+func MergeMaps(dst, src map[string]interface{}, policy MergePolicy) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+
+	for key, srcValue := range src {
+		dstValue, exists := dst[key]
+		if !exists {
+			dst[key] = cloneMergeValue(srcValue)
+			continue
+		}
+
+		switch policy {
+		case MergeKeepExisting:
+			// dst wins
+		case MergeDeep, MergeDeepAppend:
+			dst[key] = mergeDeepValue(dstValue, srcValue, policy)
+		default:
+			dst[key] = cloneMergeValue(srcValue)
+		}
+	}
+	return dst
+}
+
+// mergeDeepValue resolves a conflicting key for the deep policies.
+//
+// Parameters:
+//   - dstValue: The value in dst
+//   - srcValue: The value in src
+//   - policy: MergeDeep or MergeDeepAppend
+//
+// Returns:
+//   - interface{}: The merged value
+func mergeDeepValue(dstValue, srcValue interface{}, policy MergePolicy) interface{} {
+	switch d := dstValue.(type) {
+	case map[string]interface{}:
+		if s, ok := srcValue.(map[string]interface{}); ok {
+			return MergeMaps(d, s, policy)
+		}
+	case []interface{}:
+		if s, ok := srcValue.([]interface{}); ok && policy == MergeDeepAppend {
+			return append(d, cloneMergeValue(s).([]interface{})...)
+		}
+	}
+	return cloneMergeValue(srcValue)
+}
+
+// cloneMergeValue deep-copies nested maps and []interface{} values; other values are returned as is.
+//
+// Parameters:
+//   - value: The value to copy
+//
+// Returns:
+//   - interface{}: The copy
+func cloneMergeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		clone := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			clone[key] = cloneMergeValue(item)
+		}
+		return clone
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneMergeValue(item)
+		}
+		return clone
+	default:
+		return value
+	}
+}
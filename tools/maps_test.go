@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+// mergeFixtures returns fresh defaults and overrides for the MergeMaps tests.
+func mergeFixtures() (defaults, overrides map[string]interface{}) {
+	defaults = map[string]interface{}{
+		"name":   "api",
+		"server": map[string]interface{}{"port": 8080, "timeout": "30s"},
+		"tags":   []interface{}{"api"},
+	}
+	overrides = map[string]interface{}{
+		"server": map[string]interface{}{"port": 9090},
+		"tags":   []interface{}{"internal"},
+		"debug":  true,
+	}
+	return defaults, overrides
+}
+
+func TestMergeMaps(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy MergePolicy
+		want   map[string]interface{}
+	}{
+		{
+			name:   "override",
+			policy: MergeOverride,
+			want: map[string]interface{}{
+				"name":   "api",
+				"server": map[string]interface{}{"port": 9090},
+				"tags":   []interface{}{"internal"},
+				"debug":  true,
+			},
+		},
+		{
+			name:   "keep existing",
+			policy: MergeKeepExisting,
+			want: map[string]interface{}{
+				"name":   "api",
+				"server": map[string]interface{}{"port": 8080, "timeout": "30s"},
+				"tags":   []interface{}{"api"},
+				"debug":  true,
+			},
+		},
+		{
+			name:   "deep",
+			policy: MergeDeep,
+			want: map[string]interface{}{
+				"name":   "api",
+				"server": map[string]interface{}{"port": 9090, "timeout": "30s"},
+				"tags":   []interface{}{"internal"},
+				"debug":  true,
+			},
+		},
+		{
+			name:   "deep append",
+			policy: MergeDeepAppend,
+			want: map[string]interface{}{
+				"name":   "api",
+				"server": map[string]interface{}{"port": 9090, "timeout": "30s"},
+				"tags":   []interface{}{"api", "internal"},
+				"debug":  true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults, overrides := mergeFixtures()
+			if got := MergeMaps(defaults, overrides, tt.policy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeMaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeMapsDeepNested(t *testing.T) {
+	dst := map[string]interface{}{
+		"db": map[string]interface{}{
+			"primary": map[string]interface{}{"host": "localhost", "port": 5432},
+		},
+		"cache": map[string]interface{}{"ttl": "5m"},
+	}
+	src := map[string]interface{}{
+		"db": map[string]interface{}{
+			"primary": map[string]interface{}{"host": "db.internal"},
+			"replica": map[string]interface{}{"host": "replica.internal"},
+		},
+		"cache": "disabled",
+	}
+	want := map[string]interface{}{
+		"db": map[string]interface{}{
+			"primary": map[string]interface{}{"host": "db.internal", "port": 5432},
+			"replica": map[string]interface{}{"host": "replica.internal"},
+		},
+		"cache": "disabled",
+	}
+
+	if got := MergeMaps(dst, src, MergeDeep); !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeMaps() = %v, want %v", got, want)
+	}
+
+	// Values taken from src are copies, so changing the result leaves src untouched.
+	dst["db"].(map[string]interface{})["replica"].(map[string]interface{})["host"] = "changed"
+	if got := src["db"].(map[string]interface{})["replica"].(map[string]interface{})["host"]; got != "replica.internal" {
+		t.Errorf("src replica host = %v after changing the result, want replica.internal", got)
+	}
+}
+
+func TestMergeMapsNilDst(t *testing.T) {
+	src := map[string]interface{}{"tags": []interface{}{"api"}}
+	got := MergeMaps(nil, src, MergeOverride)
+	if !reflect.DeepEqual(got, src) {
+		t.Errorf("MergeMaps(nil) = %v, want %v", got, src)
+	}
+	got["tags"].([]interface{})[0] = "changed"
+	if src["tags"].([]interface{})[0] != "api" {
+		t.Error("MergeMaps(nil) shares slices with src, want a copy")
+	}
+}
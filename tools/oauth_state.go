@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"time"
+
+	"github.com/arbenlabs/anvil/internal/ttlmap"
+)
+
+// stateTokenBytes is the number of random bytes in a state token.
+const stateTokenBytes = 32
+
+// GenerateStateToken creates a random state parameter for an OAuth authorization request.
+// The state ties the authorization callback to the browser session that started the
+// flow, which prevents cross-site request forgery against the callback. The token
+// holds 32 random bytes encoded as unpadded base64url, so it can be placed in a URL
+// as is. Store it with a StateStore and consume it when the callback arrives.
+//
+// Example usage:
+//
+//	state, err := GenerateStateToken()
+//	if err != nil {
+//	    return err
+//	}
+//	states.Save(state, returnURL, 10*time.Minute)
+//	http.Redirect(w, r, oauthConfig.AuthCodeURL(state), http.StatusFound)
+//
+// Returns:
+//   - string: The base64url-encoded state token (43 characters)
+//   - error: Any error that occurred during random generation
+func GenerateStateToken() (string, error) {
+	return GenerateSecureToken(stateTokenBytes)
+}
+
+// StateStore stores OAuth state values until they are consumed by the callback.
+// Implementations must be safe for concurrent use, and Consume must remove the state
+// atomically so a state can be used only once, which prevents replaying a callback.
+// Services running several instances need a shared implementation (e.g., Redis GETDEL).
+type StateStore interface {
+	// Save stores the state with an associated value (e.g., a return URL or PKCE verifier) for the given TTL.
+	Save(state, value string, ttl time.Duration)
+
+	// Consume removes the state and returns its value. It returns false if the state is unknown, already consumed or expired.
+	Consume(state string) (string, bool)
+}
+
+// MemoryStateStore is an in-memory StateStore with TTL eviction.
+// States are kept in the memory of the process, so the callback must reach the
+// instance that started the flow.
+type MemoryStateStore struct {
+	states *ttlmap.Map[string]
+}
+
+// NewMemoryStateStore creates a new, empty in-memory state store.
+//
+// Example usage:
+//
+//	states := NewMemoryStateStore()
+//
+// Returns:
+//   - *MemoryStateStore: A new in-memory state store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{states: ttlmap.New[string]()}
+}
+
+// Save stores the state with an associated value for the given TTL.
+// Saving a state again replaces its value and expiry. Expired states are swept at
+// most once per minute during calls to Save.
+//
+// Parameters:
+//   - state: The state token
+//   - value: The value to return when the state is consumed
+//   - ttl: How long the state remains valid
+func (s *MemoryStateStore) Save(state, value string, ttl time.Duration) {
+	s.states.Set(state, value, time.Now().Add(ttl))
+}
+
+// Consume removes the state and returns its value.
+// A state can be consumed only once; later calls with the same state return false.
+//
+// Example usage:
+//
+//	returnURL, ok := states.Consume(r.URL.Query().Get("state"))
+//	if !ok {
+//	    // unknown, reused or expired state: reject the callback
+//	}
+//
+// Parameters:
+//   - state: The state token received by the callback
+//
+// Returns:
+//   - string: The value saved with the state
+//   - bool: true if the state was valid, false if it is unknown, already consumed or expired
+func (s *MemoryStateStore) Consume(state string) (string, bool) {
+	return s.states.Take(state)
+}
@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// errInvalidPatchTarget is returned when ApplyPartialUpdate is given something
+// other than a non-nil pointer to a struct.
+var errInvalidPatchTarget = errors.New("the patch target must be a non-nil pointer to a struct")
+
+// ApplyPartialUpdate applies a JSON partial update (PATCH body) onto a target struct.
+// This function decodes the patch into a map[string]json.RawMessage so that it can
+// distinguish between a field that was omitted and a field that was explicitly set
+// to its zero value or to null. Only keys present in the patch are applied.
+//
+// The semantics for each struct field are:
+//   - Omitted: the key is absent from the patch and the field is left unchanged
+//   - Explicit null: the key is present with a null value and the field is cleared
+//     (set to its zero value, e.g. "" for strings or nil for pointers)
+//   - Set value: the key is present with a non-null value, which is decoded into the field
+//
+// Keys are matched against struct fields using their json tag name, falling back to
+// the field name, with the same case-insensitive matching as encoding/json. Fields
+// tagged with json:"-" and unexported fields are never updated, and unknown keys are
+// ignored. Nested objects replace the existing field value rather than being merged.
+//
+// The patch is applied to a copy of the target, which is written back only once every
+// field has been decoded, so a patch with an invalid value leaves the target unchanged.
+//
+// Example usage:
+//
+//	user := loadUser(id) // {Name: "John", Bio: "Hello", Age: 30}
+//	applied, err := ApplyPartialUpdate([]byte(`{"name":"Jane","bio":null}`), &user)
+//	if err != nil {
+//	    // handle malformed patch
+//	}
+//	// user is now {Name: "Jane", Bio: "", Age: 30}
+//	// applied is ["bio", "name"]
+//
+// Parameters:
+//   - patch: The raw JSON object containing the fields to update
+//   - target: A pointer to the struct to update
+//
+// Returns:
+//   - []string: The sorted list of patch keys that were applied to the target
+//   - error: Any error that occurred while decoding the patch or a field value
+func ApplyPartialUpdate(patch []byte, target interface{}) ([]string, error) {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, errInvalidPatchTarget
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return nil, err
+	}
+
+	elem := reflect.New(rv.Elem().Type()).Elem()
+	elem.Set(rv.Elem())
+	index := patchFieldIndex(elem.Type())
+
+	applied := make([]string, 0, len(fields))
+	for key, raw := range fields {
+		fieldIndex, ok := lookupPatchField(index, key)
+		if !ok {
+			continue
+		}
+
+		field := elem.Field(fieldIndex)
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			field.Set(reflect.Zero(field.Type()))
+		} else {
+			value := reflect.New(field.Type())
+			if err := json.Unmarshal(raw, value.Interface()); err != nil {
+				return nil, fmt.Errorf("invalid value for field %q: %w", key, err)
+			}
+			field.Set(value.Elem())
+		}
+		applied = append(applied, key)
+	}
+
+	rv.Elem().Set(elem)
+	sort.Strings(applied)
+	return applied, nil
+}
+
+// patchFieldIndex maps the JSON names of the exported fields of a struct type to their index.
+//
+// Parameters:
+//   - t: The struct type to index
+//
+// Returns:
+//   - map[string]int: The JSON field name mapped to the struct field index
+func patchFieldIndex(t reflect.Type) map[string]int {
+	index := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		index[name] = i
+	}
+	return index
+}
+
+// lookupPatchField finds the struct field index for a patch key.
+// An exact match is preferred, falling back to a case-insensitive match.
+//
+// Parameters:
+//   - index: The field index built by patchFieldIndex
+//   - key: The patch key to look up
+//
+// Returns:
+//   - int: The struct field index
+//   - bool: true if a matching field was found, false otherwise
+func lookupPatchField(index map[string]int, key string) (int, bool) {
+	if i, ok := index[key]; ok {
+		return i, true
+	}
+	for name, i := range index {
+		if strings.EqualFold(name, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// patchUser is the target of the partial update tests.
+type patchUser struct {
+	Name     string  `json:"name"`
+	Bio      string  `json:"bio"`
+	Age      int     `json:"age"`
+	Nickname *string `json:"nickname"`
+	Secret   string  `json:"-"`
+}
+
+func TestApplyPartialUpdate(t *testing.T) {
+	nickname := "jj"
+	tests := []struct {
+		name    string
+		patch   string
+		want    patchUser
+		applied []string
+	}{
+		{
+			name:    "omitted",
+			patch:   `{}`,
+			want:    patchUser{Name: "John", Bio: "Hello", Age: 30, Nickname: &nickname, Secret: "s"},
+			applied: []string{},
+		},
+		{
+			name:    "null",
+			patch:   `{"bio":null,"nickname":null}`,
+			want:    patchUser{Name: "John", Age: 30, Secret: "s"},
+			applied: []string{"bio", "nickname"},
+		},
+		{
+			name:    "set",
+			patch:   `{"name":"Jane","age":0}`,
+			want:    patchUser{Name: "Jane", Bio: "Hello", Nickname: &nickname, Secret: "s"},
+			applied: []string{"age", "name"},
+		},
+		{
+			name:    "case-insensitive and ignored keys",
+			patch:   `{"NAME":"Jane","Secret":"x","unknown":1}`,
+			want:    patchUser{Name: "Jane", Bio: "Hello", Age: 30, Nickname: &nickname, Secret: "s"},
+			applied: []string{"NAME"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := patchUser{Name: "John", Bio: "Hello", Age: 30, Nickname: &nickname, Secret: "s"}
+			applied, err := ApplyPartialUpdate([]byte(tt.patch), &user)
+			if err != nil {
+				t.Fatalf("ApplyPartialUpdate() error = %v", err)
+			}
+			if !reflect.DeepEqual(user, tt.want) {
+				t.Errorf("user = %+v, want %+v", user, tt.want)
+			}
+			if !reflect.DeepEqual(applied, tt.applied) {
+				t.Errorf("applied = %v, want %v", applied, tt.applied)
+			}
+		})
+	}
+}
+
+func TestApplyPartialUpdateInvalidValueLeavesTargetUnchanged(t *testing.T) {
+	user := patchUser{Name: "John", Bio: "Hello", Age: 30}
+	before := user
+
+	if _, err := ApplyPartialUpdate([]byte(`{"name":"Jane","bio":null,"age":"thirty"}`), &user); err == nil {
+		t.Fatal("ApplyPartialUpdate() error = nil, want an invalid value error")
+	}
+	if user != before {
+		t.Errorf("user = %+v, want it unchanged: %+v", user, before)
+	}
+}
+
+func TestApplyPartialUpdateInvalidTarget(t *testing.T) {
+	var nilUser *patchUser
+	for _, target := range []interface{}{patchUser{}, nilUser, new(int)} {
+		if _, err := ApplyPartialUpdate([]byte(`{}`), target); !errors.Is(err, errInvalidPatchTarget) {
+			t.Errorf("ApplyPartialUpdate(%T) error = %v, want %v", target, err, errInvalidPatchTarget)
+		}
+	}
+}
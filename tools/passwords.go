@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword is returned by ValidatePasswordStrength when a password violates the policy.
+// The returned error wraps it and names the failed rule, e.g.
+// "password does not meet the policy: must be at least 12 characters".
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// PasswordPolicy describes the rules enforced by ValidatePasswordStrength.
+// Zero values disable the corresponding rule, so PasswordPolicy{MinLength: 8} only
+// enforces a minimum length.
+type PasswordPolicy struct {
+	MinLength     int  // Minimum length in characters (runes)
+	MaxLength     int  // Maximum length in characters; guards the hasher against huge inputs
+	RequireUpper  bool // Require at least one uppercase letter
+	RequireLower  bool // Require at least one lowercase letter
+	RequireDigit  bool // Require at least one digit
+	RequireSymbol bool // Require at least one character that is not a letter, digit or space
+
+	// IsBreached optionally reports whether the password appears in a list of breached
+	// passwords (for example via the Have I Been Pwned range API). It is called only
+	// after every other rule has passed.
+	IsBreached func(password string) (bool, error)
+}
+
+// DefaultPasswordPolicy returns a policy following current NIST guidance: at least 12
+// characters, at most 128, and no character class requirements.
+//
+// Returns:
+//   - PasswordPolicy: The default policy
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: 12, MaxLength: 128}
+}
+
+// ValidatePasswordStrength checks a password against a policy before it is hashed.
+// Rules are checked in order (length, character classes, then the breached-password
+// hook) and the first failure is returned as an error wrapping ErrWeakPassword that
+// names the failed rule, so it can be shown to the user directly.
+//
+// Example usage:
+//
+//	policy := PasswordPolicy{MinLength: 12, RequireDigit: true}
+//	if err := ValidatePasswordStrength(password, policy); err != nil {
+//	    // err: "password does not meet the policy: must contain a digit"
+//	}
+//	hash, err := GenerateHashString(password)
+//
+// Parameters:
+//   - pw: The password to validate
+//   - policy: The rules to enforce
+//
+// Returns:
+//   - error: An error naming the failed rule, an error from the IsBreached hook, or nil if the password is acceptable
+func ValidatePasswordStrength(pw string, policy PasswordPolicy) error {
+	length := utf8.RuneCountInString(pw)
+	if policy.MinLength > 0 && length < policy.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, policy.MinLength)
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		return fmt.Errorf("%w: must be at most %d characters", ErrWeakPassword, policy.MaxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range pw {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	switch {
+	case policy.RequireUpper && !hasUpper:
+		return fmt.Errorf("%w: must contain an uppercase letter", ErrWeakPassword)
+	case policy.RequireLower && !hasLower:
+		return fmt.Errorf("%w: must contain a lowercase letter", ErrWeakPassword)
+	case policy.RequireDigit && !hasDigit:
+		return fmt.Errorf("%w: must contain a digit", ErrWeakPassword)
+	case policy.RequireSymbol && !hasSymbol:
+		return fmt.Errorf("%w: must contain a symbol", ErrWeakPassword)
+	}
+
+	if policy.IsBreached != nil {
+		breached, err := policy.IsBreached(pw)
+		if err != nil {
+			return fmt.Errorf("unable to check breached passwords: %w", err)
+		}
+		if breached {
+			return fmt.Errorf("%w: appears in a list of breached passwords", ErrWeakPassword)
+		}
+	}
+
+	return nil
+}
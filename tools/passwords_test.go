@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidatePasswordStrength(t *testing.T) {
+	strict := PasswordPolicy{MinLength: 10, MaxLength: 64, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		name   string
+		pw     string
+		policy PasswordPolicy
+		rule   string
+	}{
+		{name: "compliant", pw: "Correct-Horse-7", policy: strict},
+		{name: "too short", pw: "Ab1!", policy: strict, rule: "must be at least 10 characters"},
+		{name: "too long", pw: strings.Repeat("Ab1!", 20), policy: strict, rule: "must be at most 64 characters"},
+		{name: "missing uppercase", pw: "correct-horse-7", policy: strict, rule: "must contain an uppercase letter"},
+		{name: "missing lowercase", pw: "CORRECT-HORSE-7", policy: strict, rule: "must contain a lowercase letter"},
+		{name: "missing digit", pw: "Correct-Horse-X", policy: strict, rule: "must contain a digit"},
+		{name: "missing symbol", pw: "Correct Horse 7", policy: strict, rule: "must contain a symbol"},
+		{name: "length counts runes", pw: "пароль-пароль", policy: PasswordPolicy{MinLength: 13}},
+		{name: "default policy", pw: "correct horse battery", policy: DefaultPasswordPolicy()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePasswordStrength(tt.pw, tt.policy)
+			if tt.rule == "" {
+				if err != nil {
+					t.Errorf("ValidatePasswordStrength() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrWeakPassword) || !strings.HasSuffix(err.Error(), tt.rule) {
+				t.Errorf("ValidatePasswordStrength() error = %v, want %q", err, tt.rule)
+			}
+		})
+	}
+}
+
+func TestValidatePasswordStrengthBreached(t *testing.T) {
+	errUnavailable := errors.New("breach service unavailable")
+	policy := PasswordPolicy{MinLength: 8, IsBreached: func(pw string) (bool, error) {
+		switch pw {
+		case "password123":
+			return true, nil
+		case "timeout-please":
+			return false, errUnavailable
+		}
+		return false, nil
+	}}
+
+	if err := ValidatePasswordStrength("password123", policy); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("ValidatePasswordStrength(breached) error = %v, want %v", err, ErrWeakPassword)
+	}
+	if err := ValidatePasswordStrength("timeout-please", policy); !errors.Is(err, errUnavailable) || errors.Is(err, ErrWeakPassword) {
+		t.Errorf("ValidatePasswordStrength(hook failure) error = %v, want %v", err, errUnavailable)
+	}
+	if err := ValidatePasswordStrength("unbreached-pw", policy); err != nil {
+		t.Errorf("ValidatePasswordStrength(unbreached) error = %v, want nil", err)
+	}
+	if err := ValidatePasswordStrength("short", policy); !strings.Contains(err.Error(), "at least 8") {
+		t.Errorf("ValidatePasswordStrength(short) error = %v, want the length rule before the hook", err)
+	}
+}
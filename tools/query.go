@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// QueryParamError is returned when a query parameter value cannot be parsed.
+// It names the parameter and the offending value so the error can be shown to the
+// client directly, and wraps the underlying parse error.
+type QueryParamError struct {
+	Key   string // The query parameter name
+	Value string // The value that could not be parsed
+	Err   error  // The underlying parse error
+}
+
+// Error returns a message naming the parameter and the invalid value.
+func (e *QueryParamError) Error() string {
+	return fmt.Sprintf("invalid value %q for query parameter %s", e.Value, e.Key)
+}
+
+// Unwrap returns the underlying parse error.
+func (e *QueryParamError) Unwrap() error {
+	return e.Err
+}
+
+// QueryStrings returns the values of a list query parameter.
+// Values may be given comma-separated ("?tags=a,b"), as repeated parameters
+// ("?tags=a&tags=b"), or both. Each value is trimmed of surrounding whitespace and
+// empty values are skipped.
+//
+// Example usage:
+//
+//	// GET /api/posts?tags=go, http&tags=api
+//	tags := QueryStrings(r, "tags")
+//	// Result: []string{"go", "http", "api"}
+//
+// Parameters:
+//   - r: The HTTP request
+//   - key: The query parameter name
+//
+// Returns:
+//   - []string: The values in order (empty if the parameter is absent)
+func QueryStrings(r *http.Request, key string) []string {
+	var values []string
+	for _, param := range r.URL.Query()[key] {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// QueryInts returns the values of a list query parameter parsed as integers.
+// Values are split and trimmed like QueryStrings.
+//
+// Example usage:
+//
+//	// GET /api/users?ids=1,2,3
+//	ids, err := QueryInts(r, "ids")
+//	if err != nil {
+//	    // err: invalid value "x" for query parameter ids
+//	}
+//
+// Parameters:
+//   - r: The HTTP request
+//   - key: The query parameter name
+//
+// Returns:
+//   - []int: The parsed values in order (empty if the parameter is absent)
+//   - error: A *QueryParamError for the first value that is not an integer
+func QueryInts(r *http.Request, key string) ([]int, error) {
+	values := QueryStrings(r, key)
+	ints := make([]int, 0, len(values))
+	for _, value := range values {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, &QueryParamError{Key: key, Value: value, Err: err}
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+// QueryInt64s returns the values of a list query parameter parsed as 64-bit integers.
+// Values are split and trimmed like QueryStrings.
+//
+// Parameters:
+//   - r: The HTTP request
+//   - key: The query parameter name
+//
+// Returns:
+//   - []int64: The parsed values in order (empty if the parameter is absent)
+//   - error: A *QueryParamError for the first value that is not a 64-bit integer
+func QueryInt64s(r *http.Request, key string) ([]int64, error) {
+	values := QueryStrings(r, key)
+	ints := make([]int64, 0, len(values))
+	for _, value := range values {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, &QueryParamError{Key: key, Value: value, Err: err}
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
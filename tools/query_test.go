@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestQueryStrings(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "tags=go,http", want: []string{"go", "http"}},
+		{query: "tags=go&tags=http", want: []string{"go", "http"}},
+		{query: "tags=go,%20http&tags=api", want: []string{"go", "http", "api"}},
+		{query: "tags=go,,%20,http", want: []string{"go", "http"}},
+		{query: "other=go", want: nil},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/posts?"+tt.query, nil)
+		if got := QueryStrings(r, "tags"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QueryStrings(%s) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQueryInts(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/users?ids=1,%202&ids=3", nil)
+	if got, err := QueryInts(r, "ids"); err != nil || !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("QueryInts() = %v, %v; want [1 2 3], nil", got, err)
+	}
+	if got, err := QueryInt64s(r, "ids"); err != nil || !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Errorf("QueryInt64s() = %v, %v; want [1 2 3], nil", got, err)
+	}
+
+	invalid := httptest.NewRequest(http.MethodGet, "/users?ids=1,x,3", nil)
+	_, err := QueryInts(invalid, "ids")
+	var paramErr *QueryParamError
+	if !errors.As(err, &paramErr) || paramErr.Key != "ids" || paramErr.Value != "x" {
+		t.Fatalf("QueryInts(invalid) error = %v, want a QueryParamError for ids=x", err)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("QueryInts(invalid) error = %v, want it to wrap %v", err, strconv.ErrSyntax)
+	}
+
+	overflow := httptest.NewRequest(http.MethodGet, "/users?ids=99999999999999999999", nil)
+	if _, err := QueryInt64s(overflow, "ids"); !errors.As(err, &paramErr) {
+		t.Errorf("QueryInt64s(overflow) error = %v, want a QueryParamError", err)
+	}
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidRange is returned by ParseRange when the Range header is malformed.
+	// Servers should ignore such a header and send the full content with 200 (OK).
+	ErrInvalidRange = errors.New("invalid range")
+
+	// ErrRangeNotSatisfiable is returned by ParseRange when no range overlaps the content.
+	// Servers should answer with 416 (Range Not Satisfiable) and "Content-Range: bytes */<size>".
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+)
+
+// HTTPRange is a byte range of content requested with a Range header.
+type HTTPRange struct {
+	Start  int64 // The offset of the first byte
+	Length int64 // The number of bytes
+}
+
+// ContentRange returns the Content-Range header value of the range.
+//
+// Example usage:
+//
+//	w.Header().Set("Content-Range", rng.ContentRange(size)) // "bytes 0-499/1234"
+//
+// Parameters:
+//   - size: The total size of the content
+//
+// Returns:
+//   - string: The Content-Range value (e.g., "bytes 0-499/1234")
+func (r HTTPRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.Start+r.Length-1, size)
+}
+
+// ParseRange parses a Range header (RFC 9110) for content of the given size.
+// It is meant for custom streaming handlers, for example serving objects from
+// storage where http.ServeContent cannot be used. The following forms are supported,
+// also combined in one comma-separated header for multiple ranges:
+//
+//	bytes=0-499     the first 500 bytes
+//	bytes=500-      everything from offset 500
+//	bytes=-500      the last 500 bytes (suffix range)
+//
+// Ranges extending beyond the content are truncated to its end. Ranges starting
+// beyond the end are skipped; if none remain, ErrRangeNotSatisfiable is returned.
+//
+// Example usage:
+//
+//	ranges, err := ParseRange(r.Header.Get("Range"), size)
+//	switch {
+//	case errors.Is(err, ErrRangeNotSatisfiable):
+//	    w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+//	    w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+//	case err != nil || len(ranges) != 1:
+//	    // serve the full object with 200
+//	default:
+//	    w.Header().Set("Content-Range", ranges[0].ContentRange(size))
+//	    w.WriteHeader(http.StatusPartialContent)
+//	    // copy ranges[0].Length bytes starting at ranges[0].Start
+//	}
+//
+// Parameters:
+//   - header: The Range header value (empty for none)
+//   - size: The total size of the content in bytes
+//
+// Returns:
+//   - []HTTPRange: The satisfiable ranges in request order (nil if header is empty)
+//   - error: ErrInvalidRange if the header is malformed, ErrRangeNotSatisfiable if no range overlaps the content
+func ParseRange(header string, size int64) ([]HTTPRange, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, ErrInvalidRange
+	}
+
+	var ranges []HTTPRange
+	unsatisfiable := false
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, ErrInvalidRange
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var rng HTTPRange
+		if first == "" {
+			// Suffix range: the last n bytes.
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, ErrInvalidRange
+			}
+			if n == 0 || size == 0 {
+				unsatisfiable = true
+				continue
+			}
+			n = min(n, size)
+			rng = HTTPRange{Start: size - n, Length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, ErrInvalidRange
+			}
+			if start >= size {
+				unsatisfiable = true
+				continue
+			}
+
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, ErrInvalidRange
+				}
+				end = min(end, size-1)
+			}
+			rng = HTTPRange{Start: start, Length: end - start + 1}
+		}
+		ranges = append(ranges, rng)
+	}
+
+	if len(ranges) == 0 {
+		if unsatisfiable {
+			return nil, ErrRangeNotSatisfiable
+		}
+		return nil, ErrInvalidRange
+	}
+	return ranges, nil
+}
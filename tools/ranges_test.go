@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	const size = 1000
+	tests := []struct {
+		name   string
+		header string
+		want   []HTTPRange
+		err    error
+	}{
+		{name: "none", header: ""},
+		{name: "single", header: "bytes=0-499", want: []HTTPRange{{Start: 0, Length: 500}}},
+		{name: "open ended", header: "bytes=500-", want: []HTTPRange{{Start: 500, Length: 500}}},
+		{name: "truncated", header: "bytes=900-1999", want: []HTTPRange{{Start: 900, Length: 100}}},
+		{name: "suffix", header: "bytes=-200", want: []HTTPRange{{Start: 800, Length: 200}}},
+		{name: "suffix larger than content", header: "bytes=-5000", want: []HTTPRange{{Start: 0, Length: size}}},
+		{name: "multi", header: "bytes=0-99, 200-299,-50", want: []HTTPRange{{Start: 0, Length: 100}, {Start: 200, Length: 100}, {Start: 950, Length: 50}}},
+		{name: "multi skipping unsatisfiable", header: "bytes=2000-2999,0-9", want: []HTTPRange{{Start: 0, Length: 10}}},
+		{name: "unsatisfiable", header: "bytes=1000-1999", err: ErrRangeNotSatisfiable},
+		{name: "zero suffix", header: "bytes=-0", err: ErrRangeNotSatisfiable},
+		{name: "other unit", header: "items=0-9", err: ErrInvalidRange},
+		{name: "reversed", header: "bytes=500-100", err: ErrInvalidRange},
+		{name: "not a number", header: "bytes=a-b", err: ErrInvalidRange},
+		{name: "missing dash", header: "bytes=100", err: ErrInvalidRange},
+		{name: "empty spec", header: "bytes=", err: ErrInvalidRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRange(tt.header, size)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseRange(%q) error = %v, want %v", tt.header, err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRange(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseRange("bytes=-100", 0); !errors.Is(err, ErrRangeNotSatisfiable) {
+		t.Errorf("ParseRange() of empty content error = %v, want %v", err, ErrRangeNotSatisfiable)
+	}
+}
+
+func TestHTTPRangeContentRange(t *testing.T) {
+	if got := (HTTPRange{Start: 0, Length: 500}).ContentRange(1234); got != "bytes 0-499/1234" {
+		t.Errorf("ContentRange() = %q, want bytes 0-499/1234", got)
+	}
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedRequest returns a request signed with testKey over the host and content type.
+func signedRequest(t *testing.T, target, body string) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if err := SignRequest(r, "reports-service", testKey, "Host", "content-type"); err != nil {
+		t.Fatalf("SignRequest() error = %v", err)
+	}
+	return r
+}
+
+func TestVerifyRequestSignature(t *testing.T) {
+	const body = `{"amount":100}`
+	r := signedRequest(t, "https://billing.internal/api/charges?b=2&a=1&a=0", body)
+
+	if got := r.Header.Get(SignatureHeadersHeader); got != "content-type;host" {
+		t.Errorf("%s = %q, want content-type;host", SignatureHeadersHeader, got)
+	}
+	if err := VerifyRequestSignature(r, []byte(body), testKey, RequestSignatureTolerance); err != nil {
+		t.Errorf("VerifyRequestSignature() error = %v, want nil", err)
+	}
+
+	// Intermediaries may reorder query parameters without invalidating the signature.
+	r.URL.RawQuery = "a=0&a=1&b=2"
+	if err := VerifyRequestSignature(r, []byte(body), testKey, RequestSignatureTolerance); err != nil {
+		t.Errorf("VerifyRequestSignature(reordered query) error = %v, want nil", err)
+	}
+}
+
+func TestVerifyRequestSignatureRejects(t *testing.T) {
+	const body = `{"amount":100}`
+	tests := []struct {
+		name   string
+		tamper func(r *http.Request) (body string, secret []byte)
+		want   error
+	}{
+		{name: "tampered body", tamper: func(r *http.Request) (string, []byte) {
+			return `{"amount":100000}`, testKey
+		}, want: errSignatureMismatch},
+		{name: "tampered query", tamper: func(r *http.Request) (string, []byte) {
+			r.URL.RawQuery = "a=1&b=3"
+			return body, testKey
+		}, want: errSignatureMismatch},
+		{name: "tampered header", tamper: func(r *http.Request) (string, []byte) {
+			r.Header.Set("Content-Type", "text/plain")
+			return body, testKey
+		}, want: errSignatureMismatch},
+		{name: "other secret", tamper: func(r *http.Request) (string, []byte) {
+			return body, []byte("another-secret")
+		}, want: errSignatureMismatch},
+		{name: "expired timestamp", tamper: func(r *http.Request) (string, []byte) {
+			r.Header.Set(SignatureTimestampHeader, strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10))
+			return body, testKey
+		}, want: errSignatureTimestamp},
+		{name: "future timestamp", tamper: func(r *http.Request) (string, []byte) {
+			r.Header.Set(SignatureTimestampHeader, strconv.FormatInt(time.Now().Add(10*time.Minute).Unix(), 10))
+			return body, testKey
+		}, want: errSignatureTimestamp},
+		{name: "missing signature", tamper: func(r *http.Request) (string, []byte) {
+			r.Header.Del(SignatureHeader)
+			return body, testKey
+		}, want: errSignatureMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := signedRequest(t, "https://billing.internal/api/charges?a=1&b=2", body)
+			received, secret := tt.tamper(r)
+			if err := VerifyRequestSignature(r, []byte(received), secret, RequestSignatureTolerance); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyRequestSignature() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// DefaultBackoffInitial is the default delay before the first retry.
+	DefaultBackoffInitial = 100 * time.Millisecond
+
+	// DefaultBackoffMax is the default upper bound for the delay between retries.
+	DefaultBackoffMax = 10 * time.Second
+
+	// DefaultBackoffMultiplier is the default factor by which the delay grows after each retry.
+	DefaultBackoffMultiplier = 2.0
+)
+
+// BackoffConfig configures the exponential backoff used between retry attempts.
+// Zero values are replaced with the package defaults, so BackoffConfig{} is a
+// sensible starting point.
+type BackoffConfig struct {
+	Initial    time.Duration // Delay before the first retry (defaults to DefaultBackoffInitial)
+	Max        time.Duration // Upper bound for any single delay (defaults to DefaultBackoffMax)
+	Multiplier float64       // Growth factor applied after each retry (defaults to DefaultBackoffMultiplier)
+	Jitter     float64       // Fraction of each delay (0-1) that is randomized to avoid thundering herds
+}
+
+// RetryableError marks an error as transient so that Retry will try again.
+// Errors that are not wrapped in a RetryableError short-circuit the retry loop
+// and are returned immediately, because retrying them (e.g. validation or
+// authorization failures) would only produce the same result.
+type RetryableError struct {
+	Err error // The underlying transient error
+}
+
+// Error returns the message of the underlying error.
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error, allowing errors.Is and errors.As to inspect it.
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable wraps an error in a RetryableError.
+// Returning a wrapped error from the function passed to Retry signals that the
+// failure is transient and the call should be attempted again.
+//
+// Example usage:
+//
+//	if resp.StatusCode >= 500 {
+//	    return Retryable(fmt.Errorf("upstream returned %d", resp.StatusCode))
+//	}
+//
+// Parameters:
+//   - err: The transient error to wrap (nil is returned unchanged)
+//
+// Returns:
+//   - error: The error wrapped in a RetryableError, or nil if err is nil
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryableError{Err: err}
+}
+
+// Retry calls fn until it succeeds, returns a non-retryable error, or attempts run out.
+// This function is intended for outbound calls to flaky dependencies. Between attempts
+// it waits using exponential backoff with optional jitter, and it stops waiting as soon
+// as the context is cancelled.
+//
+// The function returns:
+//   - nil as soon as fn succeeds
+//   - The error from fn immediately if it is not a RetryableError
+//   - The last error from fn once all attempts are exhausted
+//   - The context error if the context is cancelled while waiting between attempts
+//
+// Example usage:
+//
+//	err := Retry(ctx, 5, BackoffConfig{Initial: 200 * time.Millisecond, Jitter: 0.2}, func() error {
+//	    resp, err := client.Do(req)
+//	    if err != nil {
+//	        return Retryable(err)
+//	    }
+//	    defer resp.Body.Close()
+//	    if resp.StatusCode >= 500 {
+//	        return Retryable(fmt.Errorf("upstream returned %d", resp.StatusCode))
+//	    }
+//	    return nil
+//	})
+//
+// Parameters:
+//   - ctx: Context for cancelling the retry loop
+//   - attempts: The maximum number of times fn is called (values below 1 are treated as 1)
+//   - backoff: The backoff configuration used between attempts
+//   - fn: The function to call
+//
+// Returns:
+//   - error: The result of the final call to fn, or the context error
+func Retry(ctx context.Context, attempts int, backoff BackoffConfig, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		var retryable *RetryableError
+		if !errors.As(err, &retryable) {
+			return err
+		}
+
+		if attempt == attempts-1 {
+			break
+		}
+
+		timer := time.NewTimer(backoff.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return err
+}
+
+// Delay returns the backoff delay to wait after the given zero-based attempt.
+// The delay grows exponentially from Initial by Multiplier, is capped at Max,
+// and then has the configured Jitter fraction randomized.
+//
+// Parameters:
+//   - attempt: The zero-based index of the attempt that just failed
+//
+// Returns:
+//   - time.Duration: The delay to wait before the next attempt
+func (b BackoffConfig) Delay(attempt int) time.Duration {
+	initial := b.Initial
+	if initial <= 0 {
+		initial = DefaultBackoffInitial
+	}
+	maxDelay := b.Max
+	if maxDelay <= 0 {
+		maxDelay = DefaultBackoffMax
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = DefaultBackoffMultiplier
+	}
+
+	delay := float64(initial)
+	for i := 0; i < attempt && delay < float64(maxDelay); i++ {
+		delay *= multiplier
+	}
+	if delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+
+	if jitter := min(max(b.Jitter, 0), 1); jitter > 0 {
+		delay -= delay * jitter * rand.Float64()
+	}
+
+	return time.Duration(delay)
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fastBackoff keeps the retry tests quick.
+var fastBackoff = BackoffConfig{Initial: time.Millisecond, Max: 5 * time.Millisecond}
+
+func TestRetrySucceedsOnThirdAttempt(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), 5, fastBackoff, func() error {
+		calls++
+		if calls < 3 {
+			return Retryable(errors.New("temporarily unavailable"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Retry() error = %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryExhaustsAttempts(t *testing.T) {
+	errUnavailable := errors.New("temporarily unavailable")
+	calls := 0
+	err := Retry(context.Background(), 3, fastBackoff, func() error {
+		calls++
+		return Retryable(errUnavailable)
+	})
+	if !errors.Is(err, errUnavailable) {
+		t.Errorf("Retry() error = %v, want %v", err, errUnavailable)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryStopsOnPermanentError(t *testing.T) {
+	errInvalid := errors.New("invalid input")
+	calls := 0
+	err := Retry(context.Background(), 5, fastBackoff, func() error {
+		calls++
+		return errInvalid
+	})
+	if !errors.Is(err, errInvalid) || calls != 1 {
+		t.Errorf("Retry() = %v after %d calls, want %v after 1", err, calls, errInvalid)
+	}
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	err := Retry(ctx, 5, BackoffConfig{Initial: time.Minute}, func() error {
+		calls++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return Retryable(errors.New("temporarily unavailable"))
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Retry() error = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Retry() returned after %v, want it to stop waiting on cancellation", elapsed)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := BackoffConfig{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: 100 * time.Millisecond},
+		{attempt: 1, want: 200 * time.Millisecond},
+		{attempt: 3, want: 800 * time.Millisecond},
+		{attempt: 4, want: time.Second},
+		{attempt: 50, want: time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoff.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+
+	backoff.Jitter = 0.5
+	for range 20 {
+		if got := backoff.Delay(1); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("Delay(1) with jitter = %v, want within [100ms, 200ms]", got)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"time"
+
+	"github.com/arbenlabs/anvil/internal/ttlmap"
+)
+
+// SessionStore records revoked token IDs (jti) and revoked users so their tokens can be
+// rejected during verification. Implementations must be safe for concurrent use. A
+// revocation only needs to be kept until the revoked tokens would have expired on
+// their own, after which it can be evicted.
+type SessionStore interface {
+	// Revoke marks the token ID as revoked until the given time.
+	Revoke(jti string, until time.Time)
+
+	// IsRevoked reports whether the token ID is currently revoked.
+	IsRevoked(jti string) bool
+
+	// RevokeUser revokes every token issued to the user up to now, keeping the
+	// revocation until the given time.
+	RevokeUser(userID string, until time.Time)
+
+	// UserRevokedAt returns when the user's tokens were last revoked, if the
+	// revocation has not expired.
+	UserRevokedAt(userID string) (time.Time, bool)
+}
+
+// MemorySessionStore is an in-memory SessionStore with TTL eviction.
+// Revocations only live in the memory of the process, so services running several
+// instances need a shared implementation (e.g., backed by Redis) so a logout on one
+// instance is honored by all of them.
+type MemorySessionStore struct {
+	revoked *ttlmap.Map[struct{}]
+	users   *ttlmap.Map[time.Time]
+}
+
+// NewMemorySessionStore creates a new, empty in-memory session store.
+//
+// Example usage:
+//
+//	sessions := NewMemorySessionStore()
+//	jwtService := NewJsonWebToken("myapp.com", key).WithSessionStore(sessions)
+//
+// Returns:
+//   - *MemorySessionStore: A new in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		revoked: ttlmap.New[struct{}](),
+		users:   ttlmap.New[time.Time](),
+	}
+}
+
+// Revoke marks the token ID as revoked until the given time.
+// Revoking an ID that is already revoked extends the revocation if until is later.
+// Expired revocations are swept at most once per minute during calls to Revoke.
+//
+// Parameters:
+//   - jti: The token ID to revoke
+//   - until: The time after which the revocation is evicted
+func (s *MemorySessionStore) Revoke(jti string, until time.Time) {
+	s.revoked.Update(jti, func(_ struct{}, expires time.Time, ok bool) (struct{}, time.Time) {
+		if ok && expires.After(until) {
+			return struct{}{}, expires
+		}
+		return struct{}{}, until
+	})
+}
+
+// IsRevoked reports whether the token ID is currently revoked.
+// An expired revocation is evicted when it is looked up.
+//
+// Parameters:
+//   - jti: The token ID to check
+//
+// Returns:
+//   - bool: true if the token ID is revoked and the revocation has not expired, false otherwise
+func (s *MemorySessionStore) IsRevoked(jti string) bool {
+	_, ok := s.revoked.Get(jti)
+	return ok
+}
+
+// RevokeUser revokes every token issued to the user up to now.
+// The revocation is kept until the given time, or longer if an earlier revocation of
+// the user already lasts longer. Tokens issued after the call are not affected.
+//
+// Example usage:
+//
+//	// "Log out everywhere": reject the user's tokens issued so far.
+//	sessions.RevokeUser(claims.ID, time.Now().Add(15*time.Minute))
+//
+// Parameters:
+//   - userID: The ID of the user whose tokens are revoked
+//   - until: The time after which the revocation is evicted
+func (s *MemorySessionStore) RevokeUser(userID string, until time.Time) {
+	now := time.Now()
+	s.users.Update(userID, func(_ time.Time, expires time.Time, ok bool) (time.Time, time.Time) {
+		if ok && expires.After(until) {
+			return now, expires
+		}
+		return now, until
+	})
+}
+
+// UserRevokedAt returns when the user's tokens were last revoked with RevokeUser.
+//
+// Parameters:
+//   - userID: The ID of the user to check
+//
+// Returns:
+//   - time.Time: The time of the latest revocation
+//   - bool: true if the user has a revocation that has not expired, false otherwise
+func (s *MemorySessionStore) UserRevokedAt(userID string) (time.Time, bool) {
+	return s.users.Get(userID)
+}
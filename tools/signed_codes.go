@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrSignedCodeExpired is returned by VerifySignedCode for an authentic code past its expiry,
+	// so flows can offer to send a new code instead of reporting an invalid one.
+	ErrSignedCodeExpired = errors.New("the code has expired")
+
+	// errSignedCodeInvalid is returned when a code is malformed or its signature does not match.
+	errSignedCodeInvalid = errors.New("the code is invalid")
+)
+
+// GenerateSignedCode creates a compact, HMAC-signed code carrying a payload and an expiry.
+// Email confirmation, invitation and password reset flows need codes that tie an
+// action to an identity without storing them server-side. The payload and the expiry
+// (Unix seconds) are encoded as "exp.<sorted url-encoded payload>", and the code is
+// that string in unpadded base64url followed by "." and its base64url HMAC-SHA256,
+// so it can be placed in a link as is.
+//
+// The payload is signed, not encrypted: anyone holding the code can read it. Include
+// a purpose (e.g., "purpose": "invite") so a code issued for one flow cannot be used
+// in another with the same key. Codes remain valid until they expire; flows that must
+// be single-use should also record consumed codes.
+//
+// Example usage:
+//
+//	code, err := GenerateSignedCode(map[string]string{
+//	    "purpose": "invite",
+//	    "email":   "new.user@example.com",
+//	    "org_id":  "org_123",
+//	}, key, 72*time.Hour)
+//	if err != nil {
+//	    return err
+//	}
+//	link := "https://app.example.com/invite?code=" + code
+//
+// Parameters:
+//   - payload: The values to carry in the code
+//   - key: The secret key used to sign the code
+//   - ttl: How long the code remains valid
+//
+// Returns:
+//   - string: The signed code
+//   - error: An error if the key is empty or ttl is not positive
+func GenerateSignedCode(payload map[string]string, key []byte, ttl time.Duration) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("signing key must not be empty")
+	}
+	if ttl <= 0 {
+		return "", errors.New("ttl must be positive")
+	}
+
+	values := make(url.Values, len(payload))
+	for k, v := range payload {
+		values.Set(k, v)
+	}
+	body := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10) + "." + values.Encode()
+
+	return base64.RawURLEncoding.EncodeToString([]byte(body)) + "." +
+		base64.RawURLEncoding.EncodeToString(computeCodeSignature(body, key)), nil
+}
+
+// VerifySignedCode validates a code produced by GenerateSignedCode and returns its payload.
+// The signature is compared in constant time before the expiry is checked, so
+// tampered codes are always reported as invalid.
+//
+// Example usage:
+//
+//	payload, err := VerifySignedCode(r.URL.Query().Get("code"), key)
+//	switch {
+//	case errors.Is(err, ErrSignedCodeExpired):
+//	    // offer to send a new invitation
+//	case err != nil || payload["purpose"] != "invite":
+//	    // reject the code
+//	}
+//
+// Parameters:
+//   - code: The code to verify
+//   - key: The secret key used to sign the code
+//
+// Returns:
+//   - map[string]string: The payload carried by the code
+//   - error: ErrSignedCodeExpired for an expired code, or an error if the code is malformed or tampered with
+func VerifySignedCode(code string, key []byte) (map[string]string, error) {
+	encodedBody, encodedSignature, ok := strings.Cut(code, ".")
+	if !ok {
+		return nil, errSignedCodeInvalid
+	}
+	body, err := base64.RawURLEncoding.DecodeString(encodedBody)
+	if err != nil {
+		return nil, errSignedCodeInvalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, errSignedCodeInvalid
+	}
+	if !hmac.Equal(signature, computeCodeSignature(string(body), key)) {
+		return nil, errSignedCodeInvalid
+	}
+
+	rawExpiry, rawPayload, ok := strings.Cut(string(body), ".")
+	if !ok {
+		return nil, errSignedCodeInvalid
+	}
+	expiry, err := strconv.ParseInt(rawExpiry, 10, 64)
+	if err != nil {
+		return nil, errSignedCodeInvalid
+	}
+	if time.Now().After(time.Unix(expiry, 0)) {
+		return nil, ErrSignedCodeExpired
+	}
+
+	values, err := url.ParseQuery(rawPayload)
+	if err != nil {
+		return nil, errSignedCodeInvalid
+	}
+	payload := make(map[string]string, len(values))
+	for k := range values {
+		payload[k] = values.Get(k)
+	}
+	return payload, nil
+}
+
+// computeCodeSignature calculates the HMAC-SHA256 signature of an encoded code body.
+//
+// Parameters:
+//   - body: The encoded expiry and payload
+//   - key: The secret key used to compute the HMAC
+//
+// Returns:
+//   - []byte: The raw HMAC-SHA256 signature
+func computeCodeSignature(body string, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedCodeRoundTrip(t *testing.T) {
+	payload := map[string]string{"email": "user@example.com", "action": "confirm", "note": "a=b&c.d"}
+	code, err := GenerateSignedCode(payload, testKey, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateSignedCode() error = %v", err)
+	}
+	if strings.ContainsAny(code, "+/=") {
+		t.Errorf("code = %q, want URL-safe characters only", code)
+	}
+
+	got, err := VerifySignedCode(code, testKey)
+	if err != nil {
+		t.Fatalf("VerifySignedCode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, payload) {
+		t.Errorf("VerifySignedCode() = %v, want %v", got, payload)
+	}
+}
+
+func TestSignedCodeExpired(t *testing.T) {
+	body := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10) + ".email=user%40example.com"
+	code := base64.RawURLEncoding.EncodeToString([]byte(body)) + "." +
+		base64.RawURLEncoding.EncodeToString(computeCodeSignature(body, testKey))
+
+	if _, err := VerifySignedCode(code, testKey); !errors.Is(err, ErrSignedCodeExpired) {
+		t.Errorf("VerifySignedCode(expired) error = %v, want %v", err, ErrSignedCodeExpired)
+	}
+}
+
+func TestSignedCodeRejectsTampering(t *testing.T) {
+	code, _ := GenerateSignedCode(map[string]string{"email": "user@example.com"}, testKey, time.Hour)
+	encodedBody, signature, _ := strings.Cut(code, ".")
+	body, _ := base64.RawURLEncoding.DecodeString(encodedBody)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(body), "user", "admin", 1))) + "." + signature
+
+	tests := []struct {
+		name string
+		code string
+		key  []byte
+	}{
+		{name: "payload", code: forged, key: testKey},
+		{name: "signature", code: encodedBody + "." + base64.RawURLEncoding.EncodeToString([]byte("forged")), key: testKey},
+		{name: "key", code: code, key: []byte("another-key")},
+		{name: "malformed", code: "not-a-code", key: testKey},
+		{name: "bad encoding", code: "!!!." + signature, key: testKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := VerifySignedCode(tt.code, tt.key); !errors.Is(err, errSignedCodeInvalid) || got != nil {
+				t.Errorf("VerifySignedCode() = %v, %v; want nil, %v", got, err, errSignedCodeInvalid)
+			}
+		})
+	}
+}
+
+func TestGenerateSignedCodeErrors(t *testing.T) {
+	if _, err := GenerateSignedCode(nil, nil, time.Hour); err == nil {
+		t.Error("GenerateSignedCode() without a key error = nil, want an error")
+	}
+	if _, err := GenerateSignedCode(nil, testKey, 0); err == nil {
+		t.Error("GenerateSignedCode() with a zero ttl error = nil, want an error")
+	}
+}
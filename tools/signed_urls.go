@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// signedURLExpiryParam is the query parameter holding the Unix expiry time of a signed URL.
+	signedURLExpiryParam = "exp"
+
+	// signedURLSignatureParam is the query parameter holding the hex-encoded HMAC signature.
+	signedURLSignatureParam = "sig"
+)
+
+var (
+	// errSignedURLMalformed is returned when a signed URL is missing its expiry or signature,
+	// or when either value cannot be decoded.
+	errSignedURLMalformed = errors.New("the signed url is missing a valid expiry or signature")
+
+	// errSignedURLExpired is returned when a signed URL is verified after its expiry time.
+	errSignedURLExpired = errors.New("the signed url has expired")
+)
+
+// SignURL builds an HMAC-signed URL that expires at the given time.
+// This function is useful for file-download and share links that must be
+// handed to clients without requiring further authentication.
+//
+// The function merges the provided params into the query of baseURL, appends an
+// "exp" parameter containing the Unix expiry time, and then appends a "sig"
+// parameter containing the hex-encoded HMAC-SHA256 of the URL path and the
+// canonical (sorted) query string. The host is deliberately excluded from the
+// signature so links keep verifying behind proxies and load balancers that
+// rewrite it.
+//
+// Example usage:
+//
+//	link, err := SignURL("https://cdn.example.com/files/report.pdf",
+//	    map[string]string{"user": "123"}, key, time.Now().Add(time.Hour))
+//	if err != nil {
+//	    // handle error
+//	}
+//	// Result: https://cdn.example.com/files/report.pdf?exp=...&sig=...&user=123
+//
+// Parameters:
+//   - baseURL: The URL to sign (may already contain query parameters)
+//   - params: Additional query parameters to include in the signature
+//   - key: The secret key used to compute the HMAC signature
+//   - expiry: The time after which the URL is no longer valid
+//
+// Returns:
+//   - string: The signed URL including the "exp" and "sig" query parameters
+//   - error: Any error that occurred while parsing the base URL
+func SignURL(baseURL string, params map[string]string, key []byte, expiry time.Time) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	q.Del(signedURLSignatureParam)
+	q.Set(signedURLExpiryParam, strconv.FormatInt(expiry.Unix(), 10))
+
+	signature := computeURLSignature(u.EscapedPath(), q, key)
+	q.Set(signedURLSignatureParam, hex.EncodeToString(signature))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// VerifySignedURL validates a URL previously produced by SignURL.
+// This function recomputes the HMAC signature over the URL path and the
+// canonical query string (excluding "sig") and compares it to the provided
+// signature using constant-time comparison to prevent timing attacks.
+//
+// The function returns false with an error when the URL is malformed, is missing
+// its expiry or signature, or has expired. It returns false without an error when
+// the signature does not match, which indicates the URL was tampered with or
+// signed with a different key.
+//
+// Example usage:
+//
+//	valid, err := VerifySignedURL(r.URL.String(), key)
+//	if err != nil || !valid {
+//	    // reject the request
+//	}
+//
+// Parameters:
+//   - u: The signed URL to verify (absolute or path-only)
+//   - key: The secret key used to sign the URL
+//
+// Returns:
+//   - bool: true if the signature is valid and the URL has not expired, false otherwise
+//   - error: Any error that occurred during verification (malformed or expired URL)
+func VerifySignedURL(u string, key []byte) (bool, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false, err
+	}
+
+	q := parsed.Query()
+	signature, err := hex.DecodeString(q.Get(signedURLSignatureParam))
+	if err != nil || len(signature) == 0 {
+		return false, errSignedURLMalformed
+	}
+
+	expiry, err := strconv.ParseInt(q.Get(signedURLExpiryParam), 10, 64)
+	if err != nil {
+		return false, errSignedURLMalformed
+	}
+
+	q.Del(signedURLSignatureParam)
+	expected := computeURLSignature(parsed.EscapedPath(), q, key)
+	if !hmac.Equal(signature, expected) {
+		return false, nil
+	}
+
+	if time.Now().After(time.Unix(expiry, 0)) {
+		return false, errSignedURLExpired
+	}
+
+	return true, nil
+}
+
+// computeURLSignature calculates the HMAC-SHA256 signature for a URL path and query.
+// The query is encoded with url.Values.Encode, which sorts keys, so the same set of
+// parameters always produces the same signature regardless of their original order.
+//
+// Parameters:
+//   - path: The escaped URL path
+//   - q: The query parameters to sign (must not contain the signature parameter)
+//   - key: The secret key used to compute the HMAC
+//
+// Returns:
+//   - []byte: The raw HMAC-SHA256 signature
+func computeURLSignature(path string, q url.Values, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + "?" + q.Encode()))
+	return mac.Sum(nil)
+}
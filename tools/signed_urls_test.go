@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedURLRoundTrip(t *testing.T) {
+	link, err := SignURL("https://cdn.example.com/files/report.pdf?v=2", map[string]string{"user": "123"}, testKey, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SignURL() error = %v", err)
+	}
+
+	valid, err := VerifySignedURL(link, testKey)
+	if err != nil || !valid {
+		t.Fatalf("VerifySignedURL() = %v, %v; want true, nil", valid, err)
+	}
+
+	// Only the path and query are signed, so the link verifies behind a proxy.
+	parsed, _ := url.Parse(link)
+	if valid, err := VerifySignedURL(parsed.RequestURI(), testKey); err != nil || !valid {
+		t.Errorf("VerifySignedURL(path only) = %v, %v; want true, nil", valid, err)
+	}
+}
+
+func TestSignedURLRejectsTampering(t *testing.T) {
+	link, _ := SignURL("https://cdn.example.com/files/report.pdf", map[string]string{"user": "123"}, testKey, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name string
+		url  string
+		key  []byte
+	}{
+		{name: "param", url: strings.Replace(link, "user=123", "user=456", 1), key: testKey},
+		{name: "path", url: strings.Replace(link, "report.pdf", "secret.pdf", 1), key: testKey},
+		{name: "key", url: link, key: []byte("another-key")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if valid, err := VerifySignedURL(tt.url, tt.key); valid || err != nil {
+				t.Errorf("VerifySignedURL() = %v, %v; want false, nil", valid, err)
+			}
+		})
+	}
+}
+
+func TestSignedURLErrors(t *testing.T) {
+	expired, _ := SignURL("https://cdn.example.com/files/report.pdf", nil, testKey, time.Now().Add(-time.Minute))
+	if _, err := VerifySignedURL(expired, testKey); !errors.Is(err, errSignedURLExpired) {
+		t.Errorf("VerifySignedURL(expired) error = %v, want %v", err, errSignedURLExpired)
+	}
+
+	for _, malformed := range []string{
+		"https://cdn.example.com/files/report.pdf",
+		"https://cdn.example.com/files/report.pdf?exp=1&sig=zz",
+		"https://cdn.example.com/files/report.pdf?exp=soon&sig=00",
+	} {
+		if _, err := VerifySignedURL(malformed, testKey); !errors.Is(err, errSignedURLMalformed) {
+			t.Errorf("VerifySignedURL(%q) error = %v, want %v", malformed, err, errSignedURLMalformed)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package tools
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPPeriod is the time step, in seconds, used by the TOTP helpers (RFC 6238 default).
+	TOTPPeriod = 30
+
+	// TOTPDigits is the number of digits in codes produced by the TOTP helpers.
+	TOTPDigits = 6
+
+	// totpSecretSize is the number of random bytes in a generated TOTP secret (160 bits, as recommended by RFC 4226).
+	totpSecretSize = 20
+)
+
+var (
+	// errInvalidTOTPSecret is returned when a TOTP secret is not valid base32.
+	errInvalidTOTPSecret = errors.New("the totp secret is not valid base32")
+
+	// totpEncoding is the unpadded base32 encoding used for TOTP secrets, as expected by authenticator apps.
+	totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GenerateTOTPSecret creates a new random secret for time-based one-time passwords.
+// The secret contains 160 bits from crypto/rand and is encoded as unpadded base32,
+// the format expected by authenticator apps such as Google Authenticator and 1Password.
+// Store it alongside the user and share it once through TOTPProvisioningURI.
+//
+// Example usage:
+//
+//	secret, err := GenerateTOTPSecret()
+//	if err != nil {
+//	    // handle error
+//	}
+//	// Result: "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+//
+// Returns:
+//   - string: The base32-encoded secret
+//   - error: Any error that occurred while reading random bytes
+func GenerateTOTPSecret() (string, error) {
+	b, err := generateRandomBytes(totpSecretSize)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPCode computes the RFC 6238 one-time password for a secret at the given time.
+// The code uses HMAC-SHA1, a 30-second time step and 6 digits, matching the defaults
+// of common authenticator apps.
+//
+// Example usage:
+//
+//	code, err := TOTPCode(secret, time.Now())
+//	if err != nil {
+//	    // handle error
+//	}
+//	// Result: "287082"
+//
+// Parameters:
+//   - secret: The base32-encoded secret (case-insensitive, padding and spaces optional)
+//   - t: The time for which to compute the code
+//
+// Returns:
+//   - string: The zero-padded one-time password
+//   - error: An error if the secret is not valid base32
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return totpCodeAt(key, uint64(t.Unix()/TOTPPeriod)), nil
+}
+
+// VerifyTOTP checks a one-time password against a secret at the current time.
+// To tolerate clock skew between the server and the user's device, codes from up to
+// window time steps before and after the current one are also accepted. A window of
+// 1 (accepting the previous, current and next code) is a common choice. Codes are
+// compared in constant time.
+//
+// Example usage:
+//
+//	ok, err := VerifyTOTP(user.TOTPSecret, r.FormValue("code"), 1)
+//	if err != nil {
+//	    // handle error
+//	}
+//	if !ok {
+//	    // reject the login
+//	}
+//
+// Parameters:
+//   - secret: The base32-encoded secret
+//   - code: The code supplied by the user
+//   - window: The number of time steps of skew to accept on either side (negative values are treated as 0)
+//
+// Returns:
+//   - bool: true if the code is valid within the window, false otherwise
+//   - error: An error if the secret is not valid base32
+func VerifyTOTP(secret, code string, window int) (bool, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return false, err
+	}
+	if window < 0 {
+		window = 0
+	}
+
+	code = strings.TrimSpace(code)
+	counter := time.Now().Unix() / TOTPPeriod
+
+	valid := false
+	for i := -window; i <= window; i++ {
+		c := counter + int64(i)
+		if c < 0 {
+			continue
+		}
+		if SecureCompare(totpCodeAt(key, uint64(c)), code) {
+			valid = true
+		}
+	}
+	return valid, nil
+}
+
+// TOTPProvisioningURI builds an otpauth:// URI for enrolling a secret in an authenticator app.
+// The URI is usually rendered as a QR code; its label is "issuer:account" and it
+// carries the secret, issuer, algorithm, digits and period parameters.
+//
+// Example usage:
+//
+//	uri := TOTPProvisioningURI(secret, "Acme", "jane@example.com")
+//	// Result: "otpauth://totp/Acme:jane@example.com?algorithm=SHA1&digits=6&issuer=Acme&period=30&secret=..."
+//
+// Parameters:
+//   - secret: The base32-encoded secret
+//   - issuer: The service name shown in the authenticator app
+//   - account: The account name (typically the user's email)
+//
+// Returns:
+//   - string: The otpauth:// provisioning URI
+func TOTPProvisioningURI(secret, issuer, account string) string {
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+
+	q := url.Values{}
+	q.Set("secret", strings.ToUpper(strings.TrimRight(secret, "=")))
+	if issuer != "" {
+		q.Set("issuer", issuer)
+	}
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", strconv.Itoa(TOTPDigits))
+	q.Set("period", strconv.Itoa(TOTPPeriod))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// decodeTOTPSecret decodes a base32 secret, ignoring case, spaces and padding.
+//
+// Parameters:
+//   - secret: The base32-encoded secret
+//
+// Returns:
+//   - []byte: The raw secret key
+//   - error: An error if the secret is empty or not valid base32
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	normalized := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	normalized = strings.TrimRight(normalized, "=")
+
+	key, err := totpEncoding.DecodeString(normalized)
+	if err != nil || len(key) == 0 {
+		return nil, errInvalidTOTPSecret
+	}
+	return key, nil
+}
+
+// totpCodeAt computes the HOTP value (RFC 4226) for a key and counter.
+//
+// Parameters:
+//   - key: The raw secret key
+//   - counter: The time step counter
+//
+// Returns:
+//   - string: The zero-padded one-time password
+func totpCodeAt(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulus := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulus)
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key from RFC 6238, appendix B ("12345678901234567890"), in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// RFC 6238 lists 8-digit codes; the 6-digit codes are their last six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil || got != tt.want {
+			t.Errorf("TOTPCode(%d) = %q, %v; want %q, nil", tt.unix, got, err, tt.want)
+		}
+	}
+}
+
+func TestTOTPCodeNormalizesSecret(t *testing.T) {
+	got, err := TOTPCode("gezd gnbv gy3t qojq gezd gnbv gy3t qojq====", time.Unix(59, 0))
+	if err != nil || got != "287082" {
+		t.Errorf("TOTPCode(formatted secret) = %q, %v; want 287082, nil", got, err)
+	}
+
+	for _, invalid := range []string{"", "not base32!"} {
+		if _, err := TOTPCode(invalid, time.Now()); !errors.Is(err, errInvalidTOTPSecret) {
+			t.Errorf("TOTPCode(%q) error = %v, want %v", invalid, err, errInvalidTOTPSecret)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	now := time.Now()
+	current, _ := TOTPCode(secret, now)
+	previous, _ := TOTPCode(secret, now.Add(-TOTPPeriod*time.Second))
+	stale, _ := TOTPCode(secret, now.Add(-5*TOTPPeriod*time.Second))
+
+	tests := []struct {
+		name   string
+		code   string
+		window int
+		want   bool
+	}{
+		{name: "current", code: current, window: 1, want: true},
+		{name: "surrounding spaces", code: " " + current + " ", window: 1, want: true},
+		{name: "previous within window", code: previous, window: 1, want: true},
+		{name: "stale", code: stale, window: 1, want: false},
+		{name: "wrong", code: "000000", window: 1, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := VerifyTOTP(secret, tt.code, tt.window); err != nil || got != tt.want {
+				t.Errorf("VerifyTOTP() = %v, %v; want %v, nil", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	u, err := url.Parse(TOTPProvisioningURI("jbswy3dpehpk3pxp", "Acme", "jane@example.com"))
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Acme:jane@example.com" {
+		t.Errorf("URI = %s, want otpauth://totp/Acme:jane@example.com", u)
+	}
+	q := u.Query()
+	want := map[string]string{"secret": "JBSWY3DPEHPK3PXP", "issuer": "Acme", "algorithm": "SHA1", "digits": "6", "period": "30"}
+	for name, value := range want {
+		if q.Get(name) != value {
+			t.Errorf("%s = %q, want %q", name, q.Get(name), value)
+		}
+	}
+}
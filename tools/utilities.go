@@ -1,6 +1,12 @@
 package tools
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -53,14 +59,218 @@ func GenerateUUID() string {
 	return uuid.NewString()
 }
 
-// GetCurrentDate returns the current date at midnight UTC.
+// GenerateDeterministicUUID creates a name-based version 5 UUID.
+// Unlike GenerateUUID, the result is derived from the namespace and name using
+// SHA-1 (RFC 4122), so the same inputs always yield the same UUID. This is useful
+// for data pipelines that need stable identifiers derived from a natural key, such
+// as an email address or an external system's ID, without storing a mapping.
+//
+// Use one of the predefined namespaces (uuid.NameSpaceDNS, uuid.NameSpaceURL, ...)
+// or a fixed application-specific UUID as the namespace.
+//
+// Example usage:
+//
+//	id := GenerateDeterministicUUID(uuid.NameSpaceURL, "https://example.com/users/42")
+//	// Result: the same UUID every time for this URL
+//
+// Parameters:
+//   - namespace: The namespace UUID that scopes the name
+//   - name: The natural key to derive the UUID from
+//
+// Returns:
+//   - string: The version 5 UUID string
+func GenerateDeterministicUUID(namespace uuid.UUID, name string) string {
+	return uuid.NewSHA1(namespace, []byte(name)).String()
+}
+
+const (
+	// shortIDAlphabet is the URL-safe alphabet of GenerateShortID. Its 64 characters
+	// map exactly onto 6 random bits, so every character is equally likely.
+	shortIDAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-"
+
+	// MinShortIDLength is the shortest length accepted by GenerateShortID.
+	MinShortIDLength = 6
+
+	// MaxShortIDLength is the longest length accepted by GenerateShortID.
+	MaxShortIDLength = 128
+)
+
+// GenerateShortID creates a short, URL-safe random identifier (nanoid-style).
+// UUIDs are long and awkward in URLs; short IDs suit public slugs, invite codes and
+// share links. Each character is drawn from crypto/rand out of the 64-character
+// alphabet A-Z, a-z, 0-9, "_" and "-", so the ID carries 6 bits of randomness per
+// character and can be used in paths and query strings without escaping.
+//
+// The probability of any collision among k IDs of length n is about k² / 2^(6n+1).
+// Pick the length from the number of IDs that will ever exist for the same purpose
+// (each figure is roughly where the chance of a collision reaches 1%):
+//
+//	length | random bits | IDs before a 1% collision chance
+//	-------+-------------+---------------------------------
+//	     8 |          48 | ~2.4 million
+//	    10 |          60 | ~150 million
+//	    12 |          72 | ~10 billion
+//	    16 |          96 | ~40 trillion
+//	    21 |         126 | ~1.3 quintillion (comparable to a UUID)
+//
+// Short IDs are unguessable only at lengths that resist brute force; use 21 or more
+// characters, or GenerateSecureToken, for IDs that grant access on their own.
+//
+// Example usage:
+//
+//	id, err := GenerateShortID(12)
+//	if err != nil {
+//	    return err
+//	}
+//	// Result: "V1StGXR8_Z5j"
+//
+// Parameters:
+//   - length: The number of characters (between MinShortIDLength and MaxShortIDLength)
+//
+// Returns:
+//   - string: The random identifier
+//   - error: An error if length is out of bounds or random generation fails
+func GenerateShortID(length int) (string, error) {
+	if length < MinShortIDLength || length > MaxShortIDLength {
+		return "", fmt.Errorf("short id length must be between %d and %d, got %d", MinShortIDLength, MaxShortIDLength, length)
+	}
+
+	b, err := generateRandomBytes(uint32(length))
+	if err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = shortIDAlphabet[b[i]&63]
+	}
+	return string(b), nil
+}
+
+// IsValidUUID reports whether the input is a well-formed UUID.
+// This function accepts the standard hyphenated form as well as the other
+// encodings understood by uuid.Parse (braced, URN-prefixed, or unhyphenated).
+// It is useful for validating identifiers received from clients, such as
+// path parameters or tenant headers, before using them in queries.
+//
+// Example usage:
+//
+//	IsValidUUID("550e8400-e29b-41d4-a716-446655440000") // Returns: true
+//	IsValidUUID("not-a-uuid")                           // Returns: false
+//
+// Parameters:
+//   - input: The string to validate
+//
+// Returns:
+//   - bool: true if the input is a valid UUID, false otherwise
+func IsValidUUID(input string) bool {
+	_, err := uuid.Parse(input)
+	return err == nil
+}
+
+// DefaultDateLayoutValue is the initial date layout used by FormatDate.
+const DefaultDateLayoutValue = time.DateOnly
+
+var (
+	// dateSettingsMu guards defaultLocation and defaultDateLayout.
+	dateSettingsMu sync.RWMutex
+
+	// defaultLocation is the operating timezone used by the date helpers.
+	defaultLocation = time.UTC
+
+	// defaultDateLayout is the layout used by FormatDate.
+	defaultDateLayout = DefaultDateLayoutValue
+)
+
+// SetDefaultLocation sets the operating timezone used by the date helpers.
+// This gives an application a single source of truth for its timezone instead of
+// hardcoding UTC everywhere. It is safe to call concurrently with the date helpers,
+// but is typically called once at startup.
+//
+// Example usage:
+//
+//	loc, err := time.LoadLocation("America/New_York")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	SetDefaultLocation(loc)
+//
+// Parameters:
+//   - loc: The timezone to use (nil resets it to UTC)
+func SetDefaultLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	dateSettingsMu.Lock()
+	defer dateSettingsMu.Unlock()
+	defaultLocation = loc
+}
+
+// DefaultLocation returns the operating timezone used by the date helpers (UTC unless changed).
+//
+// Returns:
+//   - *time.Location: The current default timezone
+func DefaultLocation() *time.Location {
+	dateSettingsMu.RLock()
+	defer dateSettingsMu.RUnlock()
+	return defaultLocation
+}
+
+// SetDefaultDateLayout sets the layout used by FormatDate.
+// The layout uses Go's reference time syntax (e.g., "02/01/2006" or time.RFC1123).
+//
+// Parameters:
+//   - layout: The layout to use (empty resets it to DefaultDateLayoutValue)
+func SetDefaultDateLayout(layout string) {
+	if layout == "" {
+		layout = DefaultDateLayoutValue
+	}
+	dateSettingsMu.Lock()
+	defer dateSettingsMu.Unlock()
+	defaultDateLayout = layout
+}
+
+// DefaultDateLayout returns the layout used by FormatDate (time.DateOnly unless changed).
+//
+// Returns:
+//   - string: The current default date layout
+func DefaultDateLayout() string {
+	dateSettingsMu.RLock()
+	defer dateSettingsMu.RUnlock()
+	return defaultDateLayout
+}
+
+// FormatDate formats a time using the default timezone and date layout.
+// The time is first converted to DefaultLocation and then formatted with
+// DefaultDateLayout, so every date rendered by an application looks the same.
+//
+// Example usage:
+//
+//	SetDefaultDateLayout("02 Jan 2006")
+//	label := FormatDate(order.CreatedAt)
+//	// Result: "15 Jan 2024"
+//
+// Parameters:
+//   - t: The time to format
+//
+// Returns:
+//   - string: The formatted date
+func FormatDate(t time.Time) string {
+	dateSettingsMu.RLock()
+	loc, layout := defaultLocation, defaultDateLayout
+	dateSettingsMu.RUnlock()
+
+	return t.In(loc).Format(layout)
+}
+
+// GetCurrentDate returns the current date at midnight in the default timezone.
 // This function returns a time.Time value representing the current date
-// with the time set to 00:00:00 UTC. This is useful for date-based
-// operations where you need to work with dates without time components,
-// such as date ranges, daily statistics, or date-based filtering.
+// with the time set to 00:00:00 in DefaultLocation (UTC unless changed with
+// SetDefaultLocation). This is useful for date-based operations where you need
+// to work with dates without time components, such as date ranges, daily
+// statistics, or date-based filtering.
 //
-// The function extracts the year, month, and day from the current time
-// and creates a new time.Time value with those components and zero time.
+// The function extracts the year, month, and day from the current time in the
+// default timezone and creates a new time.Time value with those components and
+// zero time.
 //
 // Example usage:
 //
@@ -68,14 +278,15 @@ func GenerateUUID() string {
 //	// Result: 2024-01-15 00:00:00 +0000 UTC
 //
 // Returns:
-//   - time.Time: The current date at midnight UTC
+//   - time.Time: The current date at midnight in the default timezone
 func GetCurrentDate() time.Time {
-	currentTime := time.Now()
+	loc := DefaultLocation()
+	currentTime := time.Now().In(loc)
 	yr := currentTime.Year()
 	mo := currentTime.Month()
 	dy := currentTime.Day()
 
-	date := time.Date(yr, mo, dy, 0, 0, 0, 0, time.UTC)
+	date := time.Date(yr, mo, dy, 0, 0, 0, 0, loc)
 	return date
 }
 
@@ -190,6 +401,96 @@ func SafeTime(data map[string]interface{}, key string) time.Time {
 	return time.Time{} // Return the zero value of time.Time if missing or invalid
 }
 
+// unixMillisThreshold is the magnitude above which a numeric timestamp is treated as
+// Unix milliseconds rather than seconds. 1e11 seconds is in the year 5138, while 1e11
+// milliseconds is in 1973, so real-world values are unambiguous.
+const unixMillisThreshold = 1e11
+
+// SafeTimeParse safely extracts and parses a time value from a map[string]interface{}.
+// Unlike SafeTime, which only accepts values that are already a time.Time, this
+// function also handles the representations JSON actually delivers: strings and
+// numbers. This is useful when decoding JSON into a map, where timestamps always
+// arrive as strings (RFC 3339, custom layouts) or numbers (Unix epoch).
+//
+// The function accepts:
+//   - time.Time values, returned as-is
+//   - Strings in RFC 3339 (with or without fractional seconds), followed by each of
+//     the provided layouts in order
+//   - Numbers (float64, json.Number, int, int64) and numeric strings holding a Unix
+//     timestamp in seconds or, when larger than 1e11, in milliseconds
+//
+// The function returns the zero value of time.Time if the key doesn't exist, the
+// value is nil, or the value cannot be parsed.
+//
+// Example usage:
+//
+//	data := map[string]interface{}{
+//	    "created_at": "2024-01-15T10:30:00Z",
+//	    "updated_at": float64(1705314600),
+//	    "deleted_at": "15/01/2024",
+//	}
+//	createdAt := SafeTimeParse(data, "created_at")               // RFC 3339
+//	updatedAt := SafeTimeParse(data, "updated_at")               // Unix seconds
+//	deletedAt := SafeTimeParse(data, "deleted_at", "02/01/2006") // Custom layout
+//
+// Parameters:
+//   - data: The map containing mixed data types
+//   - key: The key to look up in the map
+//   - layouts: Additional time layouts to try for string values, after RFC 3339
+//
+// Returns:
+//   - time.Time: The parsed time if found and valid, zero time otherwise
+func SafeTimeParse(data map[string]interface{}, key string, layouts ...string) time.Time {
+	value, ok := data[key]
+	if !ok || value == nil {
+		return time.Time{}
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case float64:
+		return unixTime(v)
+	case int:
+		return unixTime(float64(v))
+	case int64:
+		return unixTime(float64(v))
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return unixTime(f)
+		}
+	case string:
+		for _, layout := range append([]string{time.RFC3339Nano}, layouts...) {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return unixTime(f)
+		}
+	}
+
+	return time.Time{} // Return the zero value of time.Time if missing or invalid
+}
+
+// unixTime converts a numeric Unix timestamp in seconds or milliseconds to a time.Time.
+//
+// Parameters:
+//   - v: The Unix timestamp (seconds, or milliseconds when above unixMillisThreshold)
+//
+// Returns:
+//   - time.Time: The corresponding time in UTC, or zero time for non-finite input
+func unixTime(v float64) time.Time {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return time.Time{}
+	}
+	if math.Abs(v) > unixMillisThreshold {
+		return time.UnixMilli(int64(v)).UTC()
+	}
+	sec, frac := math.Modf(v)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
 // SafeBool safely extracts a boolean value from a map[string]interface{}.
 // This function provides type-safe access to boolean values in maps that
 // contain mixed types (interface{}). It handles cases where the key
@@ -231,3 +532,92 @@ func SafeBool(data map[string]interface{}, key string) bool {
 	}
 	return false
 }
+
+// SafePath safely extracts a nested value from a map[string]interface{} by dotted path.
+// This function walks a path such as "user.address.city" through nested maps, which
+// avoids repeated manual type assertions when working with JSON configs or webhook
+// payloads decoded into generic maps. Keys containing dots cannot be addressed.
+//
+// The function returns false if:
+//   - Any key along the path doesn't exist
+//   - An intermediate value is nil or not a map[string]interface{}
+//   - The path is empty
+//
+// Example usage:
+//
+//	data := map[string]interface{}{
+//	    "user": map[string]interface{}{
+//	        "address": map[string]interface{}{"city": "Berlin"},
+//	    },
+//	}
+//	city, ok := SafePath(data, "user.address.city") // Returns: "Berlin", true
+//	_, ok = SafePath(data, "user.phone.number")     // Returns: nil, false
+//
+// Parameters:
+//   - data: The map containing nested data
+//   - path: The dot-separated path of keys to follow
+//
+// Returns:
+//   - interface{}: The value at the path
+//   - bool: true if the path was found, false otherwise
+func SafePath(data map[string]interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return nil, false
+	}
+
+	var current interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// SafePathString safely extracts a nested string value by dotted path.
+// It returns an empty string if the path is missing or the value is not a string.
+//
+// Example usage:
+//
+//	city := SafePathString(payload, "user.address.city") // Returns: "Berlin"
+//
+// Parameters:
+//   - data: The map containing nested data
+//   - path: The dot-separated path of keys to follow
+//
+// Returns:
+//   - string: The string value if found and valid, empty string otherwise
+func SafePathString(data map[string]interface{}, path string) string {
+	if value, ok := SafePath(data, path); ok {
+		if strValue, ok := value.(string); ok {
+			return strValue
+		}
+	}
+	return ""
+}
+
+// SafePathBool safely extracts a nested boolean value by dotted path.
+// It returns false if the path is missing or the value is not a bool.
+//
+// Example usage:
+//
+//	verified := SafePathBool(payload, "user.email.verified") // Returns: true
+//
+// Parameters:
+//   - data: The map containing nested data
+//   - path: The dot-separated path of keys to follow
+//
+// Returns:
+//   - bool: The boolean value if found and valid, false otherwise
+func SafePathBool(data map[string]interface{}, path string) bool {
+	if value, ok := SafePath(data, path); ok {
+		if boolValue, ok := value.(bool); ok {
+			return boolValue
+		}
+	}
+	return false
+}
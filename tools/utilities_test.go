@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSafeTimeParse(t *testing.T) {
+	want := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	data := map[string]interface{}{
+		"time":         want,
+		"rfc3339":      "2024-01-15T10:30:00Z",
+		"offset":       "2024-01-15T12:30:00+02:00",
+		"fractional":   "2024-01-15T10:30:00.000Z",
+		"layout":       "15/01/2024 10:30",
+		"seconds":      float64(1705314600),
+		"millis":       float64(1705314600000),
+		"int":          1705314600,
+		"int64":        int64(1705314600),
+		"json number":  json.Number("1705314600"),
+		"seconds text": "1705314600",
+		"invalid":      "next tuesday",
+		"nan":          math.NaN(),
+		"bool":         true,
+		"nil":          nil,
+	}
+
+	tests := []struct {
+		key  string
+		want time.Time
+	}{
+		{key: "time", want: want},
+		{key: "rfc3339", want: want},
+		{key: "offset", want: want},
+		{key: "fractional", want: want},
+		{key: "layout", want: want},
+		{key: "seconds", want: want},
+		{key: "millis", want: want},
+		{key: "int", want: want},
+		{key: "int64", want: want},
+		{key: "json number", want: want},
+		{key: "seconds text", want: want},
+		{key: "invalid"},
+		{key: "nan"},
+		{key: "bool"},
+		{key: "nil"},
+		{key: "missing"},
+	}
+
+	for _, tt := range tests {
+		if got := SafeTimeParse(data, tt.key, "02/01/2006 15:04"); !got.Equal(tt.want) {
+			t.Errorf("SafeTimeParse(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSafeTime(t *testing.T) {
+	now := time.Now()
+	data := map[string]interface{}{"time": now, "text": "2024-01-15T10:30:00Z"}
+
+	if got := SafeTime(data, "time"); !got.Equal(now) {
+		t.Errorf("SafeTime(time) = %v, want %v", got, now)
+	}
+	if got := SafeTime(data, "text"); !got.IsZero() {
+		t.Errorf("SafeTime(text) = %v, want zero time", got)
+	}
+}
+
+// useDateSettings sets the default location and layout for the duration of the test.
+func useDateSettings(t *testing.T, loc *time.Location, layout string) {
+	t.Helper()
+	previousLoc, previousLayout := DefaultLocation(), DefaultDateLayout()
+	SetDefaultLocation(loc)
+	SetDefaultDateLayout(layout)
+	t.Cleanup(func() {
+		SetDefaultLocation(previousLoc)
+		SetDefaultDateLayout(previousLayout)
+	})
+}
+
+func TestDateHelpersUseDefaults(t *testing.T) {
+	kiritimati := time.FixedZone("LINT", 14*60*60)
+	useDateSettings(t, kiritimati, "02 Jan 2006")
+
+	noon := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	if got := FormatDate(noon); got != "16 Jan 2024" {
+		t.Errorf("FormatDate() = %q, want the date in the default location", got)
+	}
+
+	today := GetCurrentDate()
+	if today.Location() != kiritimati {
+		t.Errorf("GetCurrentDate() location = %v, want %v", today.Location(), kiritimati)
+	}
+	if y, m, d := time.Now().In(kiritimati).Date(); today != time.Date(y, m, d, 0, 0, 0, 0, kiritimati) {
+		t.Errorf("GetCurrentDate() = %v, want midnight of today in %v", today, kiritimati)
+	}
+}
+
+func TestDateSettingsReset(t *testing.T) {
+	useDateSettings(t, nil, "")
+
+	if DefaultLocation() != time.UTC {
+		t.Errorf("DefaultLocation() = %v, want UTC after a nil reset", DefaultLocation())
+	}
+	if DefaultDateLayout() != DefaultDateLayoutValue {
+		t.Errorf("DefaultDateLayout() = %q, want %q after an empty reset", DefaultDateLayout(), DefaultDateLayoutValue)
+	}
+}
+
+func TestGenerateDeterministicUUID(t *testing.T) {
+	// uuid5(NAMESPACE_DNS, "python.org") from the Python uuid documentation.
+	if got := GenerateDeterministicUUID(uuid.NameSpaceDNS, "python.org"); got != "886313e1-3b8a-5372-9b90-0c9aee199e5d" {
+		t.Errorf("GenerateDeterministicUUID(DNS, python.org) = %q, want the RFC 4122 version 5 UUID", got)
+	}
+
+	first := GenerateDeterministicUUID(uuid.NameSpaceURL, "https://example.com/users/42")
+	if again := GenerateDeterministicUUID(uuid.NameSpaceURL, "https://example.com/users/42"); again != first {
+		t.Errorf("GenerateDeterministicUUID() = %q then %q, want the same UUID", first, again)
+	}
+	if other := GenerateDeterministicUUID(uuid.NameSpaceURL, "https://example.com/users/43"); other == first {
+		t.Errorf("different names both yield %q", first)
+	}
+	if other := GenerateDeterministicUUID(uuid.NameSpaceDNS, "https://example.com/users/42"); other == first {
+		t.Errorf("different namespaces both yield %q", first)
+	}
+	if !IsValidUUID(first) {
+		t.Errorf("IsValidUUID(%q) = false, want true", first)
+	}
+}
+
+func TestSafePath(t *testing.T) {
+	data := map[string]interface{}{
+		"user": map[string]interface{}{
+			"address": map[string]interface{}{"city": "Berlin"},
+			"email":   map[string]interface{}{"verified": true},
+			"phone":   nil,
+			"tags":    []interface{}{"admin"},
+		},
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+		ok   bool
+	}{
+		{path: "user.address.city", want: "Berlin", ok: true},
+		{path: "user.email.verified", want: true, ok: true},
+		{path: "user.phone", want: nil, ok: true},
+		{path: "user.phone.number"},
+		{path: "user.tags.0"},
+		{path: "user.address.zip"},
+		{path: "account.id"},
+		{path: ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := SafePath(data, tt.path)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("SafePath(%q) = %v, %v; want %v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if got := SafePathString(data, "user.address.city"); got != "Berlin" {
+		t.Errorf("SafePathString(city) = %q, want Berlin", got)
+	}
+	if got := SafePathString(data, "user.email.verified"); got != "" {
+		t.Errorf("SafePathString(bool) = %q, want empty for a type mismatch", got)
+	}
+	if !SafePathBool(data, "user.email.verified") {
+		t.Error("SafePathBool(verified) = false, want true")
+	}
+	if SafePathBool(data, "user.address.city") || SafePathBool(data, "user.missing") {
+		t.Error("SafePathBool() = true for a type mismatch or missing path, want false")
+	}
+}
+
+func TestGenerateShortID(t *testing.T) {
+	for _, length := range []int{MinShortIDLength, 12, 21, MaxShortIDLength} {
+		id, err := GenerateShortID(length)
+		if err != nil {
+			t.Fatalf("GenerateShortID(%d) error = %v", length, err)
+		}
+		if len(id) != length {
+			t.Errorf("GenerateShortID(%d) length = %d", length, len(id))
+		}
+		for _, c := range id {
+			if !strings.ContainsRune(shortIDAlphabet, c) {
+				t.Errorf("GenerateShortID(%d) = %q, contains %q outside the alphabet", length, id, c)
+			}
+		}
+	}
+
+	for _, length := range []int{0, MinShortIDLength - 1, MaxShortIDLength + 1, -1} {
+		if id, err := GenerateShortID(length); err == nil {
+			t.Errorf("GenerateShortID(%d) = %q, want a length error", length, id)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for range 10000 {
+		id, _ := GenerateShortID(12)
+		if seen[id] {
+			t.Fatalf("GenerateShortID(12) repeated %q", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookTolerance is the maximum age (and clock skew) of a webhook timestamp accepted by VerifyWebhook.
+	WebhookTolerance = 5 * time.Minute
+
+	// webhookSecretPrefix is the prefix of Svix-style webhook secrets (e.g., "whsec_...").
+	webhookSecretPrefix = "whsec_"
+
+	// webhookSignatureVersion is the version tag prepended to each signature.
+	webhookSignatureVersion = "v1"
+)
+
+var (
+	// errWebhookMissingHeaders is returned when the id, timestamp or signature is empty.
+	errWebhookMissingHeaders = errors.New("the webhook is missing its id, timestamp or signature")
+
+	// errWebhookTimestamp is returned when the webhook timestamp is malformed or outside WebhookTolerance.
+	errWebhookTimestamp = errors.New("the webhook timestamp is invalid or too old")
+
+	// errWebhookSignature is returned when no signature of the webhook matches the payload.
+	errWebhookSignature = errors.New("the webhook signature does not match")
+)
+
+// SignWebhook signs an outbound webhook payload using the Svix signature scheme.
+// This is the scheme used by Clerk, Svix and many other providers, so receivers can
+// verify payloads with standard libraries. The result maps onto the request headers:
+//
+//	webhook-id / svix-id:               id
+//	webhook-timestamp / svix-timestamp: timestamp
+//	webhook-signature / svix-signature: signature
+//
+// The signature is "v1," followed by the base64 HMAC-SHA256 of "id.timestamp.payload".
+//
+// Example usage:
+//
+//	id, ts, sig := SignWebhook(body, secret)
+//	req.Header.Set("svix-id", id)
+//	req.Header.Set("svix-timestamp", ts)
+//	req.Header.Set("svix-signature", sig)
+//
+// Parameters:
+//   - payload: The exact request body to be sent
+//   - secret: The raw signing key shared with the receiver (see DecodeWebhookSecret)
+//
+// Returns:
+//   - string: A unique message ID ("msg_" followed by a UUID)
+//   - string: The Unix timestamp in seconds
+//   - string: The versioned signature
+func SignWebhook(payload []byte, secret []byte) (id, timestamp, signature string) {
+	id = "msg_" + GenerateUUID()
+	timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+	signature = webhookSignatureVersion + "," + base64.StdEncoding.EncodeToString(computeWebhookSignature(id, timestamp, payload, secret))
+	return id, timestamp, signature
+}
+
+// VerifyWebhook verifies a webhook payload signed with the Svix signature scheme.
+// The timestamp must be within WebhookTolerance of the current time to prevent replay
+// attacks, and at least one of the space-separated "v1,<base64>" signatures must match
+// (senders include several during secret rotation). Signatures are compared in
+// constant time.
+//
+// Example usage:
+//
+//	err := VerifyWebhook(body, secret,
+//	    r.Header.Get("svix-id"), r.Header.Get("svix-timestamp"), r.Header.Get("svix-signature"))
+//	if err != nil {
+//	    // reject the webhook
+//	}
+//
+// Parameters:
+//   - payload: The exact request body that was received
+//   - secret: The raw signing key (see DecodeWebhookSecret)
+//   - id: The message ID header value
+//   - timestamp: The timestamp header value (Unix seconds)
+//   - signature: The signature header value
+//
+// Returns:
+//   - error: An error if a value is missing, the timestamp is out of tolerance or no signature matches
+func VerifyWebhook(payload []byte, secret []byte, id, timestamp, signature string) error {
+	if id == "" || timestamp == "" || signature == "" {
+		return errWebhookMissingHeaders
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errWebhookTimestamp
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > WebhookTolerance || age < -WebhookTolerance {
+		return errWebhookTimestamp
+	}
+
+	expected := computeWebhookSignature(id, timestamp, payload, secret)
+	for _, candidate := range strings.Fields(signature) {
+		version, value, ok := strings.Cut(candidate, ",")
+		if !ok || version != webhookSignatureVersion {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		if hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+
+	return errWebhookSignature
+}
+
+// DecodeWebhookSecret decodes a Svix-style webhook secret into the raw signing key.
+// Secrets shown by providers such as Clerk have the form "whsec_<base64>"; the prefix
+// is optional.
+//
+// Example usage:
+//
+//	secret, err := DecodeWebhookSecret(os.Getenv("CLERK_WEBHOOK_SECRET"))
+//
+// Parameters:
+//   - s: The encoded secret
+//
+// Returns:
+//   - []byte: The raw signing key
+//   - error: An error if the secret is empty or not valid base64
+func DecodeWebhookSecret(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, webhookSecretPrefix))
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("the webhook secret is not valid base64")
+	}
+	return key, nil
+}
+
+// computeWebhookSignature computes the HMAC-SHA256 of the signed content "id.timestamp.payload".
+//
+// Parameters:
+//   - id: The message ID
+//   - timestamp: The Unix timestamp in seconds
+//   - payload: The request body
+//   - secret: The raw signing key
+//
+// Returns:
+//   - []byte: The raw HMAC signature
+func computeWebhookSignature(id, timestamp string, payload, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id))
+	mac.Write([]byte("."))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
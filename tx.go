@@ -0,0 +1,124 @@
+package anvil
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Tx is a request-scoped transaction managed by TxMiddleware.
+// It is satisfied by *sql.Tx and by the transaction types of most database drivers;
+// handlers type-assert the value from TxFromContext to their concrete type.
+type Tx interface {
+	// Commit makes the changes of the transaction permanent.
+	Commit() error
+
+	// Rollback discards the changes of the transaction.
+	Rollback() error
+}
+
+// TxMiddleware creates middleware that runs each mutating request in its own transaction.
+// For unsafe methods (POST, PUT, PATCH, DELETE, ...), a transaction is started with
+// begin and stored in the request context, where handlers retrieve it with
+// TxFromContext. Safe methods pass through without a transaction.
+//
+// The transaction is committed only when the handler responds with a 2xx status (set
+// with WriteHeader or implied by writing a body) while the request is still live. It
+// is rolled back when the handler responds with any other status, panics (the panic
+// is re-raised, so RecoverMiddleware can still handle it), writes no response at all
+// (answered with a 500), or when the request context is done by the time the handler
+// returns, since HandlerFunc sends nothing for errors on cancelled requests and any
+// partial output cannot be trusted. The response is buffered until the outcome
+// is known: if the commit fails, the client receives a 500 (Internal Server Error)
+// JSON error instead of a success response for changes that were never saved. If
+// begin fails, the request is answered with a 503 (Service Unavailable).
+//
+// Example usage:
+//
+//	tx := TxMiddleware(func(ctx context.Context) (Tx, error) {
+//	    return db.BeginTx(ctx, nil)
+//	})
+//	http.Handle("POST /api/orders", tx(HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+//	    t, _ := TxFromContext(r.Context())
+//	    _, err := t.(*sql.Tx).ExecContext(r.Context(), "INSERT INTO orders ...")
+//	    return err
+//	})))
+//
+// Parameters:
+//   - begin: The function starting a transaction for the request context
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that wraps mutating requests in a transaction
+func TxMiddleware(begin func(ctx context.Context) (Tx, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tx, err := begin(r.Context())
+			if err != nil {
+				slog.Error("unable to begin transaction", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+				writeError(w, http.StatusServiceUnavailable, errors.New("unable to begin transaction"))
+				return
+			}
+
+			committed := false
+			defer func() {
+				if !committed {
+					if err := tx.Rollback(); err != nil {
+						slog.Error("unable to roll back transaction", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+					}
+				}
+			}()
+
+			recorder := &bufferedResponse{header: make(http.Header)}
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), txContextKey, tx)))
+
+			if err := r.Context().Err(); err != nil {
+				slog.Info("request ended before the handler responded; rolling back transaction", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+				return
+			}
+			if !recorder.wroteHeader {
+				slog.Error("handler wrote no response; rolling back transaction", "method", r.Method, "path", r.URL.Path)
+				writeError(w, http.StatusInternalServerError, errors.New(InternalErrorMessage))
+				return
+			}
+			if recorder.status < 200 || recorder.status >= 300 {
+				recorder.replay(w)
+				return
+			}
+
+			committed = true
+			if err := tx.Commit(); err != nil {
+				slog.Error("unable to commit transaction", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+				writeError(w, http.StatusInternalServerError, errors.New("unable to commit transaction"))
+				return
+			}
+			recorder.replay(w)
+		})
+	}
+}
+
+// TxFromContext returns the transaction stored by TxMiddleware.
+//
+// Example usage:
+//
+//	tx, ok := TxFromContext(r.Context())
+//	if !ok {
+//	    // the request is not running in a transaction
+//	}
+//	sqlTx := tx.(*sql.Tx)
+//
+// Parameters:
+//   - ctx: The request context
+//
+// Returns:
+//   - Tx: The request transaction
+//   - bool: true if a transaction was found in the context, false otherwise
+func TxFromContext(ctx context.Context) (Tx, bool) {
+	tx, ok := ctx.Value(txContextKey).(Tx)
+	return tx, ok
+}
@@ -0,0 +1,176 @@
+package anvil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeTx records whether it was committed or rolled back.
+type fakeTx struct {
+	committed  bool
+	rolledBack bool
+	commitErr  error
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.committed = true
+	return tx.commitErr
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.rolledBack = true
+	return nil
+}
+
+// serveTx runs handler behind TxMiddleware with the given transaction and returns the response.
+func serveTx(t *testing.T, tx *fakeTx, r *http.Request, handler http.Handler) *httptest.ResponseRecorder {
+	t.Helper()
+	mw := TxMiddleware(func(ctx context.Context) (Tx, error) {
+		return tx, nil
+	})
+	rec := httptest.NewRecorder()
+	mw(handler).ServeHTTP(rec, r)
+	return rec
+}
+
+func TestTxMiddlewareCommitsOn2xx(t *testing.T) {
+	tx := &fakeTx{}
+	rec := serveTx(t, tx, httptest.NewRequest(http.MethodPost, "/orders", nil), HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if got, ok := TxFromContext(r.Context()); !ok || got != tx {
+			t.Errorf("TxFromContext() = %v, %v; want the request transaction", got, ok)
+		}
+		return RespondWithSuccess(w, http.StatusCreated, map[string]string{"id": "1"})
+	}))
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if !tx.committed || tx.rolledBack {
+		t.Errorf("committed = %v, rolledBack = %v; want commit only", tx.committed, tx.rolledBack)
+	}
+}
+
+func TestTxMiddlewareRollsBack(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.Handler
+		status  int
+	}{
+		{
+			name: "500",
+			handler: HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				return NewInternalError(errors.New("insert failed"))
+			}),
+			status: http.StatusInternalServerError,
+		},
+		{
+			name: "400",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, http.StatusBadRequest, errors.New("bad input"))
+			}),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:    "no response",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			status:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &fakeTx{}
+			rec := serveTx(t, tx, httptest.NewRequest(http.MethodPost, "/orders", nil), tt.handler)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tx.committed || !tx.rolledBack {
+				t.Errorf("committed = %v, rolledBack = %v; want rollback only", tx.committed, tx.rolledBack)
+			}
+		})
+	}
+}
+
+func TestTxMiddlewareRollsBackOnPanic(t *testing.T) {
+	tx := &fakeTx{}
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("recover() = %v, want the handler panic to be re-raised", p)
+		}
+		if tx.committed || !tx.rolledBack {
+			t.Errorf("committed = %v, rolledBack = %v; want rollback only", tx.committed, tx.rolledBack)
+		}
+	}()
+
+	serveTx(t, tx, httptest.NewRequest(http.MethodPost, "/orders", nil), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("boom")
+	}))
+}
+
+func TestTxMiddlewareRollsBackCancelledRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(http.MethodPost, "/orders", nil).WithContext(ctx)
+
+	tx := &fakeTx{}
+	serveTx(t, tx, r, HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte(`{"partial":`))
+		cancel()
+		return errors.New("insert failed")
+	}))
+
+	if tx.committed || !tx.rolledBack {
+		t.Errorf("committed = %v, rolledBack = %v; want rollback only", tx.committed, tx.rolledBack)
+	}
+}
+
+func TestTxMiddlewareCommitFailure(t *testing.T) {
+	tx := &fakeTx{commitErr: errors.New("serialization failure")}
+	rec := serveTx(t, tx, httptest.NewRequest(http.MethodPut, "/orders/1", nil), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestTxMiddlewareSkipsSafeMethods(t *testing.T) {
+	began := false
+	mw := TxMiddleware(func(ctx context.Context) (Tx, error) {
+		began = true
+		return &fakeTx{}, nil
+	})
+	rec := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := TxFromContext(r.Context()); ok {
+			t.Error("TxFromContext() found a transaction for a GET request")
+		}
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	if began {
+		t.Error("begin was called for a GET request")
+	}
+}
+
+func TestTxMiddlewareBeginFailure(t *testing.T) {
+	mw := TxMiddleware(func(ctx context.Context) (Tx, error) {
+		return nil, errors.New("pool exhausted")
+	})
+	called := false
+	rec := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", nil))
+
+	if called {
+		t.Error("handler was called although begin failed")
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
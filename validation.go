@@ -0,0 +1,340 @@
+package anvil
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxURLLength is the URL length limit used by MaxURLLengthMiddleware when a
+// non-positive limit is given. It is generous enough for large query strings while
+// staying below the limits of common proxies and browsers.
+const DefaultMaxURLLength = 8 * 1024
+
+// RequireContentType creates middleware that rejects requests with an unsupported Content-Type.
+// Handlers that assume JSON bodies break in confusing ways when a client posts
+// form-encoded data. This middleware rejects such requests up front with a 415
+// (Unsupported Media Type) JSON error response.
+//
+// Only methods that carry a body (POST, PUT and PATCH) are checked; other methods
+// pass through unchanged. Media type parameters such as charset are ignored, and
+// comparison is case-insensitive, so "application/json; charset=utf-8" matches
+// "application/json".
+//
+// Example usage:
+//
+//	http.Handle("/api/users", RequireContentType("application/json")(createUserHandler))
+//
+// Parameters:
+//   - types: The allowed media types (e.g., "application/json")
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces the allowed content types
+func RequireContentType(types ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(types))
+	for _, t := range types {
+		allowed[strings.ToLower(strings.TrimSpace(t))] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !methodHasBody(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || !allowed[strings.ToLower(mediaType)] {
+				writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type, expected one of: %s", strings.Join(types, ", ")))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// jsonBodyPeekSize is the number of leading bytes RequireJSONBody inspects to find the first
+// non-whitespace byte. Bodies with more leading whitespace are rejected.
+const jsonBodyPeekSize = 4096
+
+// RequireJSONBody creates middleware that rejects write requests whose body is not JSON.
+// Handlers that assume JSON otherwise fail deep inside decoding when a misbehaving
+// client or proxy posts an HTML error page or form data. For POST, PUT and PATCH
+// requests, this middleware peeks at the first non-whitespace byte of the body and
+// responds with a 400 (Bad Request) JSON error unless it is "{" or "[". Only the
+// peeked bytes are buffered, and they are replayed, so the handler reads the
+// complete body as usual.
+//
+// Unlike RequireContentType, which trusts the Content-Type header, this checks the
+// body itself. Empty (or whitespace-only) bodies pass when allowEmpty is set, for
+// endpoints whose body is optional, and are rejected with a 400 otherwise.
+//
+// Example usage:
+//
+//	http.Handle("/api/users", RequireJSONBody(false)(createUserHandler))
+//
+// Parameters:
+//   - allowEmpty: Whether requests without a body pass through
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that requires a JSON object or array body
+func RequireJSONBody(allowEmpty bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !methodHasBody(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body := bufio.NewReaderSize(r.Body, jsonBodyPeekSize)
+			first, err := firstNonSpace(body)
+			switch {
+			case errors.Is(err, io.EOF):
+				if !allowEmpty {
+					writeError(w, http.StatusBadRequest, errors.New("request body must not be empty"))
+					return
+				}
+			case errors.Is(err, bufio.ErrBufferFull):
+				writeError(w, http.StatusBadRequest, errors.New("request body must be a JSON object or array"))
+				return
+			case err != nil:
+				writeError(w, http.StatusBadRequest, errors.New("unable to read request body"))
+				return
+			case first != '{' && first != '[':
+				writeError(w, http.StatusBadRequest, errors.New("request body must be a JSON object or array"))
+				return
+			}
+
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{body, r.Body}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// firstNonSpace peeks at a reader until it finds a byte that is not JSON whitespace, without consuming anything.
+//
+// Parameters:
+//   - br: The buffered body
+//
+// Returns:
+//   - byte: The first non-whitespace byte
+//   - error: io.EOF for an empty or all-whitespace body, bufio.ErrBufferFull if the buffer holds only whitespace, or a read error
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for n := 1; ; n++ {
+		peeked, err := br.Peek(n)
+		if len(peeked) < n {
+			return 0, err
+		}
+		switch c := peeked[n-1]; c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			return c, nil
+		}
+	}
+}
+
+// methodHasBody reports whether requests with the given method are expected to carry a body.
+//
+// Parameters:
+//   - method: The HTTP method
+//
+// Returns:
+//   - bool: true for POST, PUT and PATCH, false otherwise
+func methodHasBody(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// RequireHeaders creates middleware that rejects requests missing any of the given headers.
+// APIs often depend on headers such as X-Api-Version or Accept. This middleware checks
+// every named header and responds with a 400 (Bad Request) JSON error listing all of the
+// missing ones, so clients can fix them in a single round trip. Headers that are present
+// but empty (or whitespace only) are treated as missing.
+//
+// Example usage:
+//
+//	http.Handle("/api/", RequireHeaders("X-Api-Version", "Accept")(apiHandler))
+//	// Missing both: {"error": "missing required headers: X-Api-Version, Accept", ...}
+//
+// Parameters:
+//   - names: The names of the required headers
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces the required headers
+func RequireHeaders(names ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var missing []string
+			for _, name := range names {
+				if strings.TrimSpace(r.Header.Get(name)) == "" {
+					missing = append(missing, http.CanonicalHeaderKey(name))
+				}
+			}
+
+			if len(missing) > 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("missing required headers: %s", strings.Join(missing, ", ")))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MaxURLLengthMiddleware creates middleware that rejects requests with overly long URLs.
+// Very long URLs and query strings can be an abuse vector and bloat access logs. This
+// middleware responds with a 414 (URI Too Long) JSON error when the length of the
+// request URL (path and query) exceeds maxBytes. URLs exactly at the limit are allowed.
+//
+// Example usage:
+//
+//	http.Handle("/api/", MaxURLLengthMiddleware(2048)(apiHandler))
+//
+// Parameters:
+//   - maxBytes: The maximum URL length in bytes (DefaultMaxURLLength if <= 0)
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces the URL length limit
+func MaxURLLengthMiddleware(maxBytes int) func(http.Handler) http.Handler {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxURLLength
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.URL.String()) > maxBytes {
+				writeError(w, http.StatusRequestURITooLong, fmt.Errorf("request url must not exceed %d bytes", maxBytes))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HeaderLimitMiddleware creates middleware that rejects requests with too many or oversized headers.
+// The server's MaxHeaderBytes only caps the total size of the header block. This
+// middleware additionally caps the number of header fields and the length of any
+// single header value, which mitigates abuse through many small or one huge header.
+// Requests exceeding either limit receive a 431 (Request Header Fields Too Large)
+// JSON error response.
+//
+// Every value counts as one header field, so a header repeated three times counts
+// three times. A non-positive limit disables that check.
+//
+// Example usage:
+//
+//	http.Handle("/api/", HeaderLimitMiddleware(64, 4096)(apiHandler))
+//
+// Parameters:
+//   - maxHeaders: The maximum number of header fields (<= 0 for no limit)
+//   - maxValueLen: The maximum length in bytes of a single header value (<= 0 for no limit)
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces the header limits
+func HeaderLimitMiddleware(maxHeaders int, maxValueLen int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			count := 0
+			for name, values := range r.Header {
+				count += len(values)
+				if maxValueLen <= 0 {
+					continue
+				}
+				for _, value := range values {
+					if len(value) > maxValueLen {
+						writeError(w, http.StatusRequestHeaderFieldsTooLarge, fmt.Errorf("header %s must not exceed %d bytes", name, maxValueLen))
+						return
+					}
+				}
+			}
+
+			if maxHeaders > 0 && count > maxHeaders {
+				writeError(w, http.StatusRequestHeaderFieldsTooLarge, fmt.Errorf("request must not have more than %d headers", maxHeaders))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AllowedHostsMiddleware creates middleware that rejects requests for unknown hosts.
+// Handlers that build absolute URLs (redirects, password reset links, cache keys)
+// from the Host header can be poisoned by a forged Host. This middleware responds
+// with a 400 (Bad Request) JSON error unless the request's host is in the allowlist.
+//
+// The port is stripped from the Host header before comparison, and comparison is
+// case-insensitive. An entry of the form "*.example.com" matches any subdomain of
+// example.com (e.g., "api.example.com" or "a.b.example.com") but not example.com
+// itself; list the apex separately if it should be allowed.
+//
+// Example usage:
+//
+//	handler := AllowedHostsMiddleware("example.com", "*.example.com")(router)
+//
+// Parameters:
+//   - hosts: The allowed host names, optionally with a leading "*." wildcard
+//
+// Returns:
+//   - func(http.Handler) http.Handler: A middleware that enforces the host allowlist
+func AllowedHostsMiddleware(hosts ...string) func(http.Handler) http.Handler {
+	exact := make(map[string]bool, len(hosts))
+	var suffixes []string
+	for _, host := range hosts {
+		host = strings.ToLower(host)
+		if suffix, ok := strings.CutPrefix(host, "*"); ok && strings.HasPrefix(suffix, ".") {
+			suffixes = append(suffixes, suffix)
+			continue
+		}
+		exact[host] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := strings.TrimSuffix(strings.ToLower(stripPort(r.Host)), ".")
+			allowed := exact[host]
+			for _, suffix := range suffixes {
+				if allowed {
+					break
+				}
+				allowed = len(host) > len(suffix) && strings.HasSuffix(host, suffix)
+			}
+
+			if !allowed {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("host %q is not allowed", r.Host))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// stripPort removes the port from a host, handling bracketed IPv6 addresses.
+//
+// Parameters:
+//   - hostport: A host with an optional port (e.g., "example.com:8080" or "[::1]:8080")
+//
+// Returns:
+//   - string: The host without its port or brackets
+func stripPort(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(hostport, "["), "]")
+}
@@ -0,0 +1,212 @@
+package anvil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// record serves r with handler and returns the recorded response.
+func record(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestRequireContentType(t *testing.T) {
+	handler := RequireContentType("application/json")(statusHandler(http.StatusOK))
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		status      int
+	}{
+		{name: "json", method: http.MethodPost, contentType: "application/json", status: http.StatusOK},
+		{name: "parameters and case", method: http.MethodPut, contentType: "Application/JSON; charset=utf-8", status: http.StatusOK},
+		{name: "form", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", status: http.StatusUnsupportedMediaType},
+		{name: "missing", method: http.MethodPatch, status: http.StatusUnsupportedMediaType},
+		{name: "method without body", method: http.MethodGet, contentType: "text/plain", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/users", strings.NewReader(`{}`))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if rec := record(handler, r); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestRequireHeaders(t *testing.T) {
+	handler := RequireHeaders("x-api-version", "Accept")(statusHandler(http.StatusOK))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		message string
+	}{
+		{name: "all present", headers: map[string]string{"X-Api-Version": "2", "Accept": "application/json"}, status: http.StatusOK},
+		{name: "one missing", headers: map[string]string{"Accept": "application/json"}, status: http.StatusBadRequest, message: "missing required headers: X-Api-Version"},
+		{name: "empty value", headers: map[string]string{"X-Api-Version": " ", "Accept": "application/json"}, status: http.StatusBadRequest, message: "missing required headers: X-Api-Version"},
+		{name: "multiple missing", status: http.StatusBadRequest, message: "missing required headers: X-Api-Version, Accept"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			rec := record(handler, r)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.message != "" {
+				if got := decodeErrorBody(t, rec)["error"]; got != tt.message {
+					t.Errorf("error = %q, want %q", got, tt.message)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxURLLengthMiddleware(t *testing.T) {
+	const limit = 32
+	base := "/search?q="
+	atLimit := base + strings.Repeat("a", limit-len(base))
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{name: "short", target: "/search?q=go", status: http.StatusOK},
+		{name: "at limit", target: atLimit, status: http.StatusOK},
+		{name: "over limit", target: atLimit + "a", status: http.StatusRequestURITooLong},
+	}
+
+	handler := MaxURLLengthMiddleware(limit)(statusHandler(http.StatusOK))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := record(handler, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+
+	long := "/search?q=" + strings.Repeat("a", DefaultMaxURLLength)
+	if rec := record(MaxURLLengthMiddleware(0)(statusHandler(http.StatusOK)), httptest.NewRequest(http.MethodGet, long, nil)); rec.Code != http.StatusRequestURITooLong {
+		t.Errorf("status with the default limit = %d, want %d", rec.Code, http.StatusRequestURITooLong)
+	}
+}
+
+func TestHeaderLimitMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxHeaders  int
+		maxValueLen int
+		headers     http.Header
+		status      int
+	}{
+		{name: "within limits", maxHeaders: 3, maxValueLen: 16, headers: http.Header{"Accept": {"application/json"}, "X-Trace": {"abc"}}, status: http.StatusOK},
+		{name: "too many headers", maxHeaders: 3, maxValueLen: 16, headers: http.Header{"A": {"1"}, "B": {"2"}, "C": {"3"}, "D": {"4"}}, status: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "repeated values count", maxHeaders: 3, headers: http.Header{"X-Forwarded-For": {"1", "2", "3", "4"}}, status: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "value too long", maxHeaders: 3, maxValueLen: 16, headers: http.Header{"Cookie": {strings.Repeat("a", 17)}}, status: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "checks disabled", headers: http.Header{"A": {"1"}, "B": {strings.Repeat("a", 1024)}}, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api", nil)
+			r.Header = tt.headers
+			rec := record(HeaderLimitMiddleware(tt.maxHeaders, tt.maxValueLen)(statusHandler(http.StatusOK)), r)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestAllowedHostsMiddleware(t *testing.T) {
+	handler := AllowedHostsMiddleware("example.com", "*.api.example.com", "::1")(statusHandler(http.StatusOK))
+
+	tests := []struct {
+		host   string
+		status int
+	}{
+		{host: "example.com", status: http.StatusOK},
+		{host: "Example.COM:8443", status: http.StatusOK},
+		{host: "example.com.", status: http.StatusOK},
+		{host: "eu.api.example.com", status: http.StatusOK},
+		{host: "a.b.api.example.com:443", status: http.StatusOK},
+		{host: "[::1]:8080", status: http.StatusOK},
+		{host: "api.example.com", status: http.StatusBadRequest},
+		{host: "www.example.com", status: http.StatusBadRequest},
+		{host: "evilexample.com", status: http.StatusBadRequest},
+		{host: "eu.api.example.com.evil.com", status: http.StatusBadRequest},
+		{host: "", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/reset-password", nil)
+		r.Host = tt.host
+		if rec := record(handler, r); rec.Code != tt.status {
+			t.Errorf("Host %q status = %d, want %d", tt.host, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestRequireJSONBody(t *testing.T) {
+	var received string
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		allowEmpty bool
+		status     int
+	}{
+		{name: "object", method: http.MethodPost, body: `{"name":"Ada"}`, status: http.StatusOK},
+		{name: "array after whitespace", method: http.MethodPut, body: " \n\t[1,2]", status: http.StatusOK},
+		{name: "html", method: http.MethodPost, body: "<html><body>502 Bad Gateway</body></html>", status: http.StatusBadRequest},
+		{name: "form", method: http.MethodPatch, body: "name=Ada", status: http.StatusBadRequest},
+		{name: "empty rejected", method: http.MethodPost, body: "", status: http.StatusBadRequest},
+		{name: "whitespace rejected", method: http.MethodPost, body: "  \n", status: http.StatusBadRequest},
+		{name: "empty allowed", method: http.MethodPost, body: "", allowEmpty: true, status: http.StatusOK},
+		{name: "only whitespace beyond the peek", method: http.MethodPost, body: strings.Repeat(" ", jsonBodyPeekSize+10) + "{}", status: http.StatusBadRequest},
+		{name: "method without body", method: http.MethodGet, body: "<html>", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = ""
+			handler := RequireJSONBody(tt.allowEmpty)(echo)
+			rec := record(handler, httptest.NewRequest(tt.method, "/users", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && received != tt.body {
+				t.Errorf("handler read %q, want the complete body %q", received, tt.body)
+			}
+			if tt.status == http.StatusBadRequest {
+				if body := decodeErrorBody(t, rec); body["code"] != CodeBadRequest {
+					t.Errorf("body = %v, want a %s JSON error", body, CodeBadRequest)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package anvil
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+)
+
+// verifyCacheSweepInterval is the minimum time between sweeps of expired verification results.
+const verifyCacheSweepInterval = time.Minute
+
+// errVerifyPanicked is returned to requests waiting on a verification that panicked.
+var errVerifyPanicked = errors.New("token verification failed")
+
+// verifyCache remembers successful token verifications for a short time.
+// Under bursts from one client the same token is verified over and over, which is
+// costly when verification involves the network (e.g., fetching Clerk's JWKS).
+// Results are keyed by the SHA-256 of the token, so tokens are not kept in memory,
+// and are valid until the TTL or the token's own expiry, whichever comes first.
+// Concurrent verifications of the same token are collapsed into one call. Failures
+// are shared with concurrent callers but never cached.
+type verifyCache[T any] struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[[sha256.Size]byte]verifyEntry[T]
+	calls     map[[sha256.Size]byte]*verifyCall[T]
+	lastSweep time.Time
+}
+
+// verifyEntry is a cached verification result with its expiry.
+type verifyEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// verifyCall tracks an in-flight verification shared by concurrent requests.
+type verifyCall[T any] struct {
+	wg    sync.WaitGroup
+	value T
+	err   error
+}
+
+// newVerifyCache creates a verification cache.
+//
+// Parameters:
+//   - ttl: How long a successful verification is reused
+//
+// Returns:
+//   - *verifyCache[T]: A new, empty cache
+func newVerifyCache[T any](ttl time.Duration) *verifyCache[T] {
+	return &verifyCache[T]{
+		ttl:       ttl,
+		entries:   make(map[[sha256.Size]byte]verifyEntry[T]),
+		calls:     make(map[[sha256.Size]byte]*verifyCall[T]),
+		lastSweep: time.Now(),
+	}
+}
+
+// verify returns the cached result for a token or verifies it with fn.
+// fn returns the verification result and the token's expiry (zero if it has none).
+//
+// Parameters:
+//   - token: The token to verify
+//   - fn: The verification call
+//
+// Returns:
+//   - T: The verification result
+//   - error: The verification error
+func (c *verifyCache[T]) verify(token string, fn func(string) (T, time.Time, error)) (T, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	c.mu.Lock()
+	if now.Sub(c.lastSweep) >= verifyCacheSweepInterval {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expires) {
+		c.mu.Unlock()
+		return entry.value, nil
+	}
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.value, call.err
+	}
+
+	call := &verifyCall[T]{}
+	call.wg.Add(1)
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	var expiry time.Time
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		if call.err == nil {
+			expires := time.Now().Add(c.ttl)
+			if !expiry.IsZero() && expiry.Before(expires) {
+				expires = expiry
+			}
+			c.entries[key] = verifyEntry[T]{value: call.value, expires: expires}
+		}
+		c.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	call.err = errVerifyPanicked
+	call.value, expiry, call.err = fn(token)
+	return call.value, call.err
+}
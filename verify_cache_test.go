@@ -0,0 +1,115 @@
+package anvil
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingVerifier returns a verification function that counts its calls and reports the given expiry.
+func countingVerifier(calls *atomic.Int32, expiry time.Time, err error) func(string) (string, time.Time, error) {
+	return func(token string) (string, time.Time, error) {
+		calls.Add(1)
+		return "claims for " + token, expiry, err
+	}
+}
+
+func TestVerifyCacheReusesResults(t *testing.T) {
+	cache := newVerifyCache[string](time.Minute)
+	var calls atomic.Int32
+	verify := countingVerifier(&calls, time.Now().Add(time.Hour), nil)
+
+	for range 5 {
+		if got, err := cache.verify("token-a", verify); err != nil || got != "claims for token-a" {
+			t.Fatalf("verify() = %q, %v; want the claims", got, err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("verifications of one token = %d, want 1", got)
+	}
+
+	cache.verify("token-b", verify)
+	if got := calls.Load(); got != 2 {
+		t.Errorf("verifications after another token = %d, want 2", got)
+	}
+}
+
+func TestVerifyCacheSkipsFailures(t *testing.T) {
+	cache := newVerifyCache[string](time.Minute)
+	var calls atomic.Int32
+	invalid := errors.New("invalid token")
+	verify := countingVerifier(&calls, time.Time{}, invalid)
+
+	for range 3 {
+		if _, err := cache.verify("token-a", verify); !errors.Is(err, invalid) {
+			t.Fatalf("verify() error = %v, want %v", err, invalid)
+		}
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("verifications of a failing token = %d, want 3", got)
+	}
+}
+
+func TestVerifyCacheRespectsExpiry(t *testing.T) {
+	const window = 50 * time.Millisecond
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		expiresIn time.Duration
+	}{
+		{name: "cache ttl", ttl: window},
+		{name: "token expiry", ttl: time.Hour, expiresIn: window},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newVerifyCache[string](tt.ttl)
+			var expiry time.Time
+			if tt.expiresIn > 0 {
+				expiry = time.Now().Add(tt.expiresIn)
+			}
+			var calls atomic.Int32
+			verify := countingVerifier(&calls, expiry, nil)
+
+			cache.verify("token-a", verify)
+			cache.verify("token-a", verify)
+			time.Sleep(2 * window)
+			cache.verify("token-a", verify)
+
+			if got := calls.Load(); got != 2 {
+				t.Errorf("verifications = %d, want 2 after the entry expired", got)
+			}
+		})
+	}
+}
+
+func TestVerifyCacheCollapsesConcurrentCalls(t *testing.T) {
+	cache := newVerifyCache[string](time.Minute)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	verify := func(token string) (string, time.Time, error) {
+		calls.Add(1)
+		<-release
+		return "claims", time.Time{}, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := cache.verify("token-a", verify); err != nil || got != "claims" {
+				t.Errorf("verify() = %q, %v; want the claims", got, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("concurrent verifications = %d, want 1", got)
+	}
+}